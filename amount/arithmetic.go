@@ -0,0 +1,136 @@
+package amount
+
+import (
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// RoundingMode controls how values that can't be represented exactly are
+// rounded.
+type RoundingMode int
+
+const (
+	// RoundDown rounds toward negative infinity.
+	RoundDown RoundingMode = iota
+	// RoundUp rounds toward positive infinity.
+	RoundUp
+	// RoundHalfUp rounds to the nearest value, ties away from zero.
+	RoundHalfUp
+	// RoundHalfEven rounds to the nearest value, ties to the even value.
+	RoundHalfEven
+)
+
+// ErrOverflow is returned when the result of an operation doesn't fit in an
+// amount.
+var ErrOverflow = errors.New("amount overflow")
+
+// Add returns a + b or ErrOverflow if the sum doesn't fit in an amount.
+func Add(a, b xdr.Int64) (xdr.Int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+// Sub returns a - b or ErrOverflow if the difference doesn't fit in an
+// amount.
+func Sub(a, b xdr.Int64) (xdr.Int64, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrOverflow
+	}
+	return a - b, nil
+}
+
+// Mul returns the product of amounts a and b, ex. 2.5 * 1.5 = 3.75, rounded
+// to 7 decimal digits using `mode`. It returns ErrOverflow if the product
+// doesn't fit in an amount.
+func Mul(a, b xdr.Int64, mode RoundingMode) (xdr.Int64, error) {
+	r := Rat(a)
+	r.Mul(r, Rat(b))
+	return FromRat(r, mode)
+}
+
+// Rat returns the exact value of the amount `v`, ex. 1.5 for 15000000.
+func Rat(v xdr.Int64) *big.Rat {
+	return big.NewRat(int64(v), One)
+}
+
+// FromRat returns the amount closest to `r` using `mode` for values with more
+// than 7 decimal digits. It returns ErrOverflow if `r` doesn't fit in an
+// amount.
+func FromRat(r *big.Rat, mode RoundingMode) (xdr.Int64, error) {
+	scaled := new(big.Rat).Mul(r, bigOne)
+	i := roundRat(scaled, mode)
+	if !i.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return xdr.Int64(i.Int64()), nil
+}
+
+// Format returns an "amount string" of `v` with exactly `decimals` digits
+// after the decimal point, rounded using `mode`.
+func Format(v xdr.Int64, decimals int, mode RoundingMode) string {
+	return FormatRat(Rat(v), decimals, mode)
+}
+
+// FormatRat returns a decimal representation of `r` with exactly `decimals`
+// digits after the decimal point, rounded using `mode`. Unlike formatting
+// floats, the result never depends on the locale and is never in
+// scientific notation: "." is always the decimal separator and there are
+// no thousands separators.
+func FormatRat(r *big.Rat, decimals int, mode RoundingMode) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	i := roundRat(scaled, mode)
+
+	sign := ""
+	if i.Sign() < 0 {
+		sign = "-"
+		i.Neg(i)
+	}
+
+	digits := i.String()
+	if decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	split := len(digits) - decimals
+	return sign + digits[:split] + "." + digits[split:]
+}
+
+// roundRat rounds `r` to an integer using `mode`.
+func roundRat(r *big.Rat, mode RoundingMode) *big.Int {
+	// Denominator of big.Rat is always positive so Euclidean division
+	// returns the floor of `r` and a non-negative remainder.
+	q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() == 0 {
+		return q
+	}
+
+	one := big.NewInt(1)
+	switch mode {
+	case RoundUp:
+		return q.Add(q, one)
+	case RoundHalfUp, RoundHalfEven:
+		switch m.Lsh(m, 1).Cmp(r.Denom()) {
+		case 1:
+			return q.Add(q, one)
+		case 0:
+			// A tie: for negative `r`, q is the value away from zero.
+			if (mode == RoundHalfUp && r.Sign() > 0) || (mode == RoundHalfEven && q.Bit(0) == 1) {
+				return q.Add(q, one)
+			}
+		}
+	}
+	return q
+}
@@ -0,0 +1,110 @@
+package amount_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddSub(t *testing.T) {
+	sum, err := amount.Add(amount.MustParse("1.5"), amount.MustParse("2.25"))
+	require.NoError(t, err)
+	assert.Equal(t, amount.MustParse("3.75"), sum)
+
+	_, err = amount.Add(math.MaxInt64, 1)
+	assert.Equal(t, amount.ErrOverflow, err)
+	_, err = amount.Add(math.MinInt64, -1)
+	assert.Equal(t, amount.ErrOverflow, err)
+	sum, err = amount.Add(math.MaxInt64, math.MinInt64)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Int64(-1), sum)
+
+	diff, err := amount.Sub(amount.MustParse("1.5"), amount.MustParse("2.25"))
+	require.NoError(t, err)
+	assert.Equal(t, amount.MustParse("-0.75"), diff)
+
+	_, err = amount.Sub(math.MinInt64, 1)
+	assert.Equal(t, amount.ErrOverflow, err)
+	_, err = amount.Sub(0, math.MinInt64)
+	assert.Equal(t, amount.ErrOverflow, err)
+	diff, err = amount.Sub(-1, math.MinInt64)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Int64(math.MaxInt64), diff)
+}
+
+func TestMul(t *testing.T) {
+	product, err := amount.Mul(amount.MustParse("2.5"), amount.MustParse("1.5"), amount.RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, amount.MustParse("3.75"), product)
+
+	// 0.0000001 * 0.5 = 0.00000005
+	for _, c := range []struct {
+		mode     amount.RoundingMode
+		a        string
+		expected string
+	}{
+		{amount.RoundDown, "0.0000001", "0.0000000"},
+		{amount.RoundUp, "0.0000001", "0.0000001"},
+		{amount.RoundHalfUp, "0.0000001", "0.0000001"},
+		{amount.RoundHalfEven, "0.0000001", "0.0000000"},
+		{amount.RoundHalfEven, "0.0000003", "0.0000002"},
+		{amount.RoundDown, "-0.0000001", "-0.0000001"},
+		{amount.RoundUp, "-0.0000001", "0.0000000"},
+		{amount.RoundHalfUp, "-0.0000001", "-0.0000001"},
+		{amount.RoundHalfEven, "-0.0000001", "0.0000000"},
+	} {
+		product, err := amount.Mul(amount.MustParse(c.a), amount.MustParse("0.5"), c.mode)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, amount.String(product), "%s with mode %d", c.a, c.mode)
+	}
+
+	_, err = amount.Mul(math.MaxInt64, amount.MustParse("1.0000001"), amount.RoundDown)
+	assert.Equal(t, amount.ErrOverflow, err)
+}
+
+func TestRat(t *testing.T) {
+	assert.Equal(t, big.NewRat(3, 2), amount.Rat(amount.MustParse("1.5")))
+
+	for _, v := range []xdr.Int64{math.MaxInt64, math.MinInt64, 0, 1, -1} {
+		back, err := amount.FromRat(amount.Rat(v), amount.RoundDown)
+		require.NoError(t, err)
+		assert.Equal(t, v, back)
+	}
+
+	v, err := amount.FromRat(big.NewRat(1, 3), amount.RoundUp)
+	require.NoError(t, err)
+	assert.Equal(t, "0.3333334", amount.String(v))
+
+	_, err = amount.FromRat(big.NewRat(math.MaxInt64, 1), amount.RoundDown)
+	assert.Equal(t, amount.ErrOverflow, err)
+}
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		v        string
+		decimals int
+		mode     amount.RoundingMode
+		expected string
+	}{
+		{"1234567.1234567", 7, amount.RoundDown, "1234567.1234567"},
+		{"1234567.1234567", 9, amount.RoundDown, "1234567.123456700"},
+		{"1234567.1234567", 2, amount.RoundDown, "1234567.12"},
+		{"1234567.1234567", 2, amount.RoundUp, "1234567.13"},
+		{"1234567.5", 0, amount.RoundHalfUp, "1234568"},
+		{"-1234567.5", 0, amount.RoundHalfUp, "-1234568"},
+		{"-1234567.5", 0, amount.RoundHalfEven, "-1234568"},
+		{"0.125", 2, amount.RoundHalfEven, "0.12"},
+		{"0.0000001", 3, amount.RoundDown, "0.000"},
+		{"-0.0000001", 3, amount.RoundDown, "-0.001"},
+		{"-0.0000001", 3, amount.RoundUp, "0.000"},
+		{"0.5", -1, amount.RoundDown, "0"},
+	} {
+		assert.Equal(t, c.expected, amount.Format(amount.MustParse(c.v), c.decimals, c.mode),
+			"%s with %d decimals and mode %d", c.v, c.decimals, c.mode)
+	}
+}
@@ -0,0 +1,6 @@
+// Package keystore implements the client side encryption scheme used for keys
+// stored in the Stellar keystore service (services/keystore). Keys are
+// encrypted with a key derived from a password using scrypt and sealed with
+// NaCl secretbox, compatible with the ScryptEncrypter of js-stellar-wallets:
+// https://github.com/stellar/js-stellar-wallets/blob/master/src/helpers/ScryptEncryption.ts
+package keystore
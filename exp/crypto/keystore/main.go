@@ -0,0 +1,202 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// EncrypterName is the name of the encrypter implemented by this package,
+	// as stored in the `encrypterName` field of EncryptedKeyData.
+	EncrypterName = "ScryptEncrypter"
+	// KeyTypePlaintext is the key type of raw Stellar secret seeds.
+	KeyTypePlaintext = "plaintextKey"
+
+	currentVersion = 1
+	saltBytes      = 32
+	nonceBytes     = 24
+	keyBytes       = 32
+
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrEmptyPassword      = errors.New("Password cannot be empty")
+	ErrInvalidEncrypter   = errors.New("Unsupported encrypter")
+	ErrInvalidBlob        = errors.New("Invalid encrypted blob")
+	ErrUnsupportedVersion = errors.New("Unsupported encrypted blob version")
+	ErrDecryptionFailed   = errors.New("Decryption failed, invalid password?")
+)
+
+var randReader io.Reader = rand.Reader
+
+// RawKeyData is a key before encryption, see `RawKeyData` in the keystore
+// spec.
+type RawKeyData struct {
+	KeyType    string      `json:"keyType"`
+	PublicKey  string      `json:"publicKey"`
+	PrivateKey string      `json:"privateKey"`
+	Path       string      `json:"path,omitempty"`
+	Extra      interface{} `json:"extra,omitempty"`
+}
+
+// EncryptedKeyData is an encrypted RawKeyData, see `EncryptedKeyData` in the
+// keystore spec.
+type EncryptedKeyData struct {
+	ID            string `json:"id"`
+	Salt          string `json:"salt"`
+	EncrypterName string `json:"encrypterName"`
+	EncryptedBlob string `json:"encryptedBlob"`
+}
+
+// Encrypt encrypts secret with password. It returns the base64 encoded salt
+// and the base64 encoded blob containing the version, the nonce and the
+// ciphertext.
+func Encrypt(secret []byte, password string) (salt, blob string, err error) {
+	if password == "" {
+		return "", "", ErrEmptyPassword
+	}
+
+	rawSalt := make([]byte, saltBytes)
+	if _, err = io.ReadFull(randReader, rawSalt); err != nil {
+		return "", "", err
+	}
+	salt = base64.StdEncoding.EncodeToString(rawSalt)
+
+	var nonce [nonceBytes]byte
+	if _, err = io.ReadFull(randReader, nonce[:]); err != nil {
+		return "", "", err
+	}
+
+	key, err := deriveKey(password, salt)
+	if err != nil {
+		return "", "", err
+	}
+
+	bundle := make([]byte, 0, 1+nonceBytes+len(secret)+secretbox.Overhead)
+	bundle = append(bundle, currentVersion)
+	bundle = append(bundle, nonce[:]...)
+	bundle = secretbox.Seal(bundle, secret, &nonce, key)
+
+	return salt, base64.StdEncoding.EncodeToString(bundle), nil
+}
+
+// Decrypt reverses Encrypt.
+func Decrypt(salt, blob, password string) ([]byte, error) {
+	bundle, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		return nil, ErrInvalidBlob
+	}
+
+	if len(bundle) < 1+nonceBytes+secretbox.Overhead {
+		return nil, ErrInvalidBlob
+	}
+
+	if bundle[0] != currentVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	var nonce [nonceBytes]byte
+	copy(nonce[:], bundle[1:1+nonceBytes])
+
+	key, err := deriveKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, ok := secretbox.Open(nil, bundle[1+nonceBytes:], &nonce, key)
+	if !ok {
+		return nil, ErrDecryptionFailed
+	}
+
+	return secret, nil
+}
+
+// EncryptKey encrypts raw with password and returns it as EncryptedKeyData
+// with the given ID.
+func EncryptKey(id string, raw RawKeyData, password string) (EncryptedKeyData, error) {
+	secret, err := json.Marshal(raw)
+	if err != nil {
+		return EncryptedKeyData{}, err
+	}
+
+	salt, blob, err := Encrypt(secret, password)
+	if err != nil {
+		return EncryptedKeyData{}, err
+	}
+
+	return EncryptedKeyData{
+		ID:            id,
+		Salt:          salt,
+		EncrypterName: EncrypterName,
+		EncryptedBlob: blob,
+	}, nil
+}
+
+// DecryptKey decrypts key with password.
+func DecryptKey(key EncryptedKeyData, password string) (RawKeyData, error) {
+	if key.EncrypterName != EncrypterName {
+		return RawKeyData{}, ErrInvalidEncrypter
+	}
+
+	secret, err := Decrypt(key.Salt, key.EncryptedBlob, password)
+	if err != nil {
+		return RawKeyData{}, err
+	}
+
+	var raw RawKeyData
+	if err := json.Unmarshal(secret, &raw); err != nil {
+		return RawKeyData{}, err
+	}
+
+	return raw, nil
+}
+
+// KeysBlob encodes keys as the `keysBlob` value expected by the keystore
+// service (`base64_url_encode(EncryptedKeys)`, without padding).
+func KeysBlob(keys []EncryptedKeyData) (string, error) {
+	encoded, err := json.Marshal(keys)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// ParseKeysBlob decodes a `keysBlob` value returned by the keystore service.
+func ParseKeysBlob(blob string) ([]EncryptedKeyData, error) {
+	encoded, err := base64.RawURLEncoding.DecodeString(blob)
+	if err != nil {
+		return nil, ErrInvalidBlob
+	}
+
+	var keys []EncryptedKeyData
+	if err := json.Unmarshal(encoded, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func deriveKey(password, salt string) (*[keyBytes]byte, error) {
+	if password == "" {
+		return nil, ErrEmptyPassword
+	}
+
+	derived, err := scrypt.Key([]byte(password), []byte(salt), scryptN, scryptR, scryptP, keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [keyBytes]byte
+	copy(key[:], derived)
+	return &key, nil
+}
@@ -0,0 +1,57 @@
+package keystore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	salt, blob, err := Encrypt([]byte("secret"), "password")
+	require.NoError(t, err)
+
+	secret, err := Decrypt(salt, blob, "password")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), secret)
+
+	_, err = Decrypt(salt, blob, "wrong password")
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	_, _, err = Encrypt([]byte("secret"), "")
+	assert.Equal(t, ErrEmptyPassword, err)
+
+	_, err = Decrypt(salt, "AQ==", "password")
+	assert.Equal(t, ErrInvalidBlob, err)
+}
+
+func TestEncryptKeyAndKeysBlob(t *testing.T) {
+	raw := RawKeyData{
+		KeyType:    KeyTypePlaintext,
+		PublicKey:  "GB3JDWCQJCWMJ3IILWIGDTQJJC5567PGVEVXSCVPEQOTDN64VJBDQBYX",
+		PrivateKey: "SBUV3MRWKNS6AYKZ6E6MOUVF2OYMON3MIUASWL3JLY5E3ISDJFELYBRZ",
+		Path:       "m/44'/148'/0'",
+	}
+
+	key, err := EncryptKey(raw.PublicKey, raw, "password")
+	require.NoError(t, err)
+	assert.Equal(t, EncrypterName, key.EncrypterName)
+	assert.Equal(t, raw.PublicKey, key.ID)
+
+	blob, err := KeysBlob([]EncryptedKeyData{key})
+	require.NoError(t, err)
+	assert.NotContains(t, blob, "=")
+
+	keys, err := ParseKeysBlob(blob)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key, keys[0])
+
+	decrypted, err := DecryptKey(keys[0], "password")
+	require.NoError(t, err)
+	assert.Equal(t, raw, decrypted)
+
+	keys[0].EncrypterName = "IdentityEncrypter"
+	_, err = DecryptKey(keys[0], "password")
+	assert.Equal(t, ErrInvalidEncrypter, err)
+}
@@ -1,7 +1,9 @@
 package adapters
 
 import (
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/stellar/go/exp/ingest/io"
 	"github.com/stellar/go/support/errors"
@@ -12,6 +14,20 @@ import (
 // HistoryArchiveAdapter is an adapter for the historyarchive package to read from history archives
 type HistoryArchiveAdapter struct {
 	archive historyarchive.ArchiveInterface
+
+	// checkpointMutex protects checkpoint, the data of the last checkpoint
+	// read by GetLedger. Ledgers are usually read in order so caching a
+	// single checkpoint saves downloading the same files for every ledger.
+	checkpointMutex sync.Mutex
+	checkpoint      *archiveCheckpoint
+}
+
+// archiveCheckpoint is the data of all ledgers of a single checkpoint.
+type archiveCheckpoint struct {
+	sequence   uint32
+	headers    map[uint32]xdr.LedgerHeaderHistoryEntry
+	txSets     map[uint32]*xdr.TransactionSet
+	resultSets map[uint32]*xdr.TransactionResultSet
 }
 
 // MakeHistoryArchiveAdapter is a factory method to make a HistoryArchiveAdapter
@@ -71,7 +87,120 @@ func (haa *HistoryArchiveAdapter) GetState(
 	return sr, nil
 }
 
-// GetLedger gets a ledger transaction result at the provided sequence number
-func (haa *HistoryArchiveAdapter) GetLedger(sequence uint32) (io.ArchiveLedgerReader, error) {
-	return nil, fmt.Errorf("not implemented yet")
+// GetResumableState returns a reader with the state of the ledger at the
+// provided sequence number that saves progress to `checkpoints` every
+// `checkpointInterval` bucket entries and resumes from the saved checkpoint,
+// if any. Check io.MakeResumableSingleLedgerStateReader godoc for details.
+func (haa *HistoryArchiveAdapter) GetResumableState(
+	sequence uint32,
+	tempSet io.ResumableTempSet,
+	maxStreamRetries int,
+	checkpoints io.StateCheckpointStore,
+	checkpointInterval int,
+) (io.StateReader, error) {
+	exists, err := haa.archive.CategoryCheckpointExists("history", sequence)
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking if category checkpoint exists")
+	}
+	if !exists {
+		return nil, fmt.Errorf("history checkpoint does not exist for ledger %d", sequence)
+	}
+
+	sr, e := io.MakeResumableSingleLedgerStateReader(
+		haa.archive,
+		tempSet,
+		sequence,
+		maxStreamRetries,
+		checkpoints,
+		checkpointInterval,
+	)
+	if e != nil {
+		return nil, errors.Wrap(e, "could not make resumable state reader")
+	}
+
+	return sr, nil
+}
+
+// GetLedger returns a reader with the transactions and results of the
+// ledger at the provided sequence number, read from the checkpoint
+// containing it. `networkPassphrase` is used to match transactions with their
+// results. It returns io.ErrNotFound if the checkpoint or ledger does not exist
+// in the archive. Read io.ArchiveLedgerReader godoc to learn which data is not
+// available in history archives.
+func (haa *HistoryArchiveAdapter) GetLedger(sequence uint32, networkPassphrase string) (io.LedgerReader, error) {
+	checkpoint, err := haa.getCheckpoint(historyarchive.NextCheckpoint(sequence))
+	if err != nil {
+		return nil, err
+	}
+
+	header, ok := checkpoint.headers[sequence]
+	if !ok {
+		return nil, io.ErrNotFound
+	}
+
+	reader, err := io.NewArchiveLedgerReader(
+		networkPassphrase,
+		header,
+		checkpoint.txSets[sequence],
+		checkpoint.resultSets[sequence],
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading ledger %d", sequence)
+	}
+
+	return reader, nil
+}
+
+// getCheckpoint returns the data of the checkpoint ending at `sequence`,
+// from cache if it was the last one read.
+func (haa *HistoryArchiveAdapter) getCheckpoint(sequence uint32) (*archiveCheckpoint, error) {
+	haa.checkpointMutex.Lock()
+	defer haa.checkpointMutex.Unlock()
+
+	if haa.checkpoint != nil && haa.checkpoint.sequence == sequence {
+		return haa.checkpoint, nil
+	}
+
+	exists, err := haa.archive.CategoryCheckpointExists("ledger", sequence)
+	if err != nil {
+		return nil, errors.Wrap(err, "error checking if category checkpoint exists")
+	}
+	if !exists {
+		return nil, io.ErrNotFound
+	}
+
+	ctx := context.Background()
+	checkpoint := &archiveCheckpoint{
+		sequence:   sequence,
+		headers:    make(map[uint32]xdr.LedgerHeaderHistoryEntry),
+		txSets:     make(map[uint32]*xdr.TransactionSet),
+		resultSets: make(map[uint32]*xdr.TransactionResultSet),
+	}
+
+	headers, err := haa.archive.GetLedgerHeaderHistoryEntries(ctx, sequence)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading ledger headers")
+	}
+	for _, header := range headers {
+		checkpoint.headers[uint32(header.Header.LedgerSeq)] = header
+	}
+
+	transactions, err := haa.archive.GetTransactionHistoryEntries(ctx, sequence)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading transactions")
+	}
+	for i := range transactions {
+		checkpoint.txSets[uint32(transactions[i].LedgerSeq)] = &transactions[i].TxSet
+	}
+
+	results, err := haa.archive.GetTransactionHistoryResultEntries(ctx, sequence)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading transaction results")
+	}
+	for i := range results {
+		checkpoint.resultSets[uint32(results[i].LedgerSeq)] = &results[i].TxResultSet
+	}
+
+	haa.checkpoint = checkpoint
+	return checkpoint, nil
 }
@@ -6,8 +6,12 @@ import (
 	"testing"
 
 	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/network"
 	"github.com/stellar/go/support/historyarchive"
+	"github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // commented out this test for now
@@ -77,3 +81,61 @@ func getTestArchive() (*historyarchive.Archive, error) {
 		},
 	)
 }
+
+func TestGetLedger(t *testing.T) {
+	archive := &historyarchive.MockArchive{}
+	haa := MakeHistoryArchiveAdapter(archive)
+
+	envelope := xdr.TransactionEnvelope{
+		Tx: xdr.Transaction{
+			SourceAccount: xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB"),
+			Operations:    []xdr.Operation{{Body: xdr.OperationBody{Type: xdr.OperationTypeInflation}}},
+		},
+	}
+	hash, err := network.HashTransaction(&envelope.Tx, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+
+	archive.On("CategoryCheckpointExists", "ledger", uint32(127)).Return(true, nil).Once()
+	archive.On("GetLedgerHeaderHistoryEntries", mock.Anything, uint32(127)).Return(
+		[]xdr.LedgerHeaderHistoryEntry{
+			{Header: xdr.LedgerHeader{LedgerSeq: 64}},
+			{Header: xdr.LedgerHeader{LedgerSeq: 65}},
+		}, nil,
+	).Once()
+	archive.On("GetTransactionHistoryEntries", mock.Anything, uint32(127)).Return(
+		[]xdr.TransactionHistoryEntry{
+			{LedgerSeq: 65, TxSet: xdr.TransactionSet{Txs: []xdr.TransactionEnvelope{envelope}}},
+		}, nil,
+	).Once()
+	archive.On("GetTransactionHistoryResultEntries", mock.Anything, uint32(127)).Return(
+		[]xdr.TransactionHistoryResultEntry{
+			{LedgerSeq: 65, TxResultSet: xdr.TransactionResultSet{
+				Results: []xdr.TransactionResultPair{{TransactionHash: xdr.Hash(hash)}},
+			}},
+		}, nil,
+	).Once()
+	archive.On("CategoryCheckpointExists", "ledger", uint32(191)).Return(false, nil).Once()
+
+	reader, err := haa.GetLedger(65, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(65), reader.GetSequence())
+	tx, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, envelope, tx.Envelope)
+	_, err = reader.Read()
+	assert.Equal(t, stdio.EOF, err)
+
+	// The checkpoint is read only once.
+	reader, err = haa.GetLedger(64, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.Equal(t, stdio.EOF, err)
+
+	_, err = haa.GetLedger(66, network.TestNetworkPassphrase)
+	assert.Equal(t, io.ErrNotFound, err)
+
+	_, err = haa.GetLedger(128, network.TestNetworkPassphrase)
+	assert.Equal(t, io.ErrNotFound, err)
+
+	archive.AssertExpectations(t)
+}
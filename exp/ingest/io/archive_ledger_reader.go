@@ -1,9 +1,178 @@
 package io
 
-import "github.com/stellar/go/xdr"
+import (
+	"io"
+	"sync"
 
-// ArchiveLedgerReader placeholder
-type ArchiveLedgerReader interface {
-	GetSequence() uint32
-	Read() (bool, xdr.Transaction, xdr.TransactionResult, error)
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ArchiveLedgerReader is a history archive-backed implementation of the
+// io.LedgerReader interface. Use NewArchiveLedgerReader to create a new
+// instance.
+//
+// History archives contain ledger headers, transaction sets and results
+// only. Because of this, `FeeChanges` of transactions returned by Read are
+// empty and `Meta` has no changes. There are no upgrade changes either.
+type ArchiveLedgerReader struct {
+	sequence                uint32
+	header                  xdr.LedgerHeaderHistoryEntry
+	transactions            []LedgerTransaction
+	readMutex               sync.Mutex
+	readIdx                 int
+	readUpgradeChangeCalled bool
+	ignoreUpgradeChanges    bool
+}
+
+// Ensure ArchiveLedgerReader implements LedgerReader
+var _ LedgerReader = (*ArchiveLedgerReader)(nil)
+
+// NewArchiveLedgerReader is a factory method for ArchiveLedgerReader. It
+// pairs transactions from `txSet` with their results from `resultSet` using
+// transaction hashes, so `networkPassphrase` must be the passphrase of the
+// network the archive belongs to. `txSet` and `resultSet` can be nil for
+// ledgers without transactions.
+func NewArchiveLedgerReader(
+	networkPassphrase string,
+	header xdr.LedgerHeaderHistoryEntry,
+	txSet *xdr.TransactionSet,
+	resultSet *xdr.TransactionResultSet,
+) (*ArchiveLedgerReader, error) {
+	reader := &ArchiveLedgerReader{
+		sequence: uint32(header.Header.LedgerSeq),
+		header:   header,
+	}
+
+	err := reader.storeTransactions(networkPassphrase, txSet, resultSet)
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+// GetSequence returns the sequence number of the ledger data stored by this object.
+func (alr *ArchiveLedgerReader) GetSequence() uint32 {
+	return alr.sequence
+}
+
+// GetHeader returns the XDR Header data associated with the stored ledger.
+func (alr *ArchiveLedgerReader) GetHeader() xdr.LedgerHeaderHistoryEntry {
+	return alr.header
+}
+
+// Read returns the next transaction in the ledger, in the order they were
+// applied, each time it is called. When there are no more transactions to
+// return, an EOF error is returned.
+func (alr *ArchiveLedgerReader) Read() (LedgerTransaction, error) {
+	// Protect all accesses to alr.readIdx
+	alr.readMutex.Lock()
+	defer alr.readMutex.Unlock()
+
+	if alr.readIdx < len(alr.transactions) {
+		alr.readIdx++
+		return alr.transactions[alr.readIdx-1], nil
+	}
+	return LedgerTransaction{}, io.EOF
+}
+
+// ReadUpgradeChange always returns an EOF error because history archives
+// don't contain upgrade changes.
+func (alr *ArchiveLedgerReader) ReadUpgradeChange() (Change, error) {
+	alr.readMutex.Lock()
+	defer alr.readMutex.Unlock()
+	alr.readUpgradeChangeCalled = true
+
+	return Change{}, io.EOF
+}
+
+// GetUpgradeChanges returns all ledger upgrade changes, which is always an
+// empty slice for history archives.
+func (alr *ArchiveLedgerReader) GetUpgradeChanges() []Change {
+	return []Change{}
+}
+
+// IgnoreUpgradeChanges changes `Close()` behaviour to not error when
+// `ReadUpgradeChange` was not called.
+func (alr *ArchiveLedgerReader) IgnoreUpgradeChanges() {
+	alr.ignoreUpgradeChanges = true
+}
+
+// Close moves the read pointer so that subsequent calls to Read() will return EOF.
+func (alr *ArchiveLedgerReader) Close() error {
+	alr.readMutex.Lock()
+	defer alr.readMutex.Unlock()
+
+	alr.readIdx = len(alr.transactions)
+	if !alr.ignoreUpgradeChanges && !alr.readUpgradeChangeCalled {
+		return errors.New("Ledger upgrade changes not read! Use ReadUpgradeChange() method.")
+	}
+
+	return nil
+}
+
+// storeTransactions pairs transactions with their results. Results are stored
+// in archives in the order transactions were applied while transaction sets
+// are not, so the order of results is used.
+func (alr *ArchiveLedgerReader) storeTransactions(
+	networkPassphrase string,
+	txSet *xdr.TransactionSet,
+	resultSet *xdr.TransactionResultSet,
+) error {
+	var txs []xdr.TransactionEnvelope
+	if txSet != nil {
+		txs = txSet.Txs
+	}
+	var results []xdr.TransactionResultPair
+	if resultSet != nil {
+		results = resultSet.Results
+	}
+
+	if len(txs) != len(results) {
+		return errors.Errorf(
+			"number of transactions (%d) does not match number of results (%d) in ledger %d",
+			len(txs),
+			len(results),
+			alr.sequence,
+		)
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	if networkPassphrase == "" {
+		return errors.New("network passphrase is empty")
+	}
+
+	envelopes := make(map[xdr.Hash]xdr.TransactionEnvelope, len(txs))
+	for _, tx := range txs {
+		hash, err := network.HashTransaction(&tx.Tx, networkPassphrase)
+		if err != nil {
+			return errors.Wrap(err, "error hashing transaction")
+		}
+		envelopes[xdr.Hash(hash)] = tx
+	}
+
+	for i, result := range results {
+		envelope, ok := envelopes[result.TransactionHash]
+		if !ok {
+			return errors.Errorf(
+				"no transaction for result %x in ledger %d, wrong network passphrase?",
+				result.TransactionHash[:],
+				alr.sequence,
+			)
+		}
+
+		alr.transactions = append(alr.transactions, LedgerTransaction{
+			Index:    uint32(i + 1), // Transactions start at '1'
+			Envelope: envelope,
+			Result:   result,
+			// Archives don't contain meta. Use empty V1 meta so that
+			// LedgerTransaction.GetChanges() works.
+			Meta: xdr.TransactionMeta{V: 1, V1: &xdr.TransactionMetaV1{}},
+		})
+	}
+
+	return nil
 }
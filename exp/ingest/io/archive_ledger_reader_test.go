@@ -0,0 +1,93 @@
+package io
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeTestTransaction(t *testing.T, seq xdr.SequenceNumber) (xdr.TransactionEnvelope, xdr.TransactionResultPair) {
+	envelope := xdr.TransactionEnvelope{
+		Tx: xdr.Transaction{
+			SourceAccount: xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB"),
+			SeqNum:        seq,
+			Operations: []xdr.Operation{
+				{Body: xdr.OperationBody{Type: xdr.OperationTypeInflation}},
+			},
+		},
+	}
+	hash, err := network.HashTransaction(&envelope.Tx, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+
+	result := xdr.TransactionResultPair{
+		TransactionHash: xdr.Hash(hash),
+		Result: xdr.TransactionResult{
+			FeeCharged: 100,
+			Result:     xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxBadSeq},
+		},
+	}
+	return envelope, result
+}
+
+func TestArchiveLedgerReader(t *testing.T) {
+	tx1, result1 := makeTestTransaction(t, 1)
+	tx2, result2 := makeTestTransaction(t, 2)
+	header := xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 65}}
+
+	// Transactions are returned in the order of results.
+	reader, err := NewArchiveLedgerReader(
+		network.TestNetworkPassphrase,
+		header,
+		&xdr.TransactionSet{Txs: []xdr.TransactionEnvelope{tx1, tx2}},
+		&xdr.TransactionResultSet{Results: []xdr.TransactionResultPair{result2, result1}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(65), reader.GetSequence())
+	assert.Equal(t, header, reader.GetHeader())
+
+	tx, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), tx.Index)
+	assert.Equal(t, tx2, tx.Envelope)
+	assert.Equal(t, result2, tx.Result)
+	assert.Empty(t, tx.GetChanges())
+	assert.Empty(t, tx.GetFeeChanges())
+
+	tx, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), tx.Index)
+	assert.Equal(t, tx1, tx.Envelope)
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+
+	assert.EqualError(t, reader.Close(), "Ledger upgrade changes not read! Use ReadUpgradeChange() method.")
+	_, err = reader.ReadUpgradeChange()
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, reader.Close())
+}
+
+func TestArchiveLedgerReaderErrors(t *testing.T) {
+	tx1, result1 := makeTestTransaction(t, 1)
+	header := xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 65}}
+	txSet := &xdr.TransactionSet{Txs: []xdr.TransactionEnvelope{tx1}}
+	resultSet := &xdr.TransactionResultSet{Results: []xdr.TransactionResultPair{result1}}
+
+	reader, err := NewArchiveLedgerReader("", header, nil, nil)
+	require.NoError(t, err)
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewArchiveLedgerReader(network.TestNetworkPassphrase, header, txSet, nil)
+	assert.EqualError(t, err, "number of transactions (1) does not match number of results (0) in ledger 65")
+
+	_, err = NewArchiveLedgerReader("", header, txSet, resultSet)
+	assert.EqualError(t, err, "network passphrase is empty")
+
+	_, err = NewArchiveLedgerReader(network.PublicNetworkPassphrase, header, txSet, resultSet)
+	assert.Contains(t, err.Error(), "wrong network passphrase?")
+}
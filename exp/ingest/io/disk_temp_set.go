@@ -0,0 +1,427 @@
+package io
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const (
+	// diskTempSetMemtableSize defines the maximum number of keys kept in
+	// memory. When the number of keys exceeds it, keys are written to a new
+	// segment file.
+	// Change the value to lower: smaller memory requirements but slower.
+	// Change the value to higher: higher memory requirements but faster.
+	diskTempSetMemtableSize = 1000000
+	// diskTempSetMaxSegments defines the maximum number of segment files.
+	// When the number of segments exceeds it, all segments are merged into
+	// a single one.
+	diskTempSetMaxSegments = 8
+	// diskTempSetIndexInterval defines how often keys of a segment are added
+	// to the in-memory index. Checking a key reads at most that many keys
+	// from disk.
+	diskTempSetIndexInterval = 128
+	// diskTempSetBloomBitsPerKey and diskTempSetBloomHashes define bloom
+	// filter parameters. These values give ~1% false positive rate.
+	diskTempSetBloomBitsPerKey = 10
+	diskTempSetBloomHashes     = 7
+)
+
+// DiskTempSet is an on-disk implementation of TempSet interface. It's an
+// alternative to PostgresTempSet with similarly low memory requirements that
+// doesn't require a database.
+//
+// Keys are kept in memory until there are `diskTempSetMemtableSize` of them.
+// Then they are written, sorted, to a segment file in a temporary directory
+// created in `Dir` (or the default directory for temporary files if `Dir` is
+// empty). A bloom filter and a sparse index of every segment are kept in
+// memory so checking a key that is not in the set rarely reads from disk and
+// checking a key that is in the set reads a single block of a segment.
+// Segments are merged when there are more than `diskTempSetMaxSegments` of
+// them. The directory is removed when the store is closed.
+type DiskTempSet struct {
+	Dir string
+
+	// memtableSize and maxSegments can be changed in tests. Default
+	// values are used when 0.
+	memtableSize int
+	maxSegments  int
+
+	dir           string
+	memtable      map[string]bool
+	segments      []*diskTempSetSegment
+	nextSegmentID int
+}
+
+// Ensure DiskTempSet implements ResumableTempSet
+var _ ResumableTempSet = &DiskTempSet{}
+
+// diskTempSetSegment is a file with sorted, unique keys, one per line.
+type diskTempSetSegment struct {
+	file  *os.File
+	size  int64
+	count int
+	bloom bloomFilter
+	// indexKeys contains every diskTempSetIndexInterval-th key of the
+	// segment, starting with the first one. indexOffsets contains offsets of
+	// these keys in the file.
+	indexKeys    []string
+	indexOffsets []int64
+}
+
+// Open creates a temporary directory for segment files and initializes
+// internal data structures.
+func (s *DiskTempSet) Open() error {
+	if s.memtableSize == 0 {
+		s.memtableSize = diskTempSetMemtableSize
+	}
+	if s.maxSegments == 0 {
+		s.maxSegments = diskTempSetMaxSegments
+	}
+
+	var err error
+	s.dir, err = ioutil.TempDir(s.Dir, "exp-state-reader-")
+	if err != nil {
+		return errors.Wrap(err, "Error creating temporary directory")
+	}
+
+	s.memtable = make(map[string]bool)
+	s.segments = nil
+	s.nextSegmentID = 0
+	return nil
+}
+
+// Add adds a key to TempSet.
+func (s *DiskTempSet) Add(key string) error {
+	s.memtable[key] = true
+	if len(s.memtable) < s.memtableSize {
+		return nil
+	}
+
+	return s.flush()
+}
+
+// Preload does not do anything. Bloom filters and indexes of segments are
+// kept in memory so keys are checked without preloading.
+func (s *DiskTempSet) Preload(keys []string) error {
+	return nil
+}
+
+// Exist check if the key exists in a TempSet.
+func (s *DiskTempSet) Exist(key string) (bool, error) {
+	if s.memtable[key] {
+		return true, nil
+	}
+
+	for _, segment := range s.segments {
+		exist, err := segment.contains(key)
+		if err != nil {
+			return false, errors.Wrap(err, "Error reading segment")
+		}
+		if exist {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Save writes all keys in the set to `w`, one per line.
+func (s *DiskTempSet) Save(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	for key := range s.memtable {
+		if _, err := writer.WriteString(key + "\n"); err != nil {
+			return err
+		}
+	}
+
+	for _, segment := range s.segments {
+		_, err := io.Copy(writer, io.NewSectionReader(segment.file, 0, segment.size))
+		if err != nil {
+			return errors.Wrap(err, "Error copying segment")
+		}
+	}
+
+	return writer.Flush()
+}
+
+// Load adds keys written by Save to the set.
+func (s *DiskTempSet) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := s.Add(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Close closes segment files and removes the temporary directory.
+func (s *DiskTempSet) Close() error {
+	for _, segment := range s.segments {
+		segment.file.Close()
+	}
+	s.segments = nil
+	s.memtable = nil
+
+	if s.dir == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(s.dir)
+	s.dir = ""
+	if err != nil {
+		return errors.Wrap(err, "Error removing temporary directory")
+	}
+	return nil
+}
+
+// flush writes keys in memtable to a new segment and merges segments if
+// there are too many of them.
+func (s *DiskTempSet) flush() error {
+	keys := make([]string, 0, len(s.memtable))
+	for key := range s.memtable {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	i := 0
+	segment, err := s.writeSegment(len(keys), func() (string, bool, error) {
+		if i == len(keys) {
+			return "", false, nil
+		}
+		i++
+		return keys[i-1], true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.segments = append(s.segments, segment)
+	s.memtable = make(map[string]bool)
+
+	if len(s.segments) > s.maxSegments {
+		return s.merge()
+	}
+	return nil
+}
+
+// merge merges all segments into a single one.
+func (s *DiskTempSet) merge() error {
+	scanners := make([]*bufio.Scanner, 0, len(s.segments))
+	heads := make([]string, 0, len(s.segments))
+	count := 0
+	for _, segment := range s.segments {
+		scanner := bufio.NewScanner(io.NewSectionReader(segment.file, 0, segment.size))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return errors.Wrap(err, "Error reading segment")
+			}
+			continue
+		}
+		scanners = append(scanners, scanner)
+		heads = append(heads, scanner.Text())
+		count += segment.count
+	}
+
+	var last string
+	started := false
+	merged, err := s.writeSegment(count, func() (string, bool, error) {
+		for {
+			// Find the lowest key. The number of segments is small so a
+			// linear search is fine.
+			min := -1
+			for i := range scanners {
+				if scanners[i] != nil && (min == -1 || heads[i] < heads[min]) {
+					min = i
+				}
+			}
+			if min == -1 {
+				return "", false, nil
+			}
+
+			key := heads[min]
+			if scanners[min].Scan() {
+				heads[min] = scanners[min].Text()
+			} else {
+				if err := scanners[min].Err(); err != nil {
+					return "", false, errors.Wrap(err, "Error reading segment")
+				}
+				scanners[min] = nil
+			}
+
+			// Skip keys present in many segments.
+			if started && key == last {
+				continue
+			}
+			started = true
+			last = key
+			return key, true, nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	oldSegments := s.segments
+	s.segments = []*diskTempSetSegment{merged}
+	for _, segment := range oldSegments {
+		segment.file.Close()
+		if err := os.Remove(segment.file.Name()); err != nil {
+			return errors.Wrap(err, "Error removing segment")
+		}
+	}
+	return nil
+}
+
+// writeSegment writes sorted, unique keys returned by `next` to a new
+// segment file. `count` is the expected number of keys used to size the bloom
+// filter.
+func (s *DiskTempSet) writeSegment(count int, next func() (string, bool, error)) (*diskTempSetSegment, error) {
+	path := filepath.Join(s.dir, fmt.Sprintf("segment-%d", s.nextSegmentID))
+	s.nextSegmentID++
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating segment")
+	}
+
+	segment := &diskTempSetSegment{
+		file:  file,
+		bloom: newBloomFilter(count),
+	}
+
+	writer := bufio.NewWriter(file)
+	for {
+		key, ok, err := next()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		if segment.count%diskTempSetIndexInterval == 0 {
+			segment.indexKeys = append(segment.indexKeys, key)
+			segment.indexOffsets = append(segment.indexOffsets, segment.size)
+		}
+		segment.bloom.add(key)
+		segment.count++
+
+		n, err := writer.WriteString(key + "\n")
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "Error writing segment")
+		}
+		segment.size += int64(n)
+	}
+
+	if err = writer.Flush(); err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "Error writing segment")
+	}
+
+	return segment, nil
+}
+
+// contains checks if the segment contains `key`.
+func (s *diskTempSetSegment) contains(key string) (bool, error) {
+	if !s.bloom.mayContain(key) {
+		return false, nil
+	}
+
+	// Find the block that can contain the key: the key is greater than the
+	// first key of the block and lower than the first key of the next block.
+	i := sort.SearchStrings(s.indexKeys, key)
+	if i < len(s.indexKeys) && s.indexKeys[i] == key {
+		return true, nil
+	}
+	if i == 0 {
+		return false, nil
+	}
+
+	start := s.indexOffsets[i-1]
+	end := s.size
+	if i < len(s.indexOffsets) {
+		end = s.indexOffsets[i]
+	}
+
+	block := make([]byte, end-start)
+	_, err := s.file.ReadAt(block, start)
+	if err != nil {
+		return false, err
+	}
+
+	keyBytes := []byte(key)
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		if bytes.Equal(line, keyBytes) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// bloomFilter is a simple bloom filter for strings.
+type bloomFilter struct {
+	bits []uint64
+}
+
+func newBloomFilter(keys int) bloomFilter {
+	m := keys * diskTempSetBloomBitsPerKey
+	if m < 64 {
+		m = 64
+	}
+	return bloomFilter{bits: make([]uint64, (m+63)/64)}
+}
+
+func (b bloomFilter) add(key string) {
+	h1, h2 := bloomHashes(key)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < diskTempSetBloomHashes; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b bloomFilter) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < diskTempSetBloomHashes; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes returns two hashes of `key` (FNV-1a and FNV-1) used to
+// derive bloom filter hashes using double hashing.
+func bloomHashes(key string) (uint64, uint64) {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h1 := uint64(offset64)
+	h2 := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h1 ^= uint64(key[i])
+		h1 *= prime64
+
+		h2 *= prime64
+		h2 ^= uint64(key[i])
+	}
+
+	// Make sure h2 is not 0, otherwise all derived hashes are equal.
+	return h1, h2 | 1
+}
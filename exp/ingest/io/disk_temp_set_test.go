@@ -0,0 +1,111 @@
+package io
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskTempSet(t *testing.T) {
+	s := DiskTempSet{}
+	err := s.Open()
+	assert.NoError(t, err)
+	assert.NotNil(t, s.memtable)
+	dir := s.dir
+	assert.DirExists(t, dir)
+
+	err = s.Add("a")
+	assert.NoError(t, err)
+
+	err = s.Add("b")
+	assert.NoError(t, err)
+
+	v, err := s.Exist("a")
+	assert.NoError(t, err)
+	assert.True(t, v)
+
+	v, err = s.Exist("b")
+	assert.NoError(t, err)
+	assert.True(t, v)
+
+	// Get for not-set key should return false
+	v, err = s.Exist("c")
+	assert.NoError(t, err)
+	assert.False(t, v)
+
+	err = s.Close()
+	assert.NoError(t, err)
+	assert.Nil(t, s.memtable)
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDiskTempSetSegments(t *testing.T) {
+	parent, err := ioutil.TempDir("", "disk-temp-set")
+	assert.NoError(t, err)
+	defer os.RemoveAll(parent)
+
+	s := DiskTempSet{Dir: parent, memtableSize: 100, maxSegments: 3}
+	assert.NoError(t, s.Open())
+
+	// Add keys in random order, some of them twice so they end up in many
+	// segments.
+	keys := rand.Perm(1000)
+	keys = append(keys, keys[:300]...)
+	for _, i := range keys {
+		assert.NoError(t, s.Add(fmt.Sprintf("key-%d", i)))
+	}
+	assert.True(t, len(s.segments) > 0)
+	assert.True(t, len(s.segments) <= 3)
+
+	for i := 0; i < 1000; i++ {
+		v, err := s.Exist(fmt.Sprintf("key-%d", i))
+		assert.NoError(t, err)
+		assert.True(t, v, "key-%d", i)
+
+		v, err = s.Exist(fmt.Sprintf("missing-%d", i))
+		assert.NoError(t, err)
+		assert.False(t, v, "missing-%d", i)
+	}
+
+	// Save and load to a new set
+	var buf bytes.Buffer
+	assert.NoError(t, s.Save(&buf))
+	assert.NoError(t, s.Close())
+
+	loaded := DiskTempSet{Dir: parent, memtableSize: 100, maxSegments: 3}
+	assert.NoError(t, loaded.Open())
+	assert.NoError(t, loaded.Load(&buf))
+	for i := 0; i < 1000; i++ {
+		v, err := loaded.Exist(fmt.Sprintf("key-%d", i))
+		assert.NoError(t, err)
+		assert.True(t, v, "key-%d", i)
+	}
+	assert.NoError(t, loaded.Close())
+
+	files, err := ioutil.ReadDir(parent)
+	assert.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestBloomFilter(t *testing.T) {
+	b := newBloomFilter(1000)
+	for i := 0; i < 1000; i++ {
+		b.add(fmt.Sprintf("key-%d", i))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		assert.True(t, b.mayContain(fmt.Sprintf("key-%d", i)))
+		if b.mayContain(fmt.Sprintf("missing-%d", i)) {
+			falsePositives++
+		}
+	}
+	// ~1% expected
+	assert.True(t, falsePositives < 50, "false positives: %d", falsePositives)
+}
@@ -1,5 +1,10 @@
 package io
 
+import (
+	"bufio"
+	"io"
+)
+
 // MemoryTempSet is an in-memory implementation of TempSet interface.
 // As of July 2019 this requires up to ~4GB of memory for pubnet ledger
 // state processing. The internal structure is dereferenced after the
@@ -8,6 +13,9 @@ type MemoryTempSet struct {
 	m map[string]bool
 }
 
+// Ensure MemoryTempSet implements ResumableTempSet
+var _ ResumableTempSet = &MemoryTempSet{}
+
 // Open initialize internals data structure.
 func (s *MemoryTempSet) Open() error {
 	s.m = make(map[string]bool)
@@ -31,6 +39,26 @@ func (s *MemoryTempSet) Exist(key string) (bool, error) {
 	return s.m[key], nil
 }
 
+// Save writes all keys in the set to `w`, one per line.
+func (s *MemoryTempSet) Save(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	for key := range s.m {
+		if _, err := writer.WriteString(key + "\n"); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Load adds keys written by Save to the set.
+func (s *MemoryTempSet) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s.m[scanner.Text()] = true
+	}
+	return scanner.Err()
+}
+
 // Close removes reference to internal data structure.
 func (s *MemoryTempSet) Close() error {
 	s.m = nil
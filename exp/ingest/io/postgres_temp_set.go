@@ -29,6 +29,10 @@ const (
 // to achieve better speed at the cost of higher memory usage.
 // If `DSN` is passed, a new `db.Session` will be created.
 // If `Session` is passed, it will be cloned.
+// PostgresTempSet does not implement ResumableTempSet and cannot be used to
+// resume state ingestion from a StateCheckpoint: saving it would dump all the
+// keys in its table on every checkpoint, which is as slow as starting over
+// for large states. Use DiskTempSet instead.
 type PostgresTempSet struct {
 	DSN     string
 	Session *db.Session
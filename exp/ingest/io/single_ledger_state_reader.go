@@ -1,8 +1,11 @@
 package io
 
 import (
+	"crypto/sha256"
+	"encoding"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"io"
 	"sync"
 
@@ -15,6 +18,8 @@ import (
 type readResult struct {
 	entryChange xdr.LedgerEntryChange
 	e           error
+	// checkpoint is set at the end of a segment, see ResumableStateReader.
+	checkpoint *StateCheckpoint
 }
 
 // SingleLedgerStateReader is a streaming implementation that reads ledger entries
@@ -35,12 +40,34 @@ type SingleLedgerStateReader struct {
 	// the xdr stream returned by GetXdrStreamForHash()
 	maxStreamRetries int
 
+	// checkpoints is used to save progress every `checkpointInterval` bucket
+	// entries. Set in readers created by MakeResumableSingleLedgerStateReader
+	// only.
+	checkpoints            StateCheckpointStore
+	checkpointInterval     int
+	checkpointSaved        chan bool
+	entriesSinceCheckpoint int
+	resumableTempStore     ResumableTempSet
+	// resumeFrom is the checkpoint the reader resumes from or nil.
+	resumeFrom *StateCheckpoint
+	// pendingCheckpoint is the checkpoint at the end of the current segment,
+	// set when `Read()` reaches it and saved by SaveCheckpoint.
+	pendingCheckpoint *StateCheckpoint
+	// entriesRead and outputHash are updated in `Read()` in resumable
+	// readers. readMutex makes `Read()` calls sequential in such readers so
+	// the output hash is deterministic.
+	readMutex   sync.Mutex
+	entriesRead uint64
+	outputHash  hash.Hash
+
 	// This should be set to true in tests only
 	disableBucketListHashValidation bool
 }
 
-// Ensure SingleLedgerStateReader implements StateReader
+// Ensure SingleLedgerStateReader implements StateReader and
+// ResumableStateReader
 var _ StateReader = &SingleLedgerStateReader{}
+var _ ResumableStateReader = &SingleLedgerStateReader{}
 
 // TempSet is an interface that must be implemented by stores that
 // hold temporary set of objects for state reader. The implementation
@@ -98,6 +125,64 @@ func MakeSingleLedgerStateReader(
 	}, nil
 }
 
+// MakeResumableSingleLedgerStateReader is a factory method for
+// SingleLedgerStateReader that splits the state into segments of
+// `checkpointInterval` bucket entries, see ResumableStateReader. `Read()`
+// returns io.EOF at the end of every segment and SaveCheckpoint saves a
+// StateCheckpoint to `checkpoints`. If a checkpoint for `sequence` exists in
+// `checkpoints`, `tempStore` contents are restored and the reader resumes
+// reading buckets from the checkpoint.
+//
+// A checkpoint is saved only when SaveCheckpoint is called so entries read
+// but not yet processed (ex. waiting in pipeline buffers) are never skipped
+// after resuming. Ledger entries returned by `Read()` after the last saved
+// checkpoint will be returned again after resuming so state processors must
+// be able to process the same entry again (ex. by using upserts). Remove the
+// checkpoint when the state has been fully processed.
+//
+// The reader pauses streaming at the end of every segment so checkpoints
+// should not be saved too often.
+func MakeResumableSingleLedgerStateReader(
+	archive historyarchive.ArchiveInterface,
+	tempStore ResumableTempSet,
+	sequence uint32,
+	maxStreamRetries int,
+	checkpoints StateCheckpointStore,
+	checkpointInterval int,
+) (*SingleLedgerStateReader, error) {
+	if checkpointInterval <= 0 {
+		return nil, errors.New("checkpoint interval must be positive")
+	}
+
+	msr, err := MakeSingleLedgerStateReader(archive, tempStore, sequence, maxStreamRetries)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint, err := checkpoints.Load(sequence, tempStore)
+	if err != nil {
+		tempStore.Close()
+		return nil, errors.Wrap(err, "unable to load checkpoint")
+	}
+
+	msr.outputHash = sha256.New()
+	if checkpoint != nil {
+		err = msr.outputHash.(encoding.BinaryUnmarshaler).UnmarshalBinary(checkpoint.OutputHashState)
+		if err != nil {
+			tempStore.Close()
+			return nil, errors.Wrap(err, "unable to restore output hash")
+		}
+		msr.entriesRead = checkpoint.EntriesRead
+	}
+
+	msr.checkpoints = checkpoints
+	msr.checkpointInterval = checkpointInterval
+	msr.checkpointSaved = make(chan bool, 1)
+	msr.resumableTempStore = tempStore
+	msr.resumeFrom = checkpoint
+	return msr, nil
+}
+
 // streamBuckets is internal method that streams buckets from the given HAS.
 //
 // Buckets should be processed from oldest to newest, `snap` and then `curr` at
@@ -138,13 +223,32 @@ func (msr *SingleLedgerStateReader) streamBuckets() {
 		buckets = append(buckets, b.Curr, b.Snap)
 	}
 
-	for _, hashString := range buckets {
+	for i, hashString := range buckets {
+		// Buckets before the checkpoint have been processed.
+		if msr.resumeFrom != nil && i < msr.resumeFrom.BucketIndex {
+			continue
+		}
+
 		hash, err := historyarchive.DecodeHash(hashString)
 		if err != nil {
 			msr.readChan <- msr.error(errors.Wrap(err, "Error decoding bucket hash"))
 			return
 		}
 
+		skip := 0
+		if msr.resumeFrom != nil && i == msr.resumeFrom.BucketIndex {
+			if hash.String() != msr.resumeFrom.BucketHash {
+				msr.readChan <- msr.error(fmt.Errorf(
+					"checkpoint bucket hash (%s) does not match bucket list hash (%s) at index %d",
+					msr.resumeFrom.BucketHash,
+					hash.String(),
+					i,
+				))
+				return
+			}
+			skip = msr.resumeFrom.EntryOffset
+		}
+
 		if hash.IsZero() {
 			continue
 		}
@@ -160,7 +264,7 @@ func (msr *SingleLedgerStateReader) streamBuckets() {
 			return
 		}
 
-		if shouldContinue := msr.streamBucketContents(hash); !shouldContinue {
+		if shouldContinue := msr.streamBucketContents(hash, i, skip); !shouldContinue {
 			break
 		}
 	}
@@ -224,8 +328,11 @@ func (msr *SingleLedgerStateReader) newXDRStream(hash historyarchive.Hash) (
 	return rdr, e
 }
 
-// streamBucketContents pushes value onto the read channel, returning false when the channel needs to be closed otherwise true
-func (msr *SingleLedgerStateReader) streamBucketContents(hash historyarchive.Hash) bool {
+// streamBucketContents pushes value onto the read channel, returning false when the channel needs to be closed otherwise true.
+// `bucketIndex` is the index of the bucket in the bucket list and `skip` is
+// the number of entries at the beginning of the bucket that have been
+// processed before the checkpoint the reader resumes from.
+func (msr *SingleLedgerStateReader) streamBucketContents(hash historyarchive.Hash, bucketIndex, skip int) bool {
 	rdr, e := msr.newXDRStream(hash)
 	if e != nil {
 		msr.readChan <- msr.error(fmt.Errorf("cannot get xdr stream for hash '%s': %s", hash.String(), e))
@@ -314,6 +421,15 @@ LoopBucketEntry:
 
 		n++
 
+		if n < skip {
+			// Entry processed before the checkpoint. METAENTRY is still needed
+			// to check the bucket protocol version.
+			if entry.Type == xdr.BucketEntryTypeMetaentry {
+				bucketProtocolVersion = uint32(entry.MetaEntry.LedgerVersion)
+			}
+			continue LoopBucketEntry
+		}
+
 		var key xdr.LedgerKey
 
 		switch entry.Type {
@@ -365,7 +481,7 @@ LoopBucketEntry:
 					Type:  xdr.LedgerEntryChangeTypeLedgerEntryState,
 					State: &liveEntry,
 				}
-				msr.readChan <- readResult{entryChange, nil}
+				msr.readChan <- readResult{entryChange: entryChange}
 
 				// We don't update `tempStore` for INITENTRY because CAP-20 says:
 				// > a bucket entry marked INITENTRY implies that either no entry
@@ -391,6 +507,16 @@ LoopBucketEntry:
 			return false
 		}
 
+		if msr.checkpoints != nil {
+			msr.entriesSinceCheckpoint++
+			if msr.entriesSinceCheckpoint >= msr.checkpointInterval {
+				msr.entriesSinceCheckpoint = 0
+				if !msr.sendCheckpoint(bucketIndex, hash, n+1) {
+					return false
+				}
+			}
+		}
+
 		select {
 		case <-msr.done:
 			// Close() called: stop processing buckets.
@@ -403,6 +529,46 @@ LoopBucketEntry:
 	panic("Shouldn't happen")
 }
 
+// sendCheckpoint sends a checkpoint ending the current segment to `Read()`
+// and waits until it's saved so that `tempStore` is not modified while
+// saving. Returns false if the reader has been closed.
+func (msr *SingleLedgerStateReader) sendCheckpoint(bucketIndex int, hash historyarchive.Hash, entryOffset int) bool {
+	msr.readChan <- readResult{checkpoint: &StateCheckpoint{
+		LedgerSequence: msr.sequence,
+		BucketIndex:    bucketIndex,
+		BucketHash:     hash.String(),
+		EntryOffset:    entryOffset,
+	}}
+
+	select {
+	case <-msr.checkpointSaved:
+		return true
+	case <-msr.done:
+		return false
+	}
+}
+
+// saveCheckpoint saves `checkpoint` with the current output hash and
+// `tempStore` contents and resumes streaming. All entries before the
+// checkpoint have been returned by `Read()` when it's called.
+func (msr *SingleLedgerStateReader) saveCheckpoint(checkpoint StateCheckpoint) error {
+	hashState, err := msr.outputHash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling output hash")
+	}
+
+	checkpoint.EntriesRead = msr.entriesRead
+	checkpoint.OutputHashState = hashState
+
+	err = msr.checkpoints.Save(checkpoint, msr.resumableTempStore)
+	if err != nil {
+		return err
+	}
+
+	msr.checkpointSaved <- true
+	return nil
+}
+
 // GetSequence impl.
 func (msr *SingleLedgerStateReader) GetSequence() uint32 {
 	return msr.sequence
@@ -414,21 +580,94 @@ func (msr *SingleLedgerStateReader) Read() (xdr.LedgerEntryChange, error) {
 		go msr.streamBuckets()
 	})
 
-	// blocking call. anytime we consume from this channel, the background goroutine will stream in the next value
-	result, ok := <-msr.readChan
-	if !ok {
-		// when channel is closed then return io.EOF
-		return xdr.LedgerEntryChange{}, io.EOF
+	if msr.checkpoints != nil {
+		msr.readMutex.Lock()
+		defer msr.readMutex.Unlock()
+
+		if msr.pendingCheckpoint != nil {
+			// End of the segment, waiting for SaveCheckpoint.
+			return xdr.LedgerEntryChange{}, io.EOF
+		}
 	}
 
-	if result.e != nil {
-		return xdr.LedgerEntryChange{}, errors.Wrap(result.e, "Error while reading from buckets")
+	for {
+		// blocking call. anytime we consume from this channel, the background goroutine will stream in the next value
+		result, ok := <-msr.readChan
+		if !ok {
+			// when channel is closed then return io.EOF
+			return xdr.LedgerEntryChange{}, io.EOF
+		}
+
+		if result.e != nil {
+			return xdr.LedgerEntryChange{}, errors.Wrap(result.e, "Error while reading from buckets")
+		}
+
+		if result.checkpoint != nil {
+			msr.pendingCheckpoint = result.checkpoint
+			return xdr.LedgerEntryChange{}, io.EOF
+		}
+
+		if msr.outputHash != nil {
+			entryBytes, err := result.entryChange.MarshalBinary()
+			if err != nil {
+				return xdr.LedgerEntryChange{}, errors.Wrap(err, "Error marshaling entry")
+			}
+			msr.outputHash.Write(entryBytes)
+			msr.entriesRead++
+		}
+
+		return result.entryChange, nil
+	}
+}
+
+// SaveCheckpoint implements ResumableStateReader. It returns false (and
+// does nothing) in readers created by MakeSingleLedgerStateReader which do not
+// split the state into segments.
+func (msr *SingleLedgerStateReader) SaveCheckpoint() (bool, error) {
+	if msr.checkpoints == nil {
+		return false, nil
 	}
-	return result.entryChange, nil
+
+	msr.readMutex.Lock()
+	defer msr.readMutex.Unlock()
+
+	if msr.pendingCheckpoint == nil {
+		return false, nil
+	}
+
+	err := msr.saveCheckpoint(*msr.pendingCheckpoint)
+	if err != nil {
+		// Stop streaming, the background goroutine is waiting for the
+		// checkpoint to be saved.
+		msr.closeOnce.Do(msr.close)
+		return false, errors.Wrap(err, "Error saving checkpoint")
+	}
+
+	msr.pendingCheckpoint = nil
+	return true, nil
+}
+
+// OutputHash returns SHA-256 hash of XDR encoded ledger entry changes
+// returned by `Read()`, including changes returned before the checkpoint the
+// reader resumed from. Compare hashes of a fresh run and a resumed run, after
+// `Read()` returned io.EOF, to verify that resuming produced the same output.
+// It's available in readers created by MakeResumableSingleLedgerStateReader
+// only.
+func (msr *SingleLedgerStateReader) OutputHash() (xdr.Hash, error) {
+	if msr.outputHash == nil {
+		return xdr.Hash{}, errors.New("output hash is available in resumable readers only")
+	}
+
+	msr.readMutex.Lock()
+	defer msr.readMutex.Unlock()
+
+	var result xdr.Hash
+	copy(result[:], msr.outputHash.Sum(nil))
+	return result, nil
 }
 
 func (msr *SingleLedgerStateReader) error(err error) readResult {
-	return readResult{xdr.LedgerEntryChange{}, err}
+	return readResult{e: err}
 }
 
 func (msr *SingleLedgerStateReader) close() {
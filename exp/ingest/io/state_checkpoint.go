@@ -0,0 +1,159 @@
+package io
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/files"
+)
+
+// StateCheckpoint describes the progress of reading ledger entries from
+// buckets by SingleLedgerStateReader. All bucket entries before the position
+// described by `BucketIndex` and `EntryOffset` have been processed and all
+// ledger entries from these bucket entries have been returned by `Read()`.
+type StateCheckpoint struct {
+	// LedgerSequence is the checkpoint ledger of the state.
+	LedgerSequence uint32 `json:"ledger_sequence"`
+	// BucketIndex is the index of the bucket in the bucket list, in the order
+	// buckets are read (`curr` and `snap` at each level, empty buckets
+	// included).
+	BucketIndex int `json:"bucket_index"`
+	// BucketHash is the hash of the bucket at BucketIndex.
+	BucketHash string `json:"bucket_hash"`
+	// EntryOffset is the number of bucket entries of the bucket at
+	// BucketIndex that have been processed.
+	EntryOffset int `json:"entry_offset"`
+	// EntriesRead is the number of ledger entries returned by `Read()`.
+	EntriesRead uint64 `json:"entries_read"`
+	// OutputHashState is the internal state of the hash of ledger entries
+	// returned by `Read()`. See SingleLedgerStateReader.OutputHash.
+	OutputHashState []byte `json:"output_hash_state"`
+}
+
+// StateCheckpointStore persists state checkpoints together with contents of
+// the temp set at the time of the checkpoint.
+type StateCheckpointStore interface {
+	// Load returns the checkpoint saved for `sequence` ledger and restores
+	// `tempSet` contents. It returns nil if there is no checkpoint.
+	Load(sequence uint32, tempSet ResumableTempSet) (*StateCheckpoint, error)
+	// Save saves `checkpoint` and `tempSet` contents replacing the previous
+	// checkpoint for the same ledger. Save must be atomic: if it fails, the
+	// previous checkpoint must be preserved.
+	Save(checkpoint StateCheckpoint, tempSet ResumableTempSet) error
+	// Remove removes the checkpoint saved for `sequence` ledger, if any.
+	Remove(sequence uint32) error
+}
+
+// ResumableStateReader is a StateReader that saves StateCheckpoints. The state
+// is split into segments ending at checkpoints: `Read()` returns io.EOF at the
+// end of every segment, not only at the end of the state.
+type ResumableStateReader interface {
+	StateReader
+	// SaveCheckpoint saves the checkpoint at the end of the current segment
+	// and starts the next segment. It must be called after `Read()` returned
+	// io.EOF and all entries of the segment have been processed: entries
+	// before the checkpoint are not returned again after resuming. Returns
+	// false if the whole state has been read.
+	SaveCheckpoint() (bool, error)
+}
+
+// ResumableTempSet is a TempSet which contents can be saved and restored so
+// state ingestion can be resumed from a StateCheckpoint.
+type ResumableTempSet interface {
+	TempSet
+	// Save writes all keys in the set to `w`.
+	Save(w io.Writer) error
+	// Load adds keys written by Save to the set. It's called after Open.
+	Load(r io.Reader) error
+}
+
+// FileStateCheckpointStore is a StateCheckpointStore that saves checkpoints
+// to files in `Dir` directory. A single file contains both the checkpoint
+// and temp set contents so checkpoints are always consistent.
+type FileStateCheckpointStore struct {
+	Dir string
+}
+
+// Ensure FileStateCheckpointStore implements StateCheckpointStore
+var _ StateCheckpointStore = &FileStateCheckpointStore{}
+
+// Load returns the checkpoint saved for `sequence` ledger and restores
+// `tempSet` contents.
+func (s *FileStateCheckpointStore) Load(sequence uint32, tempSet ResumableTempSet) (*StateCheckpoint, error) {
+	file, err := os.Open(s.path(sequence))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Error opening checkpoint file")
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading checkpoint")
+	}
+
+	var checkpoint StateCheckpoint
+	err = json.Unmarshal(line, &checkpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error unmarshaling checkpoint")
+	}
+
+	if checkpoint.LedgerSequence != sequence {
+		return nil, errors.Errorf(
+			"Checkpoint ledger (%d) does not match requested ledger (%d)",
+			checkpoint.LedgerSequence,
+			sequence,
+		)
+	}
+
+	err = tempSet.Load(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error loading temp set")
+	}
+
+	return &checkpoint, nil
+}
+
+// Save saves `checkpoint` and `tempSet` contents. A failed Save does not
+// overwrite the previous checkpoint, see files.WriteAtomic.
+func (s *FileStateCheckpointStore) Save(checkpoint StateCheckpoint, tempSet ResumableTempSet) error {
+	line, err := json.Marshal(checkpoint)
+	if err != nil {
+		return errors.Wrap(err, "Error marshaling checkpoint")
+	}
+
+	return files.WriteAtomic(s.path(checkpoint.LedgerSequence), 0600, func(w io.Writer) error {
+		writer := bufio.NewWriter(w)
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			return errors.Wrap(err, "Error writing checkpoint")
+		}
+		if err := tempSet.Save(writer); err != nil {
+			return errors.Wrap(err, "Error saving temp set")
+		}
+		if err := writer.Flush(); err != nil {
+			return errors.Wrap(err, "Error writing checkpoint")
+		}
+		return nil
+	})
+}
+
+// Remove removes the checkpoint saved for `sequence` ledger, if any.
+func (s *FileStateCheckpointStore) Remove(sequence uint32) error {
+	err := os.Remove(s.path(sequence))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Error removing checkpoint file")
+	}
+	return nil
+}
+
+func (s *FileStateCheckpointStore) path(sequence uint32) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("state-%d.checkpoint", sequence))
+}
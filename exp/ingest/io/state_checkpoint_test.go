@@ -0,0 +1,227 @@
+package io
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/support/historyarchive"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	checkpointTestAccountA = "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML"
+	checkpointTestAccountB = "GDHMYFIH3QO524UVSUOCSCEI6CK23OIEJUNXHVUW43PQMXLIHPKPZJ2V"
+	checkpointTestAccountC = "GBVXTRL6NIEVEOJIFQCIDDUWCEXT6A5EAAN2S6SWJQRYKKR7D2S7YLBA"
+	checkpointTestAccountD = "GDNNXUMEULKSN4PL3VOAN7NNSNM3EKDVTNGX66OWM2E7UJKKVWCUN3GZ"
+)
+
+// newCheckpointTestArchive returns a mock archive with two non-empty buckets:
+// the first one contains A, dead B and C and the second one B, older A and D.
+// The state consists of A, C and D.
+func newCheckpointTestArchive(has historyarchive.HistoryArchiveState) *historyarchive.MockArchive {
+	mockArchive := &historyarchive.MockArchive{}
+	mockArchive.On("GetCheckpointHAS", uint32(24123007)).Return(has, nil)
+	mockArchive.On("BucketExists", mock.AnythingOfType("historyarchive.Hash")).Return(true, nil)
+
+	buckets := [][]xdr.BucketEntry{
+		{
+			metaEntry(11),
+			entryAccount(xdr.BucketEntryTypeLiveentry, checkpointTestAccountA, 2),
+			entryAccount(xdr.BucketEntryTypeDeadentry, checkpointTestAccountB, 0),
+			entryAccount(xdr.BucketEntryTypeLiveentry, checkpointTestAccountC, 3),
+		},
+		{
+			metaEntry(11),
+			entryAccount(xdr.BucketEntryTypeLiveentry, checkpointTestAccountB, 1),
+			entryAccount(xdr.BucketEntryTypeLiveentry, checkpointTestAccountA, 1),
+			entryAccount(xdr.BucketEntryTypeLiveentry, checkpointTestAccountD, 4),
+		},
+	}
+
+	i := 0
+	for _, level := range has.CurrentBuckets {
+		for _, hashString := range []string{level.Curr, level.Snap} {
+			hash := historyarchive.MustDecodeHash(hashString)
+			if hash.IsZero() {
+				continue
+			}
+
+			var entries []xdr.BucketEntry
+			if i < len(buckets) {
+				entries = buckets[i]
+			}
+			mockArchive.On("GetXdrStreamForHash", hash).Return(createXdrStream(entries...), nil).Once()
+			i++
+		}
+	}
+
+	return mockArchive
+}
+
+func newCheckpointTestReader(
+	t *testing.T,
+	checkpoints StateCheckpointStore,
+) *SingleLedgerStateReader {
+	var has historyarchive.HistoryArchiveState
+	err := json.Unmarshal([]byte(hasExample), &has)
+	assert.NoError(t, err)
+
+	reader, err := MakeResumableSingleLedgerStateReader(
+		newCheckpointTestArchive(has),
+		&MemoryTempSet{},
+		24123007,
+		0,
+		checkpoints,
+		2,
+	)
+	assert.NoError(t, err)
+	reader.disableBucketListHashValidation = true
+	return reader
+}
+
+// readAccounts reads accounts until the end of the current segment.
+func readAccounts(t *testing.T, reader *SingleLedgerStateReader) []string {
+	var accounts []string
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		id := change.State.Data.MustAccount().AccountId
+		accounts = append(accounts, id.Address())
+	}
+	return accounts
+}
+
+// readAllAccounts reads accounts from all segments, saving checkpoints.
+func readAllAccounts(t *testing.T, reader *SingleLedgerStateReader) []string {
+	var accounts []string
+	for {
+		accounts = append(accounts, readAccounts(t, reader)...)
+		more, err := reader.SaveCheckpoint()
+		assert.NoError(t, err)
+		if !more {
+			return accounts
+		}
+	}
+}
+
+func TestResumableStateReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state-checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	checkpoints := &FileStateCheckpointStore{Dir: dir}
+
+	// Fresh run
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "fresh"), 0755))
+	reader := newCheckpointTestReader(t, &FileStateCheckpointStore{Dir: filepath.Join(dir, "fresh")})
+	assert.Equal(t,
+		[]string{checkpointTestAccountA, checkpointTestAccountC, checkpointTestAccountD},
+		readAllAccounts(t, reader),
+	)
+	freshHash, err := reader.OutputHash()
+	assert.NoError(t, err)
+
+	// Interrupted run: the first segment ends after A and dead B.
+	reader = newCheckpointTestReader(t, checkpoints)
+	assert.Equal(t, []string{checkpointTestAccountA}, readAccounts(t, reader))
+
+	// The checkpoint is not saved until the segment is processed.
+	tempSet := &MemoryTempSet{}
+	assert.NoError(t, tempSet.Open())
+	checkpoint, err := checkpoints.Load(24123007, tempSet)
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+	// Read returns io.EOF until the checkpoint is saved
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+
+	more, err := reader.SaveCheckpoint()
+	assert.NoError(t, err)
+	assert.True(t, more)
+	assert.Equal(t, []string{checkpointTestAccountC}, readAccounts(t, reader))
+	assert.NoError(t, reader.Close())
+
+	checkpoint, err = checkpoints.Load(24123007, tempSet)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, checkpoint.BucketIndex)
+	assert.Equal(t, 3, checkpoint.EntryOffset)
+	assert.Equal(t, uint64(1), checkpoint.EntriesRead)
+	assert.Len(t, tempSet.m, 2)
+
+	// Resumed run returns entries after the checkpoint only
+	reader = newCheckpointTestReader(t, checkpoints)
+	assert.Equal(t,
+		[]string{checkpointTestAccountC, checkpointTestAccountD},
+		readAllAccounts(t, reader),
+	)
+	resumedHash, err := reader.OutputHash()
+	assert.NoError(t, err)
+	assert.Equal(t, freshHash, resumedHash)
+
+	assert.NoError(t, checkpoints.Remove(24123007))
+	assert.NoError(t, checkpoints.Remove(24123007))
+	checkpoint, err = checkpoints.Load(24123007, tempSet)
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+}
+
+func TestResumableStateReaderBucketHashMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state-checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	checkpoints := &FileStateCheckpointStore{Dir: dir}
+
+	tempSet := &MemoryTempSet{}
+	assert.NoError(t, tempSet.Open())
+	hashState, err := sha256.New().(encoding.BinaryMarshaler).MarshalBinary()
+	assert.NoError(t, err)
+	err = checkpoints.Save(StateCheckpoint{
+		LedgerSequence:  24123007,
+		BucketIndex:     0,
+		BucketHash:      "0000000000000000000000000000000000000000000000000000000000000001",
+		EntryOffset:     3,
+		OutputHashState: hashState,
+	}, tempSet)
+	assert.NoError(t, err)
+
+	reader := newCheckpointTestReader(t, checkpoints)
+	_, err = reader.Read()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "checkpoint bucket hash (0000000000000000000000000000000000000000000000000000000000000001) does not match bucket list hash")
+}
+
+func TestMemoryTempSetSaveLoad(t *testing.T) {
+	s := MemoryTempSet{}
+	assert.NoError(t, s.Open())
+	assert.NoError(t, s.Add("a"))
+	assert.NoError(t, s.Add("b"))
+
+	dir, err := ioutil.TempDir("", "state-checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	checkpoints := &FileStateCheckpointStore{Dir: dir}
+
+	err = checkpoints.Save(StateCheckpoint{LedgerSequence: 63, EntriesRead: 10}, &s)
+	assert.NoError(t, err)
+
+	loaded := MemoryTempSet{}
+	assert.NoError(t, loaded.Open())
+	checkpoint, err := checkpoints.Load(63, &loaded)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), checkpoint.EntriesRead)
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, loaded.m)
+
+	checkpoint, err = checkpoints.Load(127, &loaded)
+	assert.NoError(t, err)
+	assert.Nil(t, checkpoint)
+}
@@ -0,0 +1,239 @@
+package ledgerbackend
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/historyarchive"
+)
+
+// Ensure StreamBackend implements LedgerBackend
+var _ LedgerBackend = (*StreamBackend)(nil)
+
+// StreamBackend implements a data store reading a stream of LedgerCloseMeta
+// frames from a file, named pipe or subprocess stdout. Every frame is an XDR
+// encoded LedgerCloseMeta, prefixed with its length the same way as entries of
+// history archive files (see historyarchive.WriteFramedXdr). Use
+// WriteLedgerCloseMeta to write frames.
+//
+// Frames must be ordered by ledger sequence. Because a stream can only be read
+// forward, ledgers must be requested in increasing order: ledgers before the
+// requested one are skipped and can't be requested again. The last returned
+// ledger can be requested again.
+type StreamBackend struct {
+	mutex  sync.Mutex
+	stream *historyarchive.XdrStream
+	// closer, if set, is called on Close after closing the stream.
+	closer func() error
+	// done is true when the stream has been fully read or failed. The
+	// stream is closed by XdrStream in such case.
+	done bool
+	// next is a frame read ahead of the requested ledger.
+	next *LedgerCloseMeta
+	// last is the ledger returned by the last successful GetLedger call.
+	last *LedgerCloseMeta
+	// latest is the sequence of the latest ledger read from the stream.
+	latest uint32
+}
+
+// NewStreamBackend returns a StreamBackend reading frames from `in`.
+func NewStreamBackend(in io.ReadCloser) *StreamBackend {
+	return &StreamBackend{stream: historyarchive.NewXdrStream(in)}
+}
+
+// NewFileBackend returns a StreamBackend reading frames from the file (or named
+// pipe) at `path`. This is useful to replay recorded ledgers.
+func NewFileBackend(path string) (*StreamBackend, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening file")
+	}
+
+	return NewStreamBackend(file), nil
+}
+
+// NewCommandBackend starts `cmd` and returns a StreamBackend reading frames
+// from its stdout. The command is killed when the backend is closed.
+func NewCommandBackend(cmd *exec.Cmd) (*StreamBackend, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating stdout pipe")
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "error starting command")
+	}
+
+	backend := NewStreamBackend(stdout)
+	backend.closer = func() error {
+		// Ignore the error: the command could have exited already.
+		cmd.Process.Kill()
+		// Wait returns an error when the command is killed, the backend is
+		// closed anyway.
+		cmd.Wait()
+		return nil
+	}
+
+	return backend, nil
+}
+
+// GetLatestLedgerSequence returns the sequence of the latest ledger read from
+// the stream. If no ledgers have been read yet, it reads the first frame so
+// this method can block until data is available.
+func (sb *StreamBackend) GetLatestLedgerSequence() (uint32, error) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if sb.latest == 0 && sb.next == nil && !sb.done {
+		lcm, err := sb.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		sb.next = lcm
+	}
+
+	if sb.latest == 0 {
+		return 0, errors.New("no ledgers exist in stream")
+	}
+
+	return sb.latest, nil
+}
+
+// GetLedger returns the LedgerCloseMeta for the given ledger sequence number.
+// The first returned value is false when the ledger does not exist in the
+// stream: it has ended or the next ledger in the stream is greater than
+// `sequence`. Reading from a pipe blocks until the next frame is available.
+func (sb *StreamBackend) GetLedger(sequence uint32) (bool, LedgerCloseMeta, error) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	if sb.last != nil {
+		lastSequence := uint32(sb.last.LedgerHeader.Header.LedgerSeq)
+		if lastSequence == sequence {
+			return true, *sb.last, nil
+		}
+		if sequence < lastSequence {
+			return false, LedgerCloseMeta{}, errors.Errorf(
+				"ledger %d has already been read from the stream (latest returned: %d)",
+				sequence,
+				lastSequence,
+			)
+		}
+	}
+
+	for {
+		lcm := sb.next
+		sb.next = nil
+
+		if lcm == nil {
+			if sb.done {
+				return false, LedgerCloseMeta{}, nil
+			}
+
+			var err error
+			lcm, err = sb.readFrame()
+			if err != nil {
+				return false, LedgerCloseMeta{}, err
+			}
+			if lcm == nil {
+				return false, LedgerCloseMeta{}, nil
+			}
+		}
+
+		frameSequence := uint32(lcm.LedgerHeader.Header.LedgerSeq)
+		switch {
+		case frameSequence < sequence:
+			continue
+		case frameSequence > sequence:
+			sb.next = lcm
+			return false, LedgerCloseMeta{}, nil
+		default:
+			sb.last = lcm
+			return true, *lcm, nil
+		}
+	}
+}
+
+// readFrame reads the next frame from the stream. It returns nil when the
+// stream has ended.
+func (sb *StreamBackend) readFrame() (*LedgerCloseMeta, error) {
+	var lcm LedgerCloseMeta
+	err := sb.stream.ReadOne(&lcm)
+	if err != nil {
+		// XdrStream closes the stream on read errors.
+		sb.done = true
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "error reading frame from stream")
+	}
+
+	frameSequence := uint32(lcm.LedgerHeader.Header.LedgerSeq)
+	if frameSequence <= sb.latest {
+		sb.done = true
+		sb.stream.Close()
+		return nil, errors.Errorf(
+			"frames are not ordered: ledger %d read after %d",
+			frameSequence,
+			sb.latest,
+		)
+	}
+	sb.latest = frameSequence
+
+	return &lcm, nil
+}
+
+// Close closes the stream and, for backends created by NewCommandBackend,
+// kills the command.
+func (sb *StreamBackend) Close() error {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	var err error
+	if !sb.done {
+		sb.done = true
+		err = sb.stream.Close()
+	}
+
+	if sb.closer != nil {
+		if closerErr := sb.closer(); closerErr != nil && err == nil {
+			err = closerErr
+		}
+		sb.closer = nil
+	}
+
+	return err
+}
+
+// WriteLedgerCloseMeta writes `lcm` to `out` as a frame that can be read by
+// StreamBackend.
+func WriteLedgerCloseMeta(out io.Writer, lcm LedgerCloseMeta) error {
+	return historyarchive.WriteFramedXdr(out, lcm)
+}
+
+// RecordLedgers reads ledgers from `from` to `to` (inclusive) from `backend`
+// and writes them to `out` as frames that can be read by StreamBackend.
+// Ledgers that don't exist in `backend` are skipped. It returns the number of
+// written ledgers.
+func RecordLedgers(out io.Writer, backend LedgerBackend, from, to uint32) (int, error) {
+	written := 0
+	for sequence := from; sequence <= to && sequence >= from; sequence++ {
+		exists, lcm, err := backend.GetLedger(sequence)
+		if err != nil {
+			return written, errors.Wrapf(err, "error getting ledger %d", sequence)
+		}
+		if !exists {
+			continue
+		}
+
+		if err = WriteLedgerCloseMeta(out, lcm); err != nil {
+			return written, errors.Wrapf(err, "error writing ledger %d", sequence)
+		}
+		written++
+	}
+
+	return written, nil
+}
@@ -0,0 +1,182 @@
+package ledgerbackend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLedgerCloseMeta(sequence uint32) LedgerCloseMeta {
+	return LedgerCloseMeta{
+		LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+			Header: xdr.LedgerHeader{
+				LedgerSeq:          xdr.Uint32(sequence),
+				LedgerVersion:      12,
+				TotalCoins:         1000000000000000000,
+				BaseFee:            100,
+				BaseReserve:        100000000,
+				MaxTxSetSize:       50,
+				InflationSeq:       1,
+				ScpValue:           xdr.StellarValue{CloseTime: xdr.TimePoint(1000 + sequence)},
+				BucketListHash:     xdr.Hash{1, 2, 3},
+				PreviousLedgerHash: xdr.Hash{byte(sequence)},
+			},
+		},
+	}
+}
+
+func writeLedgers(t *testing.T, sequences ...uint32) *bytes.Buffer {
+	var buf bytes.Buffer
+	for _, sequence := range sequences {
+		assert.NoError(t, WriteLedgerCloseMeta(&buf, testLedgerCloseMeta(sequence)))
+	}
+	return &buf
+}
+
+func TestStreamBackendGetLedger(t *testing.T) {
+	backend := NewStreamBackend(ioutil.NopCloser(writeLedgers(t, 10, 11, 13)))
+
+	latest, err := backend.GetLatestLedgerSequence()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), latest)
+
+	exists, lcm, err := backend.GetLedger(10)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(10), lcm)
+
+	// The last ledger can be requested again
+	exists, lcm, err = backend.GetLedger(10)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(10), lcm)
+
+	exists, lcm, err = backend.GetLedger(11)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(11), lcm)
+
+	// Ledger 12 is missing, 13 is buffered
+	exists, _, err = backend.GetLedger(12)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	latest, err = backend.GetLatestLedgerSequence()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(13), latest)
+
+	exists, lcm, err = backend.GetLedger(13)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(13), lcm)
+
+	// End of stream
+	exists, _, err = backend.GetLedger(14)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, _, err = backend.GetLedger(11)
+	assert.EqualError(t, err, "ledger 11 has already been read from the stream (latest returned: 13)")
+
+	assert.NoError(t, backend.Close())
+}
+
+func TestStreamBackendSkipsLedgers(t *testing.T) {
+	backend := NewStreamBackend(ioutil.NopCloser(writeLedgers(t, 1, 2, 3, 4)))
+
+	exists, lcm, err := backend.GetLedger(3)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(3), lcm)
+
+	latest, err := backend.GetLatestLedgerSequence()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), latest)
+
+	_, _, err = backend.GetLedger(2)
+	assert.Error(t, err)
+}
+
+func TestStreamBackendEmptyStream(t *testing.T) {
+	backend := NewStreamBackend(ioutil.NopCloser(&bytes.Buffer{}))
+
+	_, err := backend.GetLatestLedgerSequence()
+	assert.EqualError(t, err, "no ledgers exist in stream")
+
+	exists, _, err := backend.GetLedger(1)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, backend.Close())
+}
+
+func TestStreamBackendUnorderedFrames(t *testing.T) {
+	backend := NewStreamBackend(ioutil.NopCloser(writeLedgers(t, 5, 4)))
+
+	exists, _, err := backend.GetLedger(5)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	_, _, err = backend.GetLedger(6)
+	assert.EqualError(t, err, "frames are not ordered: ledger 4 read after 5")
+}
+
+func TestStreamBackendTruncatedFrame(t *testing.T) {
+	buf := writeLedgers(t, 1, 2)
+	buf.Truncate(buf.Len() - 4)
+	backend := NewStreamBackend(ioutil.NopCloser(buf))
+
+	exists, _, err := backend.GetLedger(1)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	_, _, err = backend.GetLedger(2)
+	assert.Error(t, err)
+}
+
+func TestFileBackendReplaysRecordedLedgers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream-backend")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mockBackend := &MockDatabaseBackend{}
+	mockBackend.On("GetLedger", uint32(2)).Return(true, testLedgerCloseMeta(2), nil).Once()
+	mockBackend.On("GetLedger", uint32(3)).Return(false, LedgerCloseMeta{}, nil).Once()
+	mockBackend.On("GetLedger", uint32(4)).Return(true, testLedgerCloseMeta(4), nil).Once()
+
+	path := filepath.Join(dir, "ledgers.xdr")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	written, err := RecordLedgers(file, mockBackend, 2, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, written)
+	assert.NoError(t, file.Close())
+	mockBackend.AssertExpectations(t)
+
+	backend, err := NewFileBackend(path)
+	assert.NoError(t, err)
+
+	exists, lcm, err := backend.GetLedger(2)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(2), lcm)
+
+	exists, _, err = backend.GetLedger(3)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, lcm, err = backend.GetLedger(4)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, testLedgerCloseMeta(4), lcm)
+
+	assert.NoError(t, backend.Close())
+
+	_, err = NewFileBackend(filepath.Join(dir, "missing.xdr"))
+	assert.Error(t, err)
+}
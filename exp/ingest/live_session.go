@@ -278,7 +278,14 @@ func (s *LiveSession) initState(historyAdapter *adapters.HistoryArchiveAdapter,
 		tempSet = s.TempSet
 	}
 
-	stateReader, err := historyAdapter.GetState(sequence, tempSet, s.MaxStreamRetries)
+	stateReader, err := getStateReader(
+		historyAdapter,
+		sequence,
+		tempSet,
+		s.MaxStreamRetries,
+		s.StateCheckpointStore,
+		s.StateCheckpointInterval,
+	)
 	if err != nil {
 		return errors.Wrap(err, "Error getting state from history archive")
 	}
@@ -306,7 +313,7 @@ func (s *LiveSession) initState(historyAdapter *adapters.HistoryArchiveAdapter,
 	if s.StateReporter != nil {
 		s.StateReporter.OnEndState(nil, false)
 	}
-	return nil
+	return removeStateCheckpoint(s.StateCheckpointStore, sequence)
 }
 
 // Shutdown gracefully stops the pipelines and the session. This method blocks
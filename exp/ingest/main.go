@@ -44,6 +44,20 @@ type LiveSession struct {
 	// errors while streaming xdr bucket entries from the history archive.
 	// Default MaxStreamRetries value (0) means that there should be no retry attempts
 	MaxStreamRetries int
+	// StateCheckpointStore, if set, is used to save state processing progress
+	// every `StateCheckpointInterval` bucket entries so that interrupted state
+	// processing can be resumed. `TempSet` must implement io.ResumableTempSet,
+	// like io.MemoryTempSet and io.DiskTempSet. io.PostgresTempSet is not
+	// resumable.
+	// `StatePipeline` is run once for every segment of entries between
+	// checkpoints and a checkpoint is saved after the run, including
+	// post-processing hooks, succeeds. State processors must be able to
+	// process the same entry again, check
+	// io.MakeResumableSingleLedgerStateReader godoc.
+	StateCheckpointStore io.StateCheckpointStore
+	// StateCheckpointInterval is the number of bucket entries between state
+	// checkpoints. If 0, defaults to 1000000.
+	StateCheckpointInterval int
 
 	latestSuccessfullyProcessedLedger uint32
 }
@@ -65,6 +79,72 @@ type SingleLedgerSession struct {
 	// errors while streaming xdr bucket entries from the history archive.
 	// Set MaxStreamRetries to 0 if there should be no retry attempts
 	MaxStreamRetries int
+	// StateCheckpointStore, if set, is used to save state processing progress
+	// every `StateCheckpointInterval` bucket entries so that interrupted state
+	// processing can be resumed. `TempSet` must implement io.ResumableTempSet,
+	// like io.MemoryTempSet and io.DiskTempSet. io.PostgresTempSet is not
+	// resumable.
+	// `StatePipeline` is run once for every segment of entries between
+	// checkpoints and a checkpoint is saved after the run, including
+	// post-processing hooks, succeeds. State processors must be able to
+	// process the same entry again, check
+	// io.MakeResumableSingleLedgerStateReader godoc.
+	StateCheckpointStore io.StateCheckpointStore
+	// StateCheckpointInterval is the number of bucket entries between state
+	// checkpoints. If 0, defaults to 1000000.
+	StateCheckpointInterval int
+}
+
+// RangeSession processes ledgers in [`FromLedger`, `ToLedger`] range. The range
+// is split into chunks of `ChunkSize` ledgers which are processed concurrently
+// by `Workers` workers. Each worker uses its own `LedgerBackend` and
+// `LedgerPipeline` created by `LedgerBackendFactory` and
+// `LedgerPipelineFactory`. Useful for reingesting history.
+//
+// Ledgers in a chunk are processed in order but there are no guarantees about
+// the order of chunks so processors must not depend on ledgers being processed
+// sequentially.
+type RangeSession struct {
+	standardSession
+
+	FromLedger uint32
+	ToLedger   uint32
+	// ChunkSize is the number of ledgers in a single chunk. If 0, defaults to
+	// 1000.
+	ChunkSize uint32
+	// Workers is the number of chunks processed concurrently. If 0, defaults
+	// to runtime.NumCPU().
+	Workers int
+	// LedgerBackendFactory creates a backend for each worker. Backends are
+	// closed when the session finishes.
+	LedgerBackendFactory func() (ledgerbackend.LedgerBackend, error)
+	// LedgerPipelineFactory creates a pipeline for each worker. A pipeline
+	// cannot process multiple ledgers at the same time so every worker needs
+	// a separate instance.
+	LedgerPipelineFactory func() *pipeline.LedgerPipeline
+	// RangeReporter is used to report progress of chunks processing. Methods
+	// are called concurrently by workers.
+	RangeReporter RangeReporter
+
+	chunksMutex sync.Mutex
+	chunks      []RangeChunk
+
+	pipelinesMutex sync.Mutex
+	pipelines      []*pipeline.LedgerPipeline
+}
+
+// RangeChunk describes the progress of processing a chunk of ledgers in
+// RangeSession.
+type RangeChunk struct {
+	FromLedger uint32
+	ToLedger   uint32
+	// LatestProcessedLedger is the last successfully processed ledger in the
+	// chunk or 0 if no ledgers have been processed yet.
+	LatestProcessedLedger uint32
+	// Done is true if all ledgers in the chunk have been processed.
+	Done bool
+	// Err is the error that stopped processing the chunk, if any.
+	Err error
 }
 
 // Session is an implementation of a ingesting scenario. Some useful sessions
@@ -135,6 +215,16 @@ func (r reporterStateReader) Read() (xdr.LedgerEntryChange, error) {
 	return entry, err
 }
 
+// SaveCheckpoint implements io.ResumableStateReader so wrapping a resumable
+// reader does not hide its checkpoints from StatePipeline.
+func (r reporterStateReader) SaveCheckpoint() (bool, error) {
+	resumableReader, ok := r.StateReader.(io.ResumableStateReader)
+	if !ok {
+		return false, nil
+	}
+	return resumableReader.SaveCheckpoint()
+}
+
 // LedgerReporter can be used by a session to log progress
 // or update metrics as the session runs its ledger pipelines.
 type LedgerReporter interface {
@@ -153,6 +243,24 @@ type LedgerReporter interface {
 	OnEndLedger(err error, shutdown bool)
 }
 
+// RangeReporter can be used by RangeSession to log progress or update metrics
+// as the session processes chunks of ledgers. Methods are called
+// concurrently by workers so implementations must be thread-safe.
+type RangeReporter interface {
+	// OnStartChunk is called when a worker begins processing ledgers
+	// [from, to] of a chunk.
+	OnStartChunk(from, to uint32)
+	// OnChunkLedger is called when a ledger in a chunk has been successfully
+	// processed.
+	OnChunkLedger(sequence uint32)
+	// OnEndChunk is called when a worker finishes processing a chunk.
+	// if err is not nil it means that the worker stopped processing the
+	// chunk because of an error.
+	// if shutdown is true it means the worker stopped processing the chunk
+	// because it received a shutdown signal
+	OnEndChunk(from, to uint32, err error, shutdown bool)
+}
+
 // reporterLedgerReader instruments a io.LedgerReader with a LedgerReporter
 // which reports each io.LedgerTransaction which is read from the reader
 type reporterLedgerReader struct {
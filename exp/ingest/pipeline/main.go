@@ -235,6 +235,15 @@ type stateReaderWrapper struct {
 
 var _ supportPipeline.Reader = &stateReaderWrapper{}
 
+// segmentStateReader wraps ResumableStateReader for a pipeline run processing
+// a single segment of the state. Processors close their reader at the end of
+// every run so closing is deferred to StatePipeline.
+type segmentStateReader struct {
+	io.StateReader
+}
+
+var _ io.StateReader = &segmentStateReader{}
+
 // ledgerReaderWrapper wraps LedgerReader to implement pipeline.Reader interface.
 type ledgerReaderWrapper struct {
 	io.LedgerReader
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"github.com/stellar/go/exp/ingest/io"
 	supportPipeline "github.com/stellar/go/exp/support/pipeline"
+	"github.com/stellar/go/support/errors"
 )
 
 func StateNode(processor StateProcessor) *supportPipeline.PipelineNode {
@@ -11,6 +12,43 @@ func StateNode(processor StateProcessor) *supportPipeline.PipelineNode {
 	}
 }
 
+// Process processes the state from `reader`. If `reader` implements
+// io.ResumableStateReader the pipeline is run once for every segment of the
+// state and the checkpoint at the end of the segment is saved after the run,
+// including post-processing hooks, succeeds. Processors and hooks committing
+// their work at the end of a run must commit all the entries of the segment
+// then, so they are never skipped after resuming from the checkpoint. The
+// reader is closed when processing of the whole state ends.
 func (p *StatePipeline) Process(reader io.StateReader) <-chan error {
-	return p.Pipeline.Process(&stateReaderWrapper{reader})
+	resumableReader, ok := reader.(io.ResumableStateReader)
+	if !ok {
+		return p.Pipeline.Process(&stateReaderWrapper{reader})
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		err := p.processSegments(resumableReader)
+		reader.Close()
+		errChan <- err
+	}()
+	return errChan
+}
+
+// processSegments runs the pipeline for every segment of the state and saves
+// checkpoints after the segments are processed.
+func (p *StatePipeline) processSegments(reader io.ResumableStateReader) error {
+	for {
+		err := <-p.Pipeline.Process(&stateReaderWrapper{&segmentStateReader{reader}})
+		if err != nil {
+			return err
+		}
+
+		more, err := reader.SaveCheckpoint()
+		if err != nil {
+			return errors.Wrap(err, "Error saving state checkpoint")
+		}
+		if !more {
+			return nil
+		}
+	}
 }
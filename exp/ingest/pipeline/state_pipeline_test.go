@@ -0,0 +1,150 @@
+package pipeline_test
+
+import (
+	"context"
+	stdio "io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/exp/ingest/pipeline"
+	supportPipeline "github.com/stellar/go/exp/support/pipeline"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+// segmentedStateReader is a io.ResumableStateReader returning `segments` and
+// recording the number of committed entries when checkpoints are saved.
+type segmentedStateReader struct {
+	t        *testing.T
+	segments [][]xdr.LedgerEntryChange
+	// committed returns the number of entries committed by the pipeline.
+	committed func() int
+
+	segment     int
+	offset      int
+	entriesRead int
+	saved       []int
+	closed      int
+}
+
+func (r *segmentedStateReader) GetSequence() uint32 {
+	return 1000
+}
+
+func (r *segmentedStateReader) Read() (xdr.LedgerEntryChange, error) {
+	if r.closed > 0 {
+		r.t.Fatal("Read called on a closed reader")
+	}
+	if r.offset >= len(r.segments[r.segment]) {
+		return xdr.LedgerEntryChange{}, stdio.EOF
+	}
+	entry := r.segments[r.segment][r.offset]
+	r.offset++
+	r.entriesRead++
+	return entry, nil
+}
+
+func (r *segmentedStateReader) SaveCheckpoint() (bool, error) {
+	if r.segment == len(r.segments)-1 {
+		return false, nil
+	}
+	// Entries of the segment must be committed before the checkpoint is saved
+	assert.Equal(r.t, r.entriesRead, r.committed())
+	r.saved = append(r.saved, r.entriesRead)
+	r.segment++
+	r.offset = 0
+	return true, nil
+}
+
+func (r *segmentedStateReader) Close() error {
+	r.closed++
+	return nil
+}
+
+var _ io.ResumableStateReader = &segmentedStateReader{}
+
+// slowStateProcessor lags behind the reader: it processes entries slowly and
+// commits them in a post-processing hook only.
+type slowStateProcessor struct {
+	mutex     sync.Mutex
+	processed int
+	committed int
+}
+
+func (p *slowStateProcessor) ProcessState(ctx context.Context, store *supportPipeline.Store, r io.StateReader, w io.StateWriter) error {
+	defer w.Close()
+	defer r.Close()
+
+	for {
+		_, err := r.Read()
+		if err != nil {
+			if err == stdio.EOF {
+				return nil
+			}
+			return err
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		p.mutex.Lock()
+		p.processed++
+		p.mutex.Unlock()
+	}
+}
+
+func (p *slowStateProcessor) commit(ctx context.Context, err error) error {
+	if err != nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.committed = p.processed
+	return nil
+}
+
+func (p *slowStateProcessor) getCommitted() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.committed
+}
+
+func (p *slowStateProcessor) Name() string {
+	return "slowStateProcessor"
+}
+
+func (p *slowStateProcessor) Reset() {}
+
+func TestStatePipelineSavesCheckpointsAfterProcessing(t *testing.T) {
+	entry := xdr.LedgerEntryChange{
+		Type: xdr.LedgerEntryChangeTypeLedgerEntryState,
+		State: &xdr.LedgerEntry{
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeAccount,
+				Account: &xdr.AccountEntry{
+					AccountId: xdr.MustAddress("GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML"),
+				},
+			},
+		},
+	}
+	processor := &slowStateProcessor{}
+	reader := &segmentedStateReader{
+		t: t,
+		segments: [][]xdr.LedgerEntryChange{
+			{entry, entry, entry},
+			{entry, entry},
+			{entry},
+		},
+		committed: processor.getCommitted,
+	}
+
+	statePipeline := &pipeline.StatePipeline{}
+	statePipeline.SetRoot(pipeline.StateNode(processor))
+	statePipeline.AddPostProcessingHook(processor.commit)
+
+	err := <-statePipeline.Process(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 5}, reader.saved)
+	assert.Equal(t, 6, processor.getCommitted())
+	assert.Equal(t, 1, reader.closed)
+}
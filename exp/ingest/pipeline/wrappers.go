@@ -42,6 +42,10 @@ func (w *stateReaderWrapper) Read() (interface{}, error) {
 	return w.StateReader.Read()
 }
 
+func (r *segmentStateReader) Close() error {
+	return nil
+}
+
 func (w *readerWrapperState) GetSequence() uint32 {
 	return GetLedgerSequenceFromContext(w.Reader.GetContext())
 }
@@ -133,7 +137,7 @@ func (w *readerWrapperLedger) Close() error {
 
 	// Call IgnoreUpgradeChanges on a wrapped reader because `readerWrapperLedger`
 	// is responsible for streaming ledger upgrade changes now.
-	wrapper, ok := w.Reader.(*ledgerReaderWrapper)
+	wrapper, ok := supportPipeline.UnwrapReader(w.Reader).(*ledgerReaderWrapper)
 	if ok {
 		wrapper.LedgerReader.IgnoreUpgradeChanges()
 	}
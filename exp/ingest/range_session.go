@@ -0,0 +1,366 @@
+package ingest
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/exp/ingest/adapters"
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/exp/ingest/pipeline"
+	supportPipeline "github.com/stellar/go/exp/support/pipeline"
+	"github.com/stellar/go/support/errors"
+)
+
+var _ Session = &RangeSession{}
+
+const defaultRangeChunkSize = 1000
+
+// rangeWorker holds objects used by a single worker of RangeSession.
+type rangeWorker struct {
+	ledgerAdapter  *adapters.LedgerBackendAdapter
+	ledgerPipeline *pipeline.LedgerPipeline
+}
+
+// Run splits the [`FromLedger`, `ToLedger`] range into chunks and processes
+// them. A failed chunk doesn't stop processing other chunks. When some chunks
+// fail, Run returns an error after all other chunks are processed. Failed
+// chunks can be retried using Resume.
+// Returns nil when session has been shutdown.
+func (s *RangeSession) Run() error {
+	err := s.validate()
+	if err != nil {
+		return errors.Wrap(err, "Validation error")
+	}
+
+	s.chunksMutex.Lock()
+	s.chunks = s.splitRange(s.FromLedger)
+	s.chunksMutex.Unlock()
+
+	return s.run()
+}
+
+// Resume processes all chunks that have not been processed yet, starting
+// from the ledger after the last successfully processed ledger of each chunk.
+// Ledgers lower than `ledgerSequence` are considered processed, pass
+// `FromLedger` to retry all unfinished chunks. If Run was not called before
+// (ex. after application restart), [`ledgerSequence`, `ToLedger`] range is
+// split into chunks.
+// Returns nil when session has been shutdown.
+func (s *RangeSession) Resume(ledgerSequence uint32) error {
+	err := s.validate()
+	if err != nil {
+		return errors.Wrap(err, "Validation error")
+	}
+
+	if ledgerSequence < s.FromLedger || ledgerSequence > s.ToLedger {
+		return errors.Errorf(
+			"Ledger %d is out of range [%d, %d]",
+			ledgerSequence,
+			s.FromLedger,
+			s.ToLedger,
+		)
+	}
+
+	s.chunksMutex.Lock()
+	if len(s.chunks) == 0 {
+		s.chunks = s.splitRange(ledgerSequence)
+	} else {
+		for i := range s.chunks {
+			chunk := &s.chunks[i]
+			chunk.Err = nil
+			if chunk.Done {
+				continue
+			}
+
+			if chunk.ToLedger < ledgerSequence {
+				chunk.LatestProcessedLedger = chunk.ToLedger
+				chunk.Done = true
+			} else if chunk.nextLedger() < ledgerSequence {
+				chunk.LatestProcessedLedger = ledgerSequence - 1
+			}
+		}
+	}
+	s.chunksMutex.Unlock()
+
+	return s.run()
+}
+
+// Chunks returns the current progress of all chunks.
+func (s *RangeSession) Chunks() []RangeChunk {
+	s.chunksMutex.Lock()
+	defer s.chunksMutex.Unlock()
+
+	chunks := make([]RangeChunk, len(s.chunks))
+	copy(chunks, s.chunks)
+	return chunks
+}
+
+// Shutdown gracefully stops the pipelines and the session. This method blocks
+// until pipelines are gracefully shutdown.
+func (s *RangeSession) Shutdown() {
+	// Send shutdown signal
+	s.standardSession.Shutdown()
+
+	s.pipelinesMutex.Lock()
+	pipelines := s.pipelines
+	s.pipelinesMutex.Unlock()
+
+	// Shutdown pipelines
+	for _, ledgerPipeline := range pipelines {
+		ledgerPipeline.Shutdown()
+	}
+
+	// Shutdown signals sent, block/wait until pipelines are done
+	// shutting down.
+	for _, ledgerPipeline := range pipelines {
+		for ledgerPipeline.IsRunning() {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+func (s *RangeSession) validate() error {
+	switch {
+	case s.FromLedger == 0:
+		return errors.New("FromLedger not set")
+	case s.ToLedger < s.FromLedger:
+		return errors.New("ToLedger is lower than FromLedger")
+	case s.LedgerBackendFactory == nil:
+		return errors.New("LedgerBackendFactory not set")
+	case s.LedgerPipelineFactory == nil:
+		return errors.New("LedgerPipelineFactory not set")
+	case s.Workers < 0:
+		return errors.New("Workers is negative")
+	}
+
+	return nil
+}
+
+// splitRange splits [from, ToLedger] range into chunks. Chunk boundaries
+// are aligned to `ChunkSize` counting from `FromLedger`.
+func (s *RangeSession) splitRange(from uint32) []RangeChunk {
+	chunkSize := s.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = defaultRangeChunkSize
+	}
+
+	var chunks []RangeChunk
+	for start := s.FromLedger; start <= s.ToLedger; {
+		end := s.ToLedger
+		// Prevent overflow when ToLedger is close to the max uint32
+		if s.ToLedger-start >= chunkSize {
+			end = start + chunkSize - 1
+		}
+
+		if end >= from {
+			chunk := RangeChunk{FromLedger: start, ToLedger: end}
+			if from > start {
+				chunk.LatestProcessedLedger = from - 1
+			}
+			chunks = append(chunks, chunk)
+		}
+
+		if end == s.ToLedger {
+			break
+		}
+		start = end + 1
+	}
+
+	return chunks
+}
+
+func (s *RangeSession) run() error {
+	s.standardSession.shutdown = make(chan bool)
+
+	s.setRunningState(true)
+	defer s.setRunningState(false)
+
+	// Queue chunks to process
+	s.chunksMutex.Lock()
+	queue := make(chan int, len(s.chunks))
+	for i, chunk := range s.chunks {
+		if !chunk.Done {
+			queue <- i
+		}
+	}
+	s.chunksMutex.Unlock()
+	close(queue)
+
+	workers, err := s.createWorkers(len(queue))
+	defer func() {
+		for _, worker := range workers {
+			// Ignore the error: all ledgers have been processed at this point.
+			worker.ledgerAdapter.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker rangeWorker) {
+			defer wg.Done()
+			s.work(worker, queue)
+		}(worker)
+	}
+	wg.Wait()
+
+	// Exit early if Shutdown() was called.
+	select {
+	case <-s.standardSession.shutdown:
+		return nil
+	default:
+		// Continue
+	}
+
+	s.chunksMutex.Lock()
+	defer s.chunksMutex.Unlock()
+
+	var failed []RangeChunk
+	for _, chunk := range s.chunks {
+		if chunk.Err != nil {
+			failed = append(failed, chunk)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Wrapf(
+			failed[0].Err,
+			"%d chunk(s) failed, first failed chunk: [%d, %d]",
+			len(failed),
+			failed[0].FromLedger,
+			failed[0].ToLedger,
+		)
+	}
+
+	return nil
+}
+
+// createWorkers creates up to `Workers` workers, but not more than
+// `chunksCount`. Workers created before an error are returned so that their
+// backends can be closed.
+func (s *RangeSession) createWorkers(chunksCount int) ([]rangeWorker, error) {
+	workersCount := s.Workers
+	if workersCount == 0 {
+		workersCount = runtime.NumCPU()
+	}
+	if workersCount > chunksCount {
+		workersCount = chunksCount
+	}
+
+	s.pipelinesMutex.Lock()
+	defer s.pipelinesMutex.Unlock()
+	s.pipelines = nil
+
+	workers := make([]rangeWorker, 0, workersCount)
+	for i := 0; i < workersCount; i++ {
+		backend, err := s.LedgerBackendFactory()
+		if err != nil {
+			return workers, errors.Wrap(err, "Error creating ledger backend")
+		}
+
+		ledgerPipeline := s.LedgerPipelineFactory()
+		workers = append(workers, rangeWorker{
+			ledgerAdapter:  &adapters.LedgerBackendAdapter{Backend: backend},
+			ledgerPipeline: ledgerPipeline,
+		})
+		s.pipelines = append(s.pipelines, ledgerPipeline)
+	}
+
+	return workers, nil
+}
+
+// work processes chunks from `queue` until the queue is empty or the session
+// is shutdown.
+func (s *RangeSession) work(worker rangeWorker, queue <-chan int) {
+	for index := range queue {
+		select {
+		case <-s.standardSession.shutdown:
+			return
+		default:
+			// Continue
+		}
+
+		s.chunksMutex.Lock()
+		from := s.chunks[index].nextLedger()
+		to := s.chunks[index].ToLedger
+		s.chunksMutex.Unlock()
+
+		if s.RangeReporter != nil {
+			s.RangeReporter.OnStartChunk(from, to)
+		}
+
+		shutdown, err := s.processChunk(worker, index, from, to)
+
+		s.chunksMutex.Lock()
+		s.chunks[index].Err = err
+		s.chunks[index].Done = err == nil && !shutdown
+		s.chunksMutex.Unlock()
+
+		if s.RangeReporter != nil {
+			s.RangeReporter.OnEndChunk(from, to, err, shutdown)
+		}
+
+		if shutdown {
+			return
+		}
+	}
+}
+
+// processChunk processes ledgers [from, to] of a chunk at `index`. Returns
+// true if the session has been shutdown.
+func (s *RangeSession) processChunk(worker rangeWorker, index int, from, to uint32) (bool, error) {
+	for ledgerSequence := from; ledgerSequence <= to; ledgerSequence++ {
+		ledgerReader, err := worker.ledgerAdapter.GetLedger(ledgerSequence)
+		if err != nil {
+			if err == io.ErrNotFound {
+				return false, errors.Errorf("Ledger %d not found", ledgerSequence)
+			}
+			return false, errors.Wrapf(err, "Error getting ledger %d", ledgerSequence)
+		}
+
+		err = <-worker.ledgerPipeline.Process(ledgerReader)
+		if err != nil {
+			// Return with no errors if pipeline shutdown
+			if err == supportPipeline.ErrShutdown {
+				return true, nil
+			}
+			return false, errors.Wrapf(err, "Ledger pipeline errored processing ledger %d", ledgerSequence)
+		}
+
+		s.chunksMutex.Lock()
+		s.chunks[index].LatestProcessedLedger = ledgerSequence
+		s.chunksMutex.Unlock()
+
+		if s.RangeReporter != nil {
+			s.RangeReporter.OnChunkLedger(ledgerSequence)
+		}
+
+		// Prevent overflow when `to` is the max uint32
+		if ledgerSequence == to {
+			break
+		}
+
+		// Exit early if Shutdown() was called.
+		select {
+		case <-s.standardSession.shutdown:
+			return true, nil
+		default:
+			// Continue
+		}
+	}
+
+	return false, nil
+}
+
+// nextLedger returns the first ledger of the chunk that has not been
+// processed yet.
+func (c RangeChunk) nextLedger() uint32 {
+	if c.LatestProcessedLedger == 0 {
+		return c.FromLedger
+	}
+	return c.LatestProcessedLedger + 1
+}
@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"context"
+	stdio "io"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/exp/ingest/ledgerbackend"
+	"github.com/stellar/go/exp/ingest/pipeline"
+	supportPipeline "github.com/stellar/go/exp/support/pipeline"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRangeBackend struct {
+	latest uint32
+}
+
+func (b *testRangeBackend) GetLatestLedgerSequence() (uint32, error) {
+	return b.latest, nil
+}
+
+func (b *testRangeBackend) GetLedger(sequence uint32) (bool, ledgerbackend.LedgerCloseMeta, error) {
+	if sequence > b.latest {
+		return false, ledgerbackend.LedgerCloseMeta{}, nil
+	}
+
+	return true, ledgerbackend.LedgerCloseMeta{
+		LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+			Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(sequence)},
+		},
+	}, nil
+}
+
+func (b *testRangeBackend) Close() error {
+	return nil
+}
+
+// testRangeProcessor records processed ledgers. It fails once when
+// processing `failLedger`.
+type testRangeProcessor struct {
+	mutex      *sync.Mutex
+	processed  map[uint32]int
+	failLedger uint32
+}
+
+func (p *testRangeProcessor) ProcessLedger(ctx context.Context, store *supportPipeline.Store, r io.LedgerReader, w io.LedgerWriter) (err error) {
+	defer func() {
+		closeErr := r.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	defer w.Close()
+
+	_, err = r.ReadUpgradeChange()
+	if err != stdio.EOF {
+		return errors.New("unexpected upgrade change")
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	sequence := r.GetSequence()
+	if sequence == p.failLedger {
+		p.failLedger = 0
+		return errors.New("processing error")
+	}
+	p.processed[sequence]++
+	return nil
+}
+
+func (*testRangeProcessor) Name() string {
+	return "Test range processor"
+}
+
+func (*testRangeProcessor) Reset() {}
+
+func newTestRangeSession(from, to, failLedger uint32) (*RangeSession, *testRangeProcessor) {
+	processor := &testRangeProcessor{
+		mutex:      &sync.Mutex{},
+		processed:  map[uint32]int{},
+		failLedger: failLedger,
+	}
+
+	session := &RangeSession{
+		FromLedger: from,
+		ToLedger:   to,
+		ChunkSize:  10,
+		Workers:    3,
+		LedgerBackendFactory: func() (ledgerbackend.LedgerBackend, error) {
+			return &testRangeBackend{latest: 100}, nil
+		},
+		LedgerPipelineFactory: func() *pipeline.LedgerPipeline {
+			ledgerPipeline := &pipeline.LedgerPipeline{}
+			ledgerPipeline.SetRoot(pipeline.LedgerNode(processor))
+			return ledgerPipeline
+		},
+	}
+
+	return session, processor
+}
+
+func assertProcessedOnce(t *testing.T, processor *testRangeProcessor, from, to uint32) {
+	assert.Len(t, processor.processed, int(to-from+1))
+	for sequence := from; sequence <= to; sequence++ {
+		assert.Equal(t, 1, processor.processed[sequence], "ledger %d", sequence)
+	}
+}
+
+func TestRangeSessionRun(t *testing.T) {
+	session, processor := newTestRangeSession(1, 25, 0)
+
+	err := session.Run()
+	assert.NoError(t, err)
+	assertProcessedOnce(t, processor, 1, 25)
+	assert.Equal(t, []RangeChunk{
+		{FromLedger: 1, ToLedger: 10, LatestProcessedLedger: 10, Done: true},
+		{FromLedger: 11, ToLedger: 20, LatestProcessedLedger: 20, Done: true},
+		{FromLedger: 21, ToLedger: 25, LatestProcessedLedger: 25, Done: true},
+	}, session.Chunks())
+}
+
+func TestRangeSessionResumeFailedChunk(t *testing.T) {
+	session, processor := newTestRangeSession(1, 25, 15)
+
+	err := session.Run()
+	assert.EqualError(t, err, "1 chunk(s) failed, first failed chunk: [11, 20]: Ledger pipeline errored processing ledger 15: Processor Test range processor errored: processing error")
+
+	chunks := session.Chunks()
+	assert.True(t, chunks[0].Done)
+	assert.False(t, chunks[1].Done)
+	assert.Equal(t, uint32(14), chunks[1].LatestProcessedLedger)
+	assert.Error(t, chunks[1].Err)
+	assert.True(t, chunks[2].Done)
+
+	err = session.Resume(session.FromLedger)
+	assert.NoError(t, err)
+	assertProcessedOnce(t, processor, 1, 25)
+	for _, chunk := range session.Chunks() {
+		assert.True(t, chunk.Done)
+		assert.NoError(t, chunk.Err)
+	}
+}
+
+func TestRangeSessionResumeWithoutRun(t *testing.T) {
+	session, processor := newTestRangeSession(1, 25, 0)
+
+	err := session.Resume(18)
+	assert.NoError(t, err)
+	assertProcessedOnce(t, processor, 18, 25)
+	assert.Equal(t, []RangeChunk{
+		{FromLedger: 11, ToLedger: 20, LatestProcessedLedger: 20, Done: true},
+		{FromLedger: 21, ToLedger: 25, LatestProcessedLedger: 25, Done: true},
+	}, session.Chunks())
+
+	err = session.Resume(30)
+	assert.EqualError(t, err, "Ledger 30 is out of range [1, 25]")
+}
+
+func TestRangeSessionMissingLedger(t *testing.T) {
+	session, _ := newTestRangeSession(95, 105, 0)
+
+	err := session.Run()
+	assert.EqualError(t, err, "2 chunk(s) failed, first failed chunk: [95, 104]: Ledger 101 not found")
+}
@@ -43,7 +43,14 @@ func (s *SingleLedgerSession) processState(historyAdapter *adapters.HistoryArchi
 		tempSet = s.TempSet
 	}
 
-	stateReader, err := historyAdapter.GetState(sequence, tempSet, s.MaxStreamRetries)
+	stateReader, err := getStateReader(
+		historyAdapter,
+		sequence,
+		tempSet,
+		s.MaxStreamRetries,
+		s.StateCheckpointStore,
+		s.StateCheckpointInterval,
+	)
 	if err != nil {
 		return errors.Wrap(err, "Error getting state from history archive")
 	}
@@ -72,10 +79,12 @@ func (s *SingleLedgerSession) processState(historyAdapter *adapters.HistoryArchi
 			s.StateReporter.OnEndState(nil, true)
 		}
 		s.StatePipeline.Shutdown()
+		// Keep the checkpoint, state has not been fully processed.
+		return nil
 	}
 
 	if s.StateReporter != nil {
 		s.StateReporter.OnEndState(nil, false)
 	}
-	return nil
+	return removeStateCheckpoint(s.StateCheckpointStore, sequence)
 }
@@ -1,5 +1,13 @@
 package ingest
 
+import (
+	"github.com/stellar/go/exp/ingest/adapters"
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/support/errors"
+)
+
+const defaultStateCheckpointInterval = 1000000
+
 func (s *standardSession) setRunningState(newState bool) {
 	s.runningMutex.Lock()
 	defer s.runningMutex.Unlock()
@@ -29,3 +37,53 @@ func (s *standardSession) UpdateLock() {
 func (s *standardSession) UpdateUnlock() {
 	s.rwLock.Unlock()
 }
+
+// getStateReader returns a reader with the state of the ledger at `sequence`.
+// If `checkpoints` is not nil, the reader saves checkpoints and resumes from
+// the saved one.
+func getStateReader(
+	historyAdapter *adapters.HistoryArchiveAdapter,
+	sequence uint32,
+	tempSet io.TempSet,
+	maxStreamRetries int,
+	checkpoints io.StateCheckpointStore,
+	checkpointInterval int,
+) (io.StateReader, error) {
+	if checkpoints == nil {
+		return historyAdapter.GetState(sequence, tempSet, maxStreamRetries)
+	}
+
+	resumableTempSet, ok := tempSet.(io.ResumableTempSet)
+	if !ok {
+		return nil, errors.Errorf(
+			"TempSet (%T) does not implement io.ResumableTempSet and cannot be used with StateCheckpointStore",
+			tempSet,
+		)
+	}
+
+	if checkpointInterval == 0 {
+		checkpointInterval = defaultStateCheckpointInterval
+	}
+
+	return historyAdapter.GetResumableState(
+		sequence,
+		resumableTempSet,
+		maxStreamRetries,
+		checkpoints,
+		checkpointInterval,
+	)
+}
+
+// removeStateCheckpoint removes the checkpoint of the state at `sequence`
+// after the state has been successfully processed.
+func removeStateCheckpoint(checkpoints io.StateCheckpointStore, sequence uint32) error {
+	if checkpoints == nil {
+		return nil
+	}
+
+	err := checkpoints.Remove(sequence)
+	if err != nil {
+		return errors.Wrap(err, "Error removing state checkpoint")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package ingest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStateReaderRejectsNonResumableTempSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state-checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = getStateReader(
+		nil,
+		63,
+		&io.PostgresTempSet{},
+		0,
+		&io.FileStateCheckpointStore{Dir: dir},
+		0,
+	)
+	assert.EqualError(
+		t,
+		err,
+		"TempSet (*io.PostgresTempSet) does not implement io.ResumableTempSet and cannot be used with StateCheckpointStore",
+	)
+}
@@ -145,11 +145,15 @@ func (v *StateVerifier) Write(entry xdr.LedgerEntry) error {
 	}
 
 	if !bytes.Equal(actualEntryMarshaled, expectedEntryMarshaled) {
+		// Ignore error as StateError below is more important. Keys are
+		// equal so diffing can fail only for unknown entry types.
+		diff, _ := xdr.DiffLedgerEntries(expectedEntry, actualEntry)
 		return ingesterrors.NewStateError(errors.Errorf(
-			"Entry does not match the fetched entry. Expected: %s (pretransform = %s), actual: %s",
+			"Entry does not match the fetched entry. Expected: %s (pretransform = %s), actual: %s, diff: %s",
 			base64.StdEncoding.EncodeToString(expectedEntryMarshaled),
 			base64.StdEncoding.EncodeToString(preTransformExpectedEntryMarshaled),
 			base64.StdEncoding.EncodeToString(actualEntryMarshaled),
+			diff,
 		))
 	}
 
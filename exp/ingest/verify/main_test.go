@@ -235,7 +235,7 @@ func (s *StateVerifierTestSuite) TestActualExpectedEntryNotEqualWrite() {
 	s.Assert().NoError(err)
 
 	errorMsg := fmt.Sprintf(
-		"Entry does not match the fetched entry. Expected: %s (pretransform = %s), actual: %s",
+		"Entry does not match the fetched entry. Expected: %s (pretransform = %s), actual: %s, diff: high_threshold 1→0",
 		expectedEntryBase64,
 		expectedEntryBase64,
 		actualEntryBase64,
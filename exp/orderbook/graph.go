@@ -98,6 +98,19 @@ func (graph *OrderBookGraph) Apply(ledger uint32) error {
 	return nil
 }
 
+// Clear removes all offers from the graph, resets the last applied ledger and
+// discards queued operations.
+func (graph *OrderBookGraph) Clear() {
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	graph.edgesForSellingAsset = map[string]edgeSet{}
+	graph.edgesForBuyingAsset = map[string]edgeSet{}
+	graph.tradingPairForOffer = map[xdr.Int64]tradingPair{}
+	graph.lastLedger = 0
+	graph.batchedUpdates = graph.batch()
+}
+
 // Offers returns a list of offers contained in the order book
 func (graph *OrderBookGraph) Offers() []xdr.OfferEntry {
 	graph.lock.RLock()
@@ -250,6 +263,32 @@ func (graph *OrderBookGraph) FindPaths(
 	sourceAssetBalances []xdr.Int64,
 	validateSourceBalance bool,
 	maxAssetsPerPath int,
+) ([]Path, uint32, error) {
+	return graph.FindPathsWithLimits(
+		SearchLimits{},
+		maxPathLength,
+		destinationAsset,
+		destinationAmount,
+		sourceAccountID,
+		sourceAssets,
+		sourceAssetBalances,
+		validateSourceBalance,
+		maxAssetsPerPath,
+	)
+}
+
+// FindPathsWithLimits is like FindPaths but stops searching when any of the
+// `limits` is reached. In such case the best paths found so far are returned.
+func (graph *OrderBookGraph) FindPathsWithLimits(
+	limits SearchLimits,
+	maxPathLength int,
+	destinationAsset xdr.Asset,
+	destinationAmount xdr.Int64,
+	sourceAccountID *xdr.AccountId,
+	sourceAssets []xdr.Asset,
+	sourceAssetBalances []xdr.Int64,
+	validateSourceBalance bool,
+	maxAssetsPerPath int,
 ) ([]Path, uint32, error) {
 	destinationAssetString := destinationAsset.String()
 	sourceAssetsMap := map[string]xdr.Int64{}
@@ -268,11 +307,11 @@ func (graph *OrderBookGraph) FindPaths(
 		paths:                  []Path{},
 	}
 	graph.lock.RLock()
-	err := dfs(
+	err := search(
 		searchState,
 		maxPathLength,
-		map[string]bool{},
-		[]xdr.Asset{},
+		maxAssetsPerPath,
+		limits,
 		destinationAssetString,
 		destinationAsset,
 		destinationAmount,
@@ -302,6 +341,27 @@ func (graph *OrderBookGraph) FindFixedPaths(
 	amountToSpend xdr.Int64,
 	destinationAssets []xdr.Asset,
 	maxAssetsPerPath int,
+) ([]Path, uint32, error) {
+	return graph.FindFixedPathsWithLimits(
+		SearchLimits{},
+		maxPathLength,
+		sourceAsset,
+		amountToSpend,
+		destinationAssets,
+		maxAssetsPerPath,
+	)
+}
+
+// FindFixedPathsWithLimits is like FindFixedPaths but stops searching when any
+// of the `limits` is reached. In such case the best paths found so far are
+// returned.
+func (graph *OrderBookGraph) FindFixedPathsWithLimits(
+	limits SearchLimits,
+	maxPathLength int,
+	sourceAsset xdr.Asset,
+	amountToSpend xdr.Int64,
+	destinationAssets []xdr.Asset,
+	maxAssetsPerPath int,
 ) ([]Path, uint32, error) {
 	target := map[string]bool{}
 	for _, destinationAsset := range destinationAssets {
@@ -317,11 +377,11 @@ func (graph *OrderBookGraph) FindFixedPaths(
 		paths:             []Path{},
 	}
 	graph.lock.RLock()
-	err := dfs(
+	err := search(
 		searchState,
 		maxPathLength,
-		map[string]bool{},
-		[]xdr.Asset{},
+		maxAssetsPerPath,
+		limits,
 		sourceAsset.String(),
 		sourceAsset,
 		amountToSpend,
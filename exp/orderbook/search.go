@@ -1,6 +1,9 @@
 package orderbook
 
 import (
+	"sort"
+	"time"
+
 	"github.com/stellar/go/price"
 	"github.com/stellar/go/xdr"
 )
@@ -55,73 +58,192 @@ type searchState interface {
 		currentAssetAmount xdr.Int64,
 		offers []xdr.OfferEntry,
 	) (xdr.Asset, xdr.Int64, error)
+
+	// isBetterAmount returns true if a partial path ending with `amount` is
+	// better than a partial path ending with `otherAmount` of the same asset.
+	isBetterAmount(amount, otherAmount xdr.Int64) bool
+}
+
+// SearchLimits bounds the work done when searching for payment paths. When
+// any of the limits is reached the search stops and the best paths found so
+// far are returned. The zero value means no limits.
+type SearchLimits struct {
+	// Deadline is the time after which the search stops. Ignored if zero.
+	Deadline time.Time
+	// MaxIterations is the maximum number of partial paths expanded by the
+	// search. Ignored if zero or negative.
+	MaxIterations int
 }
 
-func dfs(
+// deadlineCheckInterval is the number of iterations between deadline checks.
+// time.Now() is not called on every iteration because it is relatively
+// expensive compared to expanding a partial path.
+const deadlineCheckInterval = 64
+
+func (limits SearchLimits) reached(iterations int) bool {
+	if limits.MaxIterations > 0 && iterations >= limits.MaxIterations {
+		return true
+	}
+	return !limits.Deadline.IsZero() &&
+		iterations%deadlineCheckInterval == 0 &&
+		time.Now().After(limits.Deadline)
+}
+
+// searchNode is a partial path explored by search. Partial paths share
+// prefixes so every node points to its parent instead of copying the list of
+// visited assets.
+type searchNode struct {
+	assetString string
+	asset       xdr.Asset
+	amount      xdr.Int64
+	depth       int
+	parent      *searchNode
+}
+
+func (node *searchNode) visited(assetString string) bool {
+	for current := node; current != nil; current = current.parent {
+		if current.assetString == assetString {
+			return true
+		}
+	}
+	return false
+}
+
+// assets returns all assets in the partial path, starting from the asset
+// where the search started.
+func (node *searchNode) assets() []xdr.Asset {
+	assets := make([]xdr.Asset, node.depth+1)
+	for current := node; current != nil; current = current.parent {
+		assets[current.depth] = current.asset
+	}
+	return assets
+}
+
+// search explores the graph level by level (partial paths with fewer hops
+// first) starting from `startAsset`. Instead of enumerating every path up to
+// `maxPathLength` hops, dominated partial paths are pruned: a partial path
+// ending with asset X is dropped when at least `maxPathsPerAsset` partial
+// paths ending with X, which are not longer, have already been found with an
+// amount at least as good. Given that at most `maxPathsPerAsset` paths are
+// returned per asset, such a partial path is unlikely to be extended to one of
+// the best paths. It is possible (but rare) that a pruned partial path was the
+// only way to reach some asset because better partial paths already visited
+// assets on the way.
+//
+// The search stops early, without an error, when any of the `limits` is
+// reached.
+func search(
 	state searchState,
 	maxPathLength int,
-	visited map[string]bool,
-	visitedList []xdr.Asset,
-	currentAssetString string,
-	currentAsset xdr.Asset,
-	currentAssetAmount xdr.Int64,
+	maxPathsPerAsset int,
+	limits SearchLimits,
+	startAssetString string,
+	startAsset xdr.Asset,
+	startAmount xdr.Int64,
 ) error {
-	if currentAssetAmount <= 0 {
-		return nil
-	}
-	if visited[currentAssetString] {
-		return nil
-	}
-	if len(visitedList) > maxPathLength {
+	if startAmount <= 0 {
 		return nil
 	}
-	visited[currentAssetString] = true
-	defer func() {
-		visited[currentAssetString] = false
-	}()
-
-	updatedVisitedList := append(visitedList, currentAsset)
-	if state.isTerminalNode(currentAssetString, currentAssetAmount) {
-		state.appendToPaths(
-			updatedVisitedList,
-			currentAssetString,
-			currentAssetAmount,
-		)
-	}
 
-	for nextAssetString, offers := range state.edges(currentAssetString) {
-		if len(offers) == 0 {
-			continue
-		}
+	// amounts of partial paths which were not pruned, by the last asset
+	found := map[string][]xdr.Int64{}
+	level := []*searchNode{{
+		assetString: startAssetString,
+		asset:       startAsset,
+		amount:      startAmount,
+	}}
+	iterations := 0
+
+	for len(level) > 0 {
+		// Expand the best partial paths first so that if the search stops
+		// early the best paths are likely to be found already.
+		sort.SliceStable(level, func(i, j int) bool {
+			if level[i].assetString != level[j].assetString {
+				return level[i].assetString < level[j].assetString
+			}
+			return state.isBetterAmount(level[i].amount, level[j].amount)
+		})
 
-		nextAsset, nextAssetAmount, err := state.consumeOffers(currentAssetAmount, offers)
-		if err != nil {
-			return err
-		}
-		if nextAssetAmount <= 0 {
-			continue
-		}
+		var nextLevel []*searchNode
+		for _, node := range level {
+			if limits.reached(iterations) {
+				return nil
+			}
+			iterations++
+
+			if state.isTerminalNode(node.assetString, node.amount) {
+				state.appendToPaths(
+					node.assets(),
+					node.assetString,
+					node.amount,
+				)
+			}
 
-		err = dfs(
-			state,
-			maxPathLength,
-			visited,
-			updatedVisitedList,
-			nextAssetString,
-			nextAsset,
-			nextAssetAmount,
-		)
-		if err != nil {
-			return err
+			if node.depth >= maxPathLength {
+				continue
+			}
+
+			for nextAssetString, offers := range state.edges(node.assetString) {
+				if len(offers) == 0 || node.visited(nextAssetString) {
+					continue
+				}
+
+				nextAsset, nextAssetAmount, err := state.consumeOffers(node.amount, offers)
+				if err != nil {
+					return err
+				}
+				if nextAssetAmount <= 0 {
+					continue
+				}
+
+				if isDominated(state, found[nextAssetString], nextAssetAmount, maxPathsPerAsset) {
+					continue
+				}
+				found[nextAssetString] = append(found[nextAssetString], nextAssetAmount)
+
+				nextLevel = append(nextLevel, &searchNode{
+					assetString: nextAssetString,
+					asset:       nextAsset,
+					amount:      nextAssetAmount,
+					depth:       node.depth + 1,
+					parent:      node,
+				})
+			}
 		}
+		level = nextLevel
 	}
 
 	return nil
 }
 
-// sellingGraphSearchState configures a DFS on the orderbook graph
+// isDominated returns true if at least `maxPathsPerAsset` amounts in `found`
+// are at least as good as `amount`. Nothing is dominated if
+// `maxPathsPerAsset` is not positive.
+func isDominated(
+	state searchState,
+	found []xdr.Int64,
+	amount xdr.Int64,
+	maxPathsPerAsset int,
+) bool {
+	if maxPathsPerAsset <= 0 {
+		return false
+	}
+
+	count := 0
+	for _, foundAmount := range found {
+		if !state.isBetterAmount(amount, foundAmount) {
+			count++
+			if count >= maxPathsPerAsset {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sellingGraphSearchState configures a search on the orderbook graph
 // where only edges in `graph.edgesForSellingAsset` are traversed.
-// The DFS maintains the following invariants:
+// The search maintains the following invariants:
 // no node is repeated
 // no offers are consumed from the `ignoreOffersFrom` account
 // each payment path must begin with an asset in `targetAssets`
@@ -176,6 +298,13 @@ func (state *sellingGraphSearchState) edges(currentAssetString string) edgeSet {
 	return state.graph.edgesForSellingAsset[currentAssetString]
 }
 
+// isBetterAmount returns true if `amount` is lower than `otherAmount`: the
+// selling search starts from the destination asset so the amount is the amount
+// of the current asset needed to receive the destination amount.
+func (state *sellingGraphSearchState) isBetterAmount(amount, otherAmount xdr.Int64) bool {
+	return amount < otherAmount
+}
+
 func (state *sellingGraphSearchState) consumeOffers(
 	currentAssetAmount xdr.Int64,
 	offers []xdr.OfferEntry,
@@ -189,9 +318,9 @@ func (state *sellingGraphSearchState) consumeOffers(
 	return nextAsset, nextAmount, err
 }
 
-// buyingGraphSearchState configures a DFS on the orderbook graph
+// buyingGraphSearchState configures a search on the orderbook graph
 // where only edges in `graph.edgesForBuyingAsset` are traversed.
-// The DFS maintains the following invariants:
+// The search maintains the following invariants:
 // no node is repeated
 // no offers are consumed from the `ignoreOffersFrom` account
 // each payment path must terminate with an asset in `targetAssets`
@@ -239,6 +368,13 @@ func (state *buyingGraphSearchState) edges(currentAsset string) edgeSet {
 	return state.graph.edgesForBuyingAsset[currentAsset]
 }
 
+// isBetterAmount returns true if `amount` is higher than `otherAmount`: the
+// buying search starts from the source asset so the amount is the amount of
+// the current asset received for the source amount.
+func (state *buyingGraphSearchState) isBetterAmount(amount, otherAmount xdr.Int64) bool {
+	return amount > otherAmount
+}
+
 func (state *buyingGraphSearchState) consumeOffers(
 	currentAssetAmount xdr.Int64,
 	offers []xdr.OfferEntry,
@@ -0,0 +1,167 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stellar/go/xdr"
+)
+
+func searchTestGraph(t *testing.T) *OrderBookGraph {
+	usdEurOffer := xdr.OfferEntry{
+		SellerId: issuer,
+		OfferId:  xdr.Int64(9),
+		Buying:   usdAsset,
+		Selling:  eurAsset,
+		Price: xdr.Price{
+			N: 1,
+			D: 1,
+		},
+		Amount: xdr.Int64(500),
+	}
+
+	graph := NewOrderBookGraph()
+	err := graph.
+		AddOffer(dollarOffer).
+		AddOffer(eurOffer).
+		AddOffer(usdEurOffer).
+		Apply(1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return graph
+}
+
+func TestFindFixedPathsPrunesDominatedPaths(t *testing.T) {
+	graph := searchTestGraph(t)
+
+	directPath := Path{
+		SourceAmount:      5,
+		SourceAsset:       usdAsset,
+		InteriorNodes:     []xdr.Asset{},
+		DestinationAsset:  nativeAsset,
+		DestinationAmount: 5,
+	}
+	eurPath := Path{
+		SourceAmount:      5,
+		SourceAsset:       usdAsset,
+		InteriorNodes:     []xdr.Asset{eurAsset},
+		DestinationAsset:  nativeAsset,
+		DestinationAmount: 5,
+	}
+
+	paths, _, err := graph.FindFixedPaths(3, usdAsset, 5, []xdr.Asset{nativeAsset}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assertPathEquals(t, paths, []Path{directPath, eurPath})
+
+	// The path through EUR is dominated by the direct path which delivers
+	// the same amount with fewer hops.
+	paths, _, err = graph.FindFixedPaths(3, usdAsset, 5, []xdr.Asset{nativeAsset}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assertPathEquals(t, paths, []Path{directPath})
+}
+
+func TestFindFixedPathsWithLimits(t *testing.T) {
+	graph := searchTestGraph(t)
+
+	expectedPaths, _, err := graph.FindFixedPaths(3, usdAsset, 5, []xdr.Asset{nativeAsset}, 5)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, testCase := range []struct {
+		name     string
+		limits   SearchLimits
+		expected []Path
+	}{
+		{
+			"no limits",
+			SearchLimits{},
+			expectedPaths,
+		},
+		{
+			"high limits",
+			SearchLimits{Deadline: time.Now().Add(time.Hour), MaxIterations: 1000},
+			expectedPaths,
+		},
+		{
+			// only the source asset is expanded
+			"one iteration",
+			SearchLimits{MaxIterations: 1},
+			[]Path{},
+		},
+		{
+			// EUR and native assets reached from USD are expanded, EUR first
+			"three iterations",
+			SearchLimits{MaxIterations: 3},
+			expectedPaths[:1],
+		},
+		{
+			"deadline exceeded",
+			SearchLimits{Deadline: time.Now().Add(-time.Second)},
+			[]Path{},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			paths, lastLedger, err := graph.FindFixedPathsWithLimits(
+				testCase.limits,
+				3,
+				usdAsset,
+				5,
+				[]xdr.Asset{nativeAsset},
+				5,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if lastLedger != 1 {
+				t.Fatalf("expected last ledger to be %v but got %v", 1, lastLedger)
+			}
+			assertPathEquals(t, paths, testCase.expected)
+		})
+	}
+}
+
+func TestFindPathsWithLimits(t *testing.T) {
+	graph := searchTestGraph(t)
+
+	paths, _, err := graph.FindPathsWithLimits(
+		SearchLimits{MaxIterations: 1000},
+		3,
+		nativeAsset,
+		5,
+		nil,
+		[]xdr.Asset{usdAsset},
+		[]xdr.Int64{0},
+		false,
+		5,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("expected %v paths but got %v", 2, paths)
+	}
+
+	paths, _, err = graph.FindPathsWithLimits(
+		SearchLimits{Deadline: time.Now().Add(-time.Second)},
+		3,
+		nativeAsset,
+		5,
+		nil,
+		[]xdr.Asset{usdAsset},
+		[]xdr.Int64{0},
+		false,
+		5,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected no paths but got %v", paths)
+	}
+}
@@ -0,0 +1,140 @@
+package orderbook
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/files"
+	"github.com/stellar/go/xdr"
+)
+
+// snapshotMagic is written at the beginning of every snapshot. The last two
+// characters are the version of the snapshot format.
+var snapshotMagic = [8]byte{'O', 'B', 'S', 'N', 'A', 'P', '0', '1'}
+
+var errInvalidSnapshotChecksum = errors.New("snapshot checksum does not match")
+
+// LastLedger returns the sequence of the last ledger applied to the graph or
+// 0 if no ledgers have been applied yet.
+func (graph *OrderBookGraph) LastLedger() uint32 {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+	return graph.lastLedger
+}
+
+// WriteSnapshot writes all offers in the graph and the last applied ledger to
+// `w`. Snapshot format:
+//
+//   - 8 bytes: "OBSNAP01",
+//   - 4 bytes: last ledger (big-endian),
+//   - 4 bytes: number of offers (big-endian),
+//   - XDR-encoded offers,
+//   - 32 bytes: SHA-256 of all the previous bytes.
+//
+// Operations queued but not applied are not included in the snapshot.
+func (graph *OrderBookGraph) WriteSnapshot(w io.Writer) error {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	hash := sha256.New()
+	writer := bufio.NewWriter(io.MultiWriter(w, hash))
+
+	var header [16]byte
+	copy(header[:8], snapshotMagic[:])
+	binary.BigEndian.PutUint32(header[8:12], graph.lastLedger)
+	binary.BigEndian.PutUint32(header[12:16], uint32(len(graph.tradingPairForOffer)))
+	if _, err := writer.Write(header[:]); err != nil {
+		return errors.Wrap(err, "could not write snapshot header")
+	}
+
+	for _, edges := range graph.edgesForSellingAsset {
+		for _, offers := range edges {
+			for _, offer := range offers {
+				if _, err := xdr.Marshal(writer, offer); err != nil {
+					return errors.Wrap(err, "could not write offer")
+				}
+			}
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return errors.Wrap(err, "could not write snapshot")
+	}
+
+	if _, err := w.Write(hash.Sum(nil)); err != nil {
+		return errors.Wrap(err, "could not write snapshot checksum")
+	}
+	return nil
+}
+
+// ReadSnapshot replaces all offers in the graph and the last applied ledger
+// with the contents of a snapshot written by WriteSnapshot. Operations queued
+// but not applied are discarded. The graph is not modified if the snapshot is
+// invalid.
+func (graph *OrderBookGraph) ReadSnapshot(r io.Reader) error {
+	hash := sha256.New()
+	reader := bufio.NewReader(r)
+	hashingReader := io.TeeReader(reader, hash)
+
+	var header [16]byte
+	if _, err := io.ReadFull(hashingReader, header[:]); err != nil {
+		return errors.Wrap(err, "could not read snapshot header")
+	}
+	if !bytes.Equal(header[:8], snapshotMagic[:]) {
+		return errors.New("invalid snapshot header")
+	}
+	lastLedger := binary.BigEndian.Uint32(header[8:12])
+	count := binary.BigEndian.Uint32(header[12:16])
+
+	loaded := NewOrderBookGraph()
+	for i := uint32(0); i < count; i++ {
+		var offer xdr.OfferEntry
+		if _, err := xdr.Unmarshal(hashingReader, &offer); err != nil {
+			return errors.Wrap(err, "could not read offer")
+		}
+		if err := loaded.add(offer); err != nil {
+			return errors.Wrap(err, "could not add offer")
+		}
+	}
+
+	var checksum [sha256.Size]byte
+	if _, err := io.ReadFull(reader, checksum[:]); err != nil {
+		return errors.Wrap(err, "could not read snapshot checksum")
+	}
+	if !bytes.Equal(checksum[:], hash.Sum(nil)) {
+		return errInvalidSnapshotChecksum
+	}
+
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	graph.edgesForSellingAsset = loaded.edgesForSellingAsset
+	graph.edgesForBuyingAsset = loaded.edgesForBuyingAsset
+	graph.tradingPairForOffer = loaded.tradingPairForOffer
+	graph.lastLedger = lastLedger
+	graph.batchedUpdates = graph.batch()
+	return nil
+}
+
+// WriteSnapshotFile writes a snapshot of the graph to a file at `path`. The
+// previous snapshot is preserved if writing fails, see files.WriteAtomic.
+func (graph *OrderBookGraph) WriteSnapshotFile(path string) error {
+	return files.WriteAtomic(path, 0600, graph.WriteSnapshot)
+}
+
+// ReadSnapshotFile reads a snapshot written by WriteSnapshotFile, see
+// ReadSnapshot.
+func (graph *OrderBookGraph) ReadSnapshotFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "could not open snapshot file")
+	}
+	defer file.Close()
+
+	return graph.ReadSnapshot(file)
+}
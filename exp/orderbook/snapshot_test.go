@@ -0,0 +1,174 @@
+package orderbook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+)
+
+func TestSnapshot(t *testing.T) {
+	graph := NewOrderBookGraph()
+	err := graph.
+		AddOffer(dollarOffer).
+		AddOffer(threeEurOffer).
+		AddOffer(eurOffer).
+		AddOffer(twoEurOffer).
+		AddOffer(quarterOffer).
+		AddOffer(fiftyCentsOffer).
+		Apply(10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = graph.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	loaded := NewOrderBookGraph()
+	// Pending operations are discarded
+	loaded.AddOffer(dollarOffer)
+	if err = loaded.ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assertGraphEquals(t, graph, loaded)
+	if loaded.LastLedger() != 10 {
+		t.Fatalf("expected last ledger to be %v but got %v", 10, loaded.LastLedger())
+	}
+
+	// Loaded graph can be updated
+	err = loaded.
+		RemoveOffer(dollarOffer.OfferId).
+		Apply(11)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(loaded.Offers()) != 5 {
+		t.Fatalf("expected %v offers but got %v", 5, len(loaded.Offers()))
+	}
+}
+
+func TestSnapshotEmptyGraph(t *testing.T) {
+	graph := NewOrderBookGraph()
+
+	var buf bytes.Buffer
+	if err := graph.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	loaded := NewOrderBookGraph()
+	if err := loaded.ReadSnapshot(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !loaded.IsEmpty() {
+		t.Fatal("expected graph to be empty")
+	}
+	if loaded.LastLedger() != 0 {
+		t.Fatalf("expected last ledger to be %v but got %v", 0, loaded.LastLedger())
+	}
+}
+
+func TestInvalidSnapshot(t *testing.T) {
+	graph := NewOrderBookGraph()
+	err := graph.
+		AddOffer(dollarOffer).
+		AddOffer(eurOffer).
+		Apply(10)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = graph.WriteSnapshot(&buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	loaded := NewOrderBookGraph()
+	if err = loaded.AddOffer(quarterOffer).Apply(5); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Corrupted offer
+	corrupted := append([]byte{}, snapshot...)
+	corrupted[len(corrupted)-40]++
+	if err = loaded.ReadSnapshot(bytes.NewReader(corrupted)); err != errInvalidSnapshotChecksum {
+		t.Fatalf("expected error %v but got %v", errInvalidSnapshotChecksum, err)
+	}
+
+	// Truncated
+	if err = loaded.ReadSnapshot(bytes.NewReader(snapshot[:len(snapshot)-1])); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Invalid header
+	if err = loaded.ReadSnapshot(bytes.NewReader([]byte("invalid snapshot"))); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Graph is not modified
+	if loaded.LastLedger() != 5 {
+		t.Fatalf("expected last ledger to be %v but got %v", 5, loaded.LastLedger())
+	}
+	assertOfferListEquals(t, loaded.Offers(), []xdr.OfferEntry{quarterOffer})
+}
+
+func TestSnapshotFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orderbook-snapshot")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orderbook.snapshot")
+
+	graph := NewOrderBookGraph()
+	if err = graph.AddOffer(eurOffer).Apply(3); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = graph.WriteSnapshotFile(path); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	loaded := NewOrderBookGraph()
+	if err = loaded.ReadSnapshotFile(path); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assertGraphEquals(t, graph, loaded)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the snapshot file but got %v files", len(files))
+	}
+
+	if err = loaded.ReadSnapshotFile(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestClear(t *testing.T) {
+	graph := NewOrderBookGraph()
+	if err := graph.AddOffer(eurOffer).Apply(3); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	graph.AddOffer(dollarOffer).Clear()
+	if !graph.IsEmpty() {
+		t.Fatal("expected graph to be empty")
+	}
+	if graph.LastLedger() != 0 {
+		t.Fatalf("expected last ledger to be %v but got %v", 0, graph.LastLedger())
+	}
+
+	// Any ledger can be applied after Clear
+	if err := graph.AddOffer(dollarOffer).Apply(10); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	assertOfferListEquals(t, graph.Offers(), []xdr.OfferEntry{dollarOffer})
+}
@@ -38,10 +38,27 @@ type BufferedReadWriter struct {
 
 type multiWriter struct {
 	writers []Writer
+	// predicates decide which writers an entry is written to. nil predicate
+	// matches all entries.
+	predicates []func(entry interface{}) bool
+	// firstMatch is true when an entry should be written only to the first
+	// writer with matching predicate.
+	firstMatch bool
 
 	mutex        sync.Mutex
 	closeAfter   int
 	wroteEntries int
+	// closed contains writers that returned `io.ErrClosedPipe`.
+	closed map[int]bool
+}
+
+// fanInWriter is an input of a node with many parents. The node input buffer
+// is closed when all parents close their writers.
+type fanInWriter struct {
+	*BufferedReadWriter
+
+	mutex   sync.Mutex
+	parents int
 }
 
 type Pipeline struct {
@@ -57,6 +74,15 @@ type Pipeline struct {
 	shutDown   bool
 	cancelled  bool
 	cancelFunc context.CancelFunc
+
+	// inputsMutex protects parents and fanInInputs that are accessed when
+	// starting nodes.
+	inputsMutex sync.Mutex
+	// parents contains the number of parents of every node, nodes with more
+	// than one parent are fan-in nodes.
+	parents map[*PipelineNode]int
+	// fanInInputs contains inputs of fan-in nodes in the current run.
+	fanInInputs map[*PipelineNode]*fanInWriter
 }
 
 // PipelineInterface is an interface that defines common pipeline methods
@@ -70,20 +96,65 @@ type PipelineInterface interface {
 	AddPostProcessingHook(hook func(context.Context, error) error)
 	Shutdown()
 	PrintStatus()
+	// Metrics returns metrics of all nodes in the pipeline.
+	Metrics() NodeMetrics
 }
 
 var _ PipelineInterface = &Pipeline{}
 
+// PipelineNode is a node of a processing pipeline. Every entry written by the
+// node processor is passed to all children (see Pipe), children matching the
+// entry (see Where) or the first matching child (see Route).
+//
+// A node can be a child of many nodes (fan-in). Such node is run once and
+// reads entries written by all its parents. Its input is closed when all
+// parents close their writers.
 type PipelineNode struct {
-	// Remember to update reset() method if you ever add a new field to this struct!
 	Processor Processor
 	Children  []*PipelineNode
+	// ErrorPolicy defines what happens when the processor reports an error
+	// processing a single entry using HandleEntryError. Errors returned from
+	// Process() always stop the pipeline.
+	ErrorPolicy ErrorPolicy
+	// DeadLetterHandler is called with entries that failed processing when
+	// ErrorPolicy is DeadLetterOnError. It can be called concurrently by many
+	// nodes.
+	DeadLetterHandler func(DeadLetter)
 
-	readEntries     int
-	readsPerSecond  int
-	queuedEntries   int
-	wroteEntries    int
-	writesPerSecond int
+	// predicate decides which entries written by parents are passed to the
+	// node, nil means all entries.
+	predicate func(entry interface{}) bool
+	// routeFirstMatch is true when entries are passed only to the first child
+	// with matching predicate.
+	routeFirstMatch bool
+
+	// metricsMutex protects metrics which are updated by the processor
+	// go routine and read by Pipeline.Metrics().
+	metricsMutex sync.Mutex
+	metrics      nodeMetrics
+}
+
+// ErrorPolicy defines how entry errors reported using HandleEntryError are
+// handled by a PipelineNode.
+type ErrorPolicy int
+
+const (
+	// FailOnError returns the error to the processor which should stop
+	// processing and return it. This is the default policy.
+	FailOnError ErrorPolicy = iota
+	// SkipOnError logs the error and skips the entry.
+	SkipOnError
+	// DeadLetterOnError skips the entry and passes it to the node
+	// DeadLetterHandler.
+	DeadLetterOnError
+)
+
+// DeadLetter is an entry that a processor failed to process.
+type DeadLetter struct {
+	// Processor is the name of the processor that failed.
+	Processor string
+	Entry     interface{}
+	Err       error
 }
 
 // Reader interface placeholder
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NodeMetrics contains metrics of a single PipelineNode. Counters and
+// durations are accumulated over all pipeline runs.
+//
+// Time blocked on read and write helps finding bottlenecks in a pipeline:
+// a node that spends most of the processing time blocked on write is waiting
+// for slow children (backpressure), a node that spends most of the processing
+// time blocked on read is waiting for its parent (or the pipeline reader in
+// case of the root node).
+type NodeMetrics struct {
+	// ID identifies the node in the pipeline tree. It consists of indexes of
+	// nodes on the path from the root node separated with "/". The root node
+	// ID is "0", the ID of the second child of the root node is "0/1".
+	ID string
+	// Processor is the name of the node processor.
+	Processor string
+	// Running is true when the node processor is running.
+	Running bool
+	// Runs is the number of times the node processor has been run.
+	Runs int64
+	// ReadEntries is the number of entries read by the processor.
+	ReadEntries int64
+	// WroteEntries is the number of entries written by the processor.
+	WroteEntries int64
+	// ErroredEntries is the number of entries skipped or sent to the dead
+	// letter handler, see ErrorPolicy.
+	ErroredEntries int64
+	// ReadBlocked is the time the processor spent waiting in Reader.Read().
+	ReadBlocked time.Duration
+	// WriteBlocked is the time the processor spent waiting in Writer.Write().
+	// Writes are blocked when an input buffer of any of the children is full.
+	WriteBlocked time.Duration
+	// Processing is the time the processor spent in Process(), including the
+	// current run.
+	Processing time.Duration
+	// QueuedEntries is the number of entries waiting in the node input
+	// buffer. It's always 0 for the root node which reads from the pipeline
+	// reader directly.
+	QueuedEntries int
+	// BufferSize is the capacity of the node input buffer or 0 for the root
+	// node.
+	BufferSize int
+	// Children contains metrics of node children. Fan-in nodes are reported
+	// once, as a child of the first parent in depth-first order.
+	Children []NodeMetrics
+}
+
+// nodeMetrics contains metrics collected by PipelineNode.
+type nodeMetrics struct {
+	runs           int64
+	readEntries    int64
+	wroteEntries   int64
+	erroredEntries int64
+	readBlocked    time.Duration
+	writeBlocked   time.Duration
+	processing     time.Duration
+	// runStart is a start time of the current run or zero value when the
+	// processor is not running.
+	runStart time.Time
+	// input is the node input buffer in the current or the last run.
+	input *BufferedReadWriter
+}
+
+// meteredReader wraps a node Reader and updates node metrics.
+type meteredReader struct {
+	Reader
+	node *PipelineNode
+}
+
+// meteredWriter wraps a node Writer and updates node metrics.
+type meteredWriter struct {
+	Writer
+	node *PipelineNode
+}
+
+// UnwrapReader returns a Reader wrapped by the pipeline to collect metrics.
+// Processors that need to type assert a Reader passed to Process() should
+// call it first. If `r` is not wrapped it is returned as is.
+func UnwrapReader(r Reader) Reader {
+	if m, ok := r.(*meteredReader); ok {
+		return m.Reader
+	}
+	return r
+}
+
+func (r *meteredReader) Read() (interface{}, error) {
+	start := time.Now()
+	entry, err := r.Reader.Read()
+	elapsed := time.Since(start)
+
+	r.node.metricsMutex.Lock()
+	defer r.node.metricsMutex.Unlock()
+	r.node.metrics.readBlocked += elapsed
+	if err == nil {
+		r.node.metrics.readEntries++
+	}
+	return entry, err
+}
+
+func (w *meteredWriter) Write(entry interface{}) error {
+	start := time.Now()
+	err := w.Writer.Write(entry)
+	elapsed := time.Since(start)
+
+	w.node.metricsMutex.Lock()
+	defer w.node.metricsMutex.Unlock()
+	w.node.metrics.writeBlocked += elapsed
+	if err == nil {
+		w.node.metrics.wroteEntries++
+	}
+	return err
+}
+
+// Metrics returns metrics of all nodes in the pipeline. It's safe to call it
+// when the pipeline is running.
+func (p *Pipeline) Metrics() NodeMetrics {
+	return p.root.nodeMetrics("0", make(map[*PipelineNode]bool))
+}
+
+func (p *PipelineNode) startRun(input *BufferedReadWriter) {
+	p.metricsMutex.Lock()
+	defer p.metricsMutex.Unlock()
+	p.metrics.runs++
+	p.metrics.runStart = time.Now()
+	p.metrics.input = input
+}
+
+func (p *PipelineNode) endRun() {
+	p.metricsMutex.Lock()
+	defer p.metricsMutex.Unlock()
+	p.metrics.processing += time.Since(p.metrics.runStart)
+	p.metrics.runStart = time.Time{}
+}
+
+func (p *PipelineNode) nodeMetrics(id string, visited map[*PipelineNode]bool) NodeMetrics {
+	visited[p] = true
+
+	p.metricsMutex.Lock()
+	metrics := NodeMetrics{
+		ID:             id,
+		Processor:      p.Processor.Name(),
+		Running:        !p.metrics.runStart.IsZero(),
+		Runs:           p.metrics.runs,
+		ReadEntries:    p.metrics.readEntries,
+		WroteEntries:   p.metrics.wroteEntries,
+		ErroredEntries: p.metrics.erroredEntries,
+		ReadBlocked:    p.metrics.readBlocked,
+		WriteBlocked:   p.metrics.writeBlocked,
+		Processing:     p.metrics.processing,
+	}
+	if metrics.Running {
+		metrics.Processing += time.Since(p.metrics.runStart)
+	}
+	input := p.metrics.input
+	p.metricsMutex.Unlock()
+
+	if input != nil {
+		metrics.QueuedEntries = input.QueuedEntries()
+		metrics.BufferSize = bufferSize
+	}
+
+	for i, child := range p.Children {
+		if visited[child] {
+			continue
+		}
+
+		metrics.Children = append(
+			metrics.Children,
+			child.nodeMetrics(id+"/"+strconv.Itoa(i), visited),
+		)
+	}
+
+	return metrics
+}
+
+type prometheusMetric struct {
+	name     string
+	help     string
+	kind     string
+	getValue func(m NodeMetrics) float64
+}
+
+var prometheusMetrics = []prometheusMetric{
+	{
+		"pipeline_node_runs_total", "Number of processor runs.", "counter",
+		func(m NodeMetrics) float64 { return float64(m.Runs) },
+	},
+	{
+		"pipeline_node_read_entries_total", "Number of entries read by the processor.", "counter",
+		func(m NodeMetrics) float64 { return float64(m.ReadEntries) },
+	},
+	{
+		"pipeline_node_wrote_entries_total", "Number of entries written by the processor.", "counter",
+		func(m NodeMetrics) float64 { return float64(m.WroteEntries) },
+	},
+	{
+		"pipeline_node_errored_entries_total", "Number of entries skipped or sent to the dead letter handler.", "counter",
+		func(m NodeMetrics) float64 { return float64(m.ErroredEntries) },
+	},
+	{
+		"pipeline_node_read_blocked_seconds_total", "Time the processor spent waiting for entries to read.", "counter",
+		func(m NodeMetrics) float64 { return m.ReadBlocked.Seconds() },
+	},
+	{
+		"pipeline_node_write_blocked_seconds_total", "Time the processor spent waiting for children to accept written entries.", "counter",
+		func(m NodeMetrics) float64 { return m.WriteBlocked.Seconds() },
+	},
+	{
+		"pipeline_node_processing_seconds_total", "Time the processor spent processing.", "counter",
+		func(m NodeMetrics) float64 { return m.Processing.Seconds() },
+	},
+	{
+		"pipeline_node_queued_entries", "Number of entries waiting in the node input buffer.", "gauge",
+		func(m NodeMetrics) float64 { return float64(m.QueuedEntries) },
+	},
+	{
+		"pipeline_node_buffer_size", "Capacity of the node input buffer.", "gauge",
+		func(m NodeMetrics) float64 { return float64(m.BufferSize) },
+	},
+	{
+		"pipeline_node_running", "1 if the processor is running, 0 otherwise.", "gauge",
+		func(m NodeMetrics) float64 {
+			if m.Running {
+				return 1
+			}
+			return 0
+		},
+	},
+}
+
+// WritePrometheus writes metrics of pipelines in `pipelines` (pipeline name =>
+// metrics returned by Pipeline.Metrics()) to `w` in Prometheus text exposition
+// format. Every sample is labeled with `pipeline`, `node` (NodeMetrics.ID) and
+// `processor` labels.
+func WritePrometheus(w io.Writer, pipelines map[string]NodeMetrics) error {
+	names := make([]string, 0, len(pipelines))
+	for name := range pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, metric := range prometheusMetrics {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			err = writePrometheusNode(w, metric, name, pipelines[name])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writePrometheusNode(w io.Writer, metric prometheusMetric, pipelineName string, node NodeMetrics) error {
+	_, err := fmt.Fprintf(
+		w,
+		"%s{pipeline=\"%s\",node=\"%s\",processor=\"%s\"} %s\n",
+		metric.name,
+		escapePrometheusLabel(pipelineName),
+		escapePrometheusLabel(node.ID),
+		escapePrometheusLabel(node.Processor),
+		strconv.FormatFloat(metric.getValue(node), 'g', -1, 64),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range node.Children {
+		err = writePrometheusNode(w, metric, pipelineName, child)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapePrometheusLabel(value string) string {
+	return prometheusLabelReplacer.Replace(value)
+}
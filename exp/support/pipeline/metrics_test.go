@@ -0,0 +1,110 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stellar/go/exp/support/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineMetrics(t *testing.T) {
+	p := pipeline.New(
+		pipeline.Node(&PassthroughProcessor{}).
+			Pipe(
+				pipeline.Node(&NoOpProcessor{}),
+				pipeline.Node(&NoOpProcessor{}),
+			),
+	)
+
+	assert.NoError(t, <-p.Process(&SimpleReader{CountObject: 10}))
+	assert.NoError(t, <-p.Process(&SimpleReader{CountObject: 20}))
+
+	metrics := p.Metrics()
+	assert.Equal(t, "0", metrics.ID)
+	assert.Equal(t, "PassthroughProcessor", metrics.Processor)
+	assert.False(t, metrics.Running)
+	assert.Equal(t, int64(2), metrics.Runs)
+	assert.Equal(t, int64(30), metrics.ReadEntries)
+	assert.Equal(t, int64(30), metrics.WroteEntries)
+	assert.Equal(t, 0, metrics.BufferSize)
+	assert.True(t, metrics.Processing > 0)
+	assert.True(t, metrics.Processing >= metrics.ReadBlocked+metrics.WriteBlocked)
+
+	assert.Len(t, metrics.Children, 2)
+	for i, child := range metrics.Children {
+		assert.Equal(t, []string{"0/0", "0/1"}[i], child.ID)
+		assert.Equal(t, int64(2), child.Runs)
+		assert.Equal(t, int64(30), child.ReadEntries)
+		assert.Equal(t, int64(0), child.WroteEntries)
+		assert.Equal(t, 0, child.QueuedEntries)
+		assert.True(t, child.BufferSize > 0)
+		assert.Len(t, child.Children, 0)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	metrics := pipeline.NodeMetrics{
+		ID:           "0",
+		Processor:    "Root",
+		Runs:         1,
+		ReadEntries:  10,
+		WroteEntries: 5,
+		Children: []pipeline.NodeMetrics{
+			{
+				ID:            "0/0",
+				Processor:     `Child "A"`,
+				Running:       true,
+				QueuedEntries: 3,
+				BufferSize:    50000,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := pipeline.WritePrometheus(&buf, map[string]pipeline.NodeMetrics{"state": metrics})
+	assert.NoError(t, err)
+
+	output := buf.String()
+	assert.Contains(t, output, "# TYPE pipeline_node_read_entries_total counter\n")
+	assert.Contains(t, output, "pipeline_node_read_entries_total{pipeline=\"state\",node=\"0\",processor=\"Root\"} 10\n")
+	assert.Contains(t, output, "pipeline_node_wrote_entries_total{pipeline=\"state\",node=\"0\",processor=\"Root\"} 5\n")
+	assert.Contains(t, output, "pipeline_node_queued_entries{pipeline=\"state\",node=\"0/0\",processor=\"Child \\\"A\\\"\"} 3\n")
+	assert.Contains(t, output, "pipeline_node_buffer_size{pipeline=\"state\",node=\"0/0\",processor=\"Child \\\"A\\\"\"} 50000\n")
+	assert.Contains(t, output, "pipeline_node_running{pipeline=\"state\",node=\"0/0\",processor=\"Child \\\"A\\\"\"} 1\n")
+}
+
+type PassthroughProcessor struct{}
+
+func (p *PassthroughProcessor) Process(ctx context.Context, store *pipeline.Store, r pipeline.Reader, w pipeline.Writer) error {
+	defer r.Close()
+	defer w.Close()
+
+	for {
+		entry, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		err = w.Write(entry)
+		if err != nil {
+			if err == io.ErrClosedPipe {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PassthroughProcessor) Name() string {
+	return "PassthroughProcessor"
+}
+
+func (p *PassthroughProcessor) Reset() {}
@@ -6,43 +6,76 @@ import (
 	"github.com/stellar/go/support/errors"
 )
 
+type multiWriterResult struct {
+	index int
+	err   error
+}
+
 func (m *multiWriter) Write(entry interface{}) error {
 	m.mutex.Lock()
 	m.wroteEntries++
 	m.mutex.Unlock()
 
-	results := make(chan error, len(m.writers))
+	// There are no active readers when there are no writers.
+	if len(m.writers) == 0 {
+		return io.ErrClosedPipe
+	}
+
+	targets := m.targets(entry)
+	results := make(chan multiWriterResult, len(targets))
 
-	for _, w := range m.writers {
-		go func(w Writer) {
+	for _, i := range targets {
+		go func(i int) {
 			// We can keep sending entries even when io.ErrClosedPipe is returned
 			// as bufferedStateReadWriter will ignore them (won't add them to
 			// a channel).
-			results <- w.Write(entry)
-		}(w)
+			results <- multiWriterResult{i, m.writers[i].Write(entry)}
+		}(i)
 	}
 
-	countClosedPipes := 0
-	for range m.writers {
-		err := <-results
-		if err != nil {
-			if err == io.ErrClosedPipe {
-				countClosedPipes++
+	for range targets {
+		result := <-results
+		if result.err != nil {
+			if result.err == io.ErrClosedPipe {
+				m.mutex.Lock()
+				if m.closed == nil {
+					m.closed = make(map[int]bool)
+				}
+				m.closed[result.index] = true
+				m.mutex.Unlock()
 			} else {
-				return err
+				return result.err
 			}
 		}
 	}
 
 	// When all pipes are closed return `io.ErrClosedPipe` because there are no
 	// active readers anymore.
-	if countClosedPipes == len(m.writers) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.closed) == len(m.writers) {
 		return io.ErrClosedPipe
 	}
 
 	return nil
 }
 
+// targets returns indexes of writers `entry` should be written to.
+func (m *multiWriter) targets(entry interface{}) []int {
+	targets := make([]int, 0, len(m.writers))
+	for i := range m.writers {
+		if len(m.predicates) > i && m.predicates[i] != nil && !m.predicates[i](entry) {
+			continue
+		}
+
+		targets = append(targets, i)
+		if m.firstMatch {
+			break
+		}
+	}
+	return targets
+}
+
 func (m *multiWriter) Close() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -64,4 +97,18 @@ func (m *multiWriter) Close() error {
 	return nil
 }
 
+// Close closes the node input buffer when called by the last parent.
+func (f *fanInWriter) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.parents--
+	if f.parents > 0 {
+		return nil
+	}
+
+	return f.BufferedReadWriter.Close()
+}
+
 var _ Writer = &multiWriter{}
+var _ Writer = &fanInWriter{}
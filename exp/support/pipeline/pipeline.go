@@ -20,7 +20,7 @@ func Node(processor Processor) *PipelineNode {
 }
 
 func (p *Pipeline) PrintStatus() {
-	p.printNodeStatus(p.root, 0)
+	p.printNodeStatus(p.Metrics(), 0)
 }
 
 // AddPreProcessingHook adds post-processing hook. Context will be a main
@@ -36,28 +36,30 @@ func (p *Pipeline) AddPostProcessingHook(hook func(context.Context, error) error
 	p.postProcessingHooks = append(p.postProcessingHooks, hook)
 }
 
-func (p *Pipeline) printNodeStatus(node *PipelineNode, level int) {
+func (p *Pipeline) printNodeStatus(node NodeMetrics, level int) {
 	fmt.Print(strings.Repeat("  ", level))
 
 	var wrRatio = float32(0)
-	if node.readEntries > 0 {
-		wrRatio = float32(node.wroteEntries) / float32(node.readEntries)
+	if node.ReadEntries > 0 {
+		wrRatio = float32(node.WroteEntries) / float32(node.ReadEntries)
 	}
 
 	icon := ""
-	if node.queuedEntries > bufferSize/10*9 {
+	if node.QueuedEntries > bufferSize/10*9 {
 		icon = "⚠️ "
 	}
 
 	fmt.Printf(
-		"└ %s%s read=%d (queued=%d rps=%d) wrote=%d (w/r ratio = %1.5f)\n",
+		"└ %s%s read=%d (queued=%d blocked=%s) wrote=%d (blocked=%s) (w/r ratio = %1.5f) processing=%s\n",
 		icon,
-		node.Processor.Name(),
-		node.readEntries,
-		node.queuedEntries,
-		node.readsPerSecond,
-		node.wroteEntries,
+		node.Processor,
+		node.ReadEntries,
+		node.QueuedEntries,
+		node.ReadBlocked,
+		node.WroteEntries,
+		node.WriteBlocked,
 		wrRatio,
+		node.Processing,
 	)
 
 	for _, child := range node.Children {
@@ -96,7 +98,12 @@ func (p *Pipeline) IsRunning() bool {
 // reset resets internal state of the pipeline and all the nodes and processors.
 func (p *Pipeline) reset() {
 	p.cancelled = false
-	p.resetNode(p.root)
+
+	p.inputsMutex.Lock()
+	defer p.inputsMutex.Unlock()
+	p.parents = make(map[*PipelineNode]int)
+	p.fanInInputs = make(map[*PipelineNode]*fanInWriter)
+	p.resetNode(p.root, make(map[*PipelineNode]bool))
 }
 
 func (p *Pipeline) sendPreProcessingHooks(ctx context.Context) (context.Context, error) {
@@ -124,14 +131,46 @@ func (p *Pipeline) sendPostProcessingHooks(ctx context.Context, processingError
 }
 
 // resetNode resets internal state of the pipeline node and internal processor and
-// calls itself recursively on all of the children.
-func (p *Pipeline) resetNode(node *PipelineNode) {
+// calls itself recursively on all of the children. It also counts parents of
+// every node.
+func (p *Pipeline) resetNode(node *PipelineNode, visited map[*PipelineNode]bool) {
+	if visited[node] {
+		return
+	}
+	visited[node] = true
+
 	node.reset()
 	for _, child := range node.Children {
-		p.resetNode(child)
+		p.parents[child]++
+		p.resetNode(child, visited)
 	}
 }
 
+// nodeInput returns a Writer of `node` input buffer. Nodes with a single
+// parent get a new buffer. All parents of a fan-in node get the same Writer,
+// the second returned value is true only for the first parent which should
+// start the node.
+func (p *Pipeline) nodeInput(ctx context.Context, node *PipelineNode) (Writer, *BufferedReadWriter, bool) {
+	p.inputsMutex.Lock()
+	defer p.inputsMutex.Unlock()
+
+	if p.parents[node] <= 1 {
+		input := &BufferedReadWriter{context: ctx}
+		return input, input, true
+	}
+
+	if input, exist := p.fanInInputs[node]; exist {
+		return input, input.BufferedReadWriter, false
+	}
+
+	input := &fanInWriter{
+		BufferedReadWriter: &BufferedReadWriter{context: ctx},
+		parents:            p.parents[node],
+	}
+	p.fanInInputs[node] = input
+	return input, input.BufferedReadWriter, true
+}
+
 // Process starts pipeline. Return channel will return if an error occured in
 // any of the processors or any of the pipeline hooks. Will return ErrShutdown
 // if the pipeline was shutdown.
@@ -161,27 +200,50 @@ func (p *Pipeline) Process(reader Reader) <-chan error {
 
 func (p *Pipeline) processStateNode(ctx context.Context, store *Store, node *PipelineNode, reader Reader) <-chan error {
 	outputs := make([]Writer, len(node.Children))
+	predicates := make([]func(entry interface{}) bool, len(node.Children))
+	// childInputs contains input buffers of children started by this node,
+	// fan-in nodes are started by one of the parents only.
+	childInputs := make([]*BufferedReadWriter, len(node.Children))
 
-	for i := range outputs {
-		outputs[i] = &BufferedReadWriter{
-			context: reader.GetContext(),
+	for i, child := range node.Children {
+		var start bool
+		outputs[i], childInputs[i], start = p.nodeInput(reader.GetContext(), child)
+		if !start {
+			childInputs[i] = nil
 		}
+		predicates[i] = child.predicate
 	}
 
 	var wg sync.WaitGroup
 
 	writer := &multiWriter{
 		writers:    outputs,
+		predicates: predicates,
+		firstMatch: node.routeFirstMatch,
 		closeAfter: 1,
 	}
 
 	var processingError error
 
+	// Input buffer of the root node is the pipeline reader, not tracked in
+	// metrics.
+	input, _ := reader.(*BufferedReadWriter)
+	if node == p.root {
+		input = nil
+	}
+	node.startRun(input)
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-
-		err := node.Processor.Process(ctx, store, reader, writer)
+		defer node.endRun()
+
+		err := node.Processor.Process(
+			context.WithValue(ctx, nodeContextKey{}, node),
+			store,
+			&meteredReader{Reader: reader, node: node},
+			&meteredWriter{Writer: writer, node: node},
+		)
 		if err != nil {
 			// Protects from cancelling twice and sending multiple errors to err channel
 			p.mutex.Lock()
@@ -200,10 +262,14 @@ func (p *Pipeline) processStateNode(ctx context.Context, store *Store, node *Pip
 	}()
 
 	for i, child := range node.Children {
+		if childInputs[i] == nil {
+			continue
+		}
+
 		wg.Add(1)
 		go func(i int, child *PipelineNode) {
 			defer wg.Done()
-			err := <-p.processStateNode(ctx, store, child, outputs[i].(*BufferedReadWriter))
+			err := <-p.processStateNode(ctx, store, child, childInputs[i])
 			if err != nil {
 				processingError = err
 			}
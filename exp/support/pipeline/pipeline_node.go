@@ -1,16 +1,87 @@
 package pipeline
 
+import (
+	"context"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+)
+
+// nodeContextKey is a key of the PipelineNode value in a context passed to
+// Processor.Process().
+type nodeContextKey struct{}
+
+// Pipe sets node children. Every entry written by the node processor is passed
+// to all children with matching predicate (see Where).
 func (p *PipelineNode) Pipe(children ...*PipelineNode) *PipelineNode {
 	p.Children = children
+	p.routeFirstMatch = false
+	return p
+}
+
+// Route sets node children. Every entry written by the node processor is
+// passed only to the first child with matching predicate (see Where). A child
+// without a predicate matches all entries so it can be used as the last
+// (default) route. Entries that don't match any child are dropped.
+func (p *PipelineNode) Route(children ...*PipelineNode) *PipelineNode {
+	p.Children = children
+	p.routeFirstMatch = true
+	return p
+}
+
+// Where sets a predicate of entries passed to the node by its parents.
+func (p *PipelineNode) Where(predicate func(entry interface{}) bool) *PipelineNode {
+	p.predicate = predicate
 	return p
 }
 
+// OnError sets node ErrorPolicy and DeadLetterHandler.
+func (p *PipelineNode) OnError(policy ErrorPolicy, deadLetterHandler func(DeadLetter)) *PipelineNode {
+	p.ErrorPolicy = policy
+	p.DeadLetterHandler = deadLetterHandler
+	return p
+}
+
+// HandleEntryError should be called by a processor when processing a single
+// entry fails. It applies the ErrorPolicy of the node running the processor:
+// it returns nil if the entry has been skipped and the processor should
+// continue processing the next entries or an error the processor should
+// return otherwise. `ctx` must be the context passed to Process().
+func HandleEntryError(ctx context.Context, entry interface{}, err error) error {
+	node, ok := ctx.Value(nodeContextKey{}).(*PipelineNode)
+	if !ok {
+		return err
+	}
+
+	return node.handleEntryError(entry, err)
+}
+
+func (p *PipelineNode) handleEntryError(entry interface{}, err error) error {
+	switch p.ErrorPolicy {
+	case SkipOnError:
+		log.WithFields(log.F{
+			"processor": p.Processor.Name(),
+			"err":       err,
+		}).Warn("Skipping entry that failed processing")
+	case DeadLetterOnError:
+		if p.DeadLetterHandler == nil {
+			return errors.Wrap(err, "DeadLetterHandler not set")
+		}
+		p.DeadLetterHandler(DeadLetter{
+			Processor: p.Processor.Name(),
+			Entry:     entry,
+			Err:       err,
+		})
+	default:
+		return err
+	}
+
+	p.metricsMutex.Lock()
+	p.metrics.erroredEntries++
+	p.metricsMutex.Unlock()
+	return nil
+}
+
 func (p *PipelineNode) reset() {
 	p.Processor.Reset()
-
-	p.readEntries = 0
-	p.readsPerSecond = 0
-	p.queuedEntries = 0
-	p.wroteEntries = 0
-	p.writesPerSecond = 0
 }
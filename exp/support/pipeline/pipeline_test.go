@@ -228,3 +228,193 @@ func (p *WaitForShutDownProcessor) Name() string {
 }
 
 func (p *WaitForShutDownProcessor) Reset() {}
+
+func TestPipeWhere(t *testing.T) {
+	even := &CollectProcessor{}
+	all := &CollectProcessor{}
+	p := pipeline.New(
+		pipeline.Node(&PassthroughProcessor{}).
+			Pipe(
+				pipeline.Node(even).Where(isEven),
+				pipeline.Node(all),
+			),
+	)
+
+	assert.NoError(t, <-p.Process(&SliceReader{Entries: []interface{}{1, 2, 3, 4}}))
+	assert.ElementsMatch(t, []interface{}{2, 4}, even.Entries)
+	assert.ElementsMatch(t, []interface{}{1, 2, 3, 4}, all.Entries)
+}
+
+func TestRoute(t *testing.T) {
+	even := &CollectProcessor{}
+	small := &CollectProcessor{}
+	other := &CollectProcessor{}
+	p := pipeline.New(
+		pipeline.Node(&PassthroughProcessor{}).
+			Route(
+				pipeline.Node(even).Where(isEven),
+				pipeline.Node(small).Where(func(entry interface{}) bool {
+					return entry.(int) < 4
+				}),
+				pipeline.Node(other),
+			),
+	)
+
+	assert.NoError(t, <-p.Process(&SliceReader{Entries: []interface{}{1, 2, 3, 4, 5}}))
+	assert.ElementsMatch(t, []interface{}{2, 4}, even.Entries)
+	assert.ElementsMatch(t, []interface{}{1, 3}, small.Entries)
+	assert.ElementsMatch(t, []interface{}{5}, other.Entries)
+}
+
+func TestFanIn(t *testing.T) {
+	merged := &CollectProcessor{}
+	mergeNode := pipeline.Node(merged)
+	p := pipeline.New(
+		pipeline.Node(&PassthroughProcessor{}).
+			Route(
+				pipeline.Node(&PassthroughProcessor{}).Where(isEven).Pipe(mergeNode),
+				pipeline.Node(&PassthroughProcessor{}).Pipe(mergeNode),
+			),
+	)
+
+	for i := 0; i < 2; i++ {
+		merged.Reset()
+		assert.NoError(t, <-p.Process(&SliceReader{Entries: []interface{}{1, 2, 3, 4, 5}}))
+		assert.ElementsMatch(t, []interface{}{1, 2, 3, 4, 5}, merged.Entries)
+	}
+
+	metrics := p.Metrics()
+	assert.Len(t, metrics.Children, 2)
+	assert.Len(t, metrics.Children[0].Children, 1)
+	assert.Len(t, metrics.Children[1].Children, 0)
+	assert.Equal(t, int64(2), metrics.Children[0].Children[0].Runs)
+	assert.Equal(t, int64(10), metrics.Children[0].Children[0].ReadEntries)
+}
+
+func TestErrorPolicy(t *testing.T) {
+	entries := []interface{}{1, 2, 3, 4}
+
+	p := pipeline.New(
+		pipeline.Node(&RejectOddProcessor{}),
+	)
+	err := <-p.Process(&SliceReader{Entries: entries})
+	assert.EqualError(t, err, "Processor RejectOddProcessor errored: odd entry: 1")
+
+	node := pipeline.Node(&RejectOddProcessor{}).OnError(pipeline.SkipOnError, nil)
+	p = pipeline.New(node)
+	assert.NoError(t, <-p.Process(&SliceReader{Entries: entries}))
+	assert.Equal(t, int64(2), p.Metrics().ErroredEntries)
+
+	var deadLetters []pipeline.DeadLetter
+	p = pipeline.New(
+		pipeline.Node(&RejectOddProcessor{}).
+			OnError(pipeline.DeadLetterOnError, func(deadLetter pipeline.DeadLetter) {
+				deadLetters = append(deadLetters, deadLetter)
+			}),
+	)
+	assert.NoError(t, <-p.Process(&SliceReader{Entries: entries}))
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, "RejectOddProcessor", deadLetters[0].Processor)
+	assert.Equal(t, 1, deadLetters[0].Entry)
+	assert.EqualError(t, deadLetters[0].Err, "odd entry: 1")
+	assert.Equal(t, 3, deadLetters[1].Entry)
+
+	p = pipeline.New(
+		pipeline.Node(&RejectOddProcessor{}).OnError(pipeline.DeadLetterOnError, nil),
+	)
+	err = <-p.Process(&SliceReader{Entries: entries})
+	assert.EqualError(t, err, "Processor RejectOddProcessor errored: DeadLetterHandler not set: odd entry: 1")
+}
+
+func isEven(entry interface{}) bool {
+	return entry.(int)%2 == 0
+}
+
+// SliceReader sends Entries.
+type SliceReader struct {
+	Entries []interface{}
+
+	sent int
+}
+
+func (r *SliceReader) GetContext() context.Context {
+	return context.Background()
+}
+
+func (r *SliceReader) Read() (interface{}, error) {
+	if r.sent == len(r.Entries) {
+		return nil, io.EOF
+	}
+
+	r.sent++
+	return r.Entries[r.sent-1], nil
+}
+
+func (r *SliceReader) Close() error {
+	return nil
+}
+
+// CollectProcessor saves all read entries in Entries.
+type CollectProcessor struct {
+	Entries []interface{}
+}
+
+func (p *CollectProcessor) Process(ctx context.Context, store *pipeline.Store, r pipeline.Reader, w pipeline.Writer) error {
+	defer r.Close()
+	defer w.Close()
+
+	for {
+		entry, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		p.Entries = append(p.Entries, entry)
+	}
+
+	return nil
+}
+
+func (p *CollectProcessor) Name() string {
+	return "CollectProcessor"
+}
+
+func (p *CollectProcessor) Reset() {
+	p.Entries = nil
+}
+
+// RejectOddProcessor reports errors for odd entries.
+type RejectOddProcessor struct{}
+
+func (p *RejectOddProcessor) Process(ctx context.Context, store *pipeline.Store, r pipeline.Reader, w pipeline.Writer) error {
+	defer r.Close()
+	defer w.Close()
+
+	for {
+		entry, err := r.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+
+		if entry.(int)%2 == 1 {
+			err = pipeline.HandleEntryError(ctx, entry, errors.Errorf("odd entry: %d", entry))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *RejectOddProcessor) Name() string {
+	return "RejectOddProcessor"
+}
+
+func (p *RejectOddProcessor) Reset() {}
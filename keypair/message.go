@@ -0,0 +1,71 @@
+package keypair
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/stellar/go/hash"
+	"github.com/stellar/go/network"
+)
+
+// MessagePrefix is the domain separation prefix of off-chain signed messages.
+// It guarantees that a message signature can never be a valid signature of a
+// transaction or of a message signed by another protocol.
+const MessagePrefix = "Stellar Signed Message:\n"
+
+// ErrEmptyNetworkPassphrase is returned when signing or verifying a message
+// without a network passphrase.
+var ErrEmptyNetworkPassphrase = errors.New("empty network passphrase")
+
+// HashMessage derives the network specific hash of an arbitrary off-chain
+// message: SHA-256(MessagePrefix || network ID || message). This is the value
+// signed by SignMessage.
+//
+// The network ID binds the signature to a single network, so proofs made for
+// the test network can't be replayed on the public network.
+func HashMessage(message []byte, passphrase string) ([32]byte, error) {
+	if strings.TrimSpace(passphrase) == "" {
+		return [32]byte{}, ErrEmptyNetworkPassphrase
+	}
+
+	id := network.ID(passphrase)
+
+	var payload bytes.Buffer
+	payload.Grow(len(MessagePrefix) + len(id) + len(message))
+	payload.WriteString(MessagePrefix)
+	payload.Write(id[:])
+	payload.Write(message)
+
+	return hash.Hash(payload.Bytes()), nil
+}
+
+// SignMessage signs an off-chain message for the network identified by the
+// supplied passphrase, for example to prove ownership of an account outside
+// of a transaction. Use VerifyMessage to verify the signature.
+func SignMessage(kp KP, message []byte, passphrase string) ([]byte, error) {
+	h, err := HashMessage(message, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return kp.Sign(h[:])
+}
+
+// VerifyMessage verifies that signature is a signature of message created
+// with SignMessage by the secret key of address (G...) for the network
+// identified by the supplied passphrase. It returns ErrInvalidSignature if
+// the signature doesn't verify.
+func VerifyMessage(address string, message, signature []byte, passphrase string) error {
+	kp, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	h, err := HashMessage(message, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return kp.Verify(h[:], signature)
+}
@@ -0,0 +1,90 @@
+package keypair
+
+import (
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/stellar/go/network"
+)
+
+var _ = Describe("off-chain messages", func() {
+	type MessageCase struct {
+		Passphrase string
+		Message    string
+		Signature  string
+	}
+
+	cases := []TableEntry{
+		Entry("hello on pubnet", MessageCase{
+			network.PublicNetworkPassphrase,
+			"hello",
+			"c322f56f4499fc6ed052d2ea4c09a8afff438aedc48534322c5c30ea51d99a5592a62081dcfb20ab13407f65a87d319278bfe72a61b3094c360ffebd8a559604",
+		}),
+		Entry("hello on testnet", MessageCase{
+			network.TestNetworkPassphrase,
+			"hello",
+			"40737f9e02cabfd9a666d9792c298883b377362c81e3d726a20f665e91e5aba25cf2819176415013c0488748d398559fa7f446868d1a1485f639d043cae39507",
+		}),
+		Entry("proof of ownership on pubnet", MessageCase{
+			network.PublicNetworkPassphrase,
+			"I control GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H",
+			"de43169141e5a968e736e3f981a3f4ec66856286aedc8109d5b22209f8129a047c21f6deef6f540ec5c220603bf8c88a3daa353980c005b185227695fc1aed0a",
+		}),
+	}
+
+	DescribeTable("SignMessage()",
+		func(c MessageCase) {
+			sig, err := SignMessage(&Full{seed}, []byte(c.Message), c.Passphrase)
+			Expect(err).To(BeNil())
+			Expect(hex.EncodeToString(sig)).To(Equal(c.Signature))
+		},
+		cases...,
+	)
+
+	DescribeTable("VerifyMessage()",
+		func(c MessageCase) {
+			sig, err := hex.DecodeString(c.Signature)
+			Expect(err).To(BeNil())
+			Expect(VerifyMessage(address, []byte(c.Message), sig, c.Passphrase)).To(BeNil())
+		},
+		cases...,
+	)
+
+	It("does not verify signatures of other messages, networks or accounts", func() {
+		sig, err := SignMessage(&Full{seed}, []byte("hello"), network.PublicNetworkPassphrase)
+		Expect(err).To(BeNil())
+
+		Expect(VerifyMessage(address, []byte("hello!"), sig, network.PublicNetworkPassphrase)).
+			To(Equal(ErrInvalidSignature))
+		Expect(VerifyMessage(address, []byte("hello"), sig, network.TestNetworkPassphrase)).
+			To(Equal(ErrInvalidSignature))
+		Expect(VerifyMessage("GAHK7EEG2WWHVKDNT4CEQFZGKF2LGDSW2IVM4S5DP42RBW3K6BTODB4A", []byte("hello"), sig, network.PublicNetworkPassphrase)).
+			To(Equal(ErrInvalidSignature))
+	})
+
+	It("does not verify message signatures as raw signatures of the message", func() {
+		sig, err := SignMessage(&Full{seed}, message, network.PublicNetworkPassphrase)
+		Expect(err).To(BeNil())
+		Expect((&Full{seed}).Verify(message, sig)).To(Equal(ErrInvalidSignature))
+	})
+
+	It("requires a network passphrase", func() {
+		_, err := SignMessage(&Full{seed}, []byte("hello"), "")
+		Expect(err).To(Equal(ErrEmptyNetworkPassphrase))
+
+		err = VerifyMessage(address, []byte("hello"), signature, " ")
+		Expect(err).To(Equal(ErrEmptyNetworkPassphrase))
+	})
+
+	It("cannot sign without a secret key", func() {
+		_, err := SignMessage(&FromAddress{address}, []byte("hello"), network.PublicNetworkPassphrase)
+		Expect(err).To(Equal(ErrCannotSign))
+	})
+
+	It("fails on invalid addresses", func() {
+		err := VerifyMessage(seed, []byte("hello"), signature, network.PublicNetworkPassphrase)
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,595 @@
+package meta
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/stellar/go/xdr"
+)
+
+// EffectType is the type of an effect. Values and names are the same as
+// Horizon's effect types (see services/horizon/internal/db2/history), so
+// effects derived here can be compared with the ones served by Horizon.
+type EffectType int
+
+const (
+	// EffectAccountCreated effects occur when a new account is created
+	EffectAccountCreated EffectType = 0 // from create_account
+
+	// EffectAccountRemoved effects occur when one account is merged into another
+	EffectAccountRemoved EffectType = 1 // from merge_account
+
+	// EffectAccountCredited effects occur when an account receives some currency
+	EffectAccountCredited EffectType = 2 // from create_account, payment, path_payment, merge_account, inflation
+
+	// EffectAccountDebited effects occur when an account sends some currency
+	EffectAccountDebited EffectType = 3 // from create_account, payment, path_payment, merge_account
+
+	// EffectAccountThresholdsUpdated effects occur when an account changes its
+	// multisig thresholds.
+	EffectAccountThresholdsUpdated EffectType = 4 // from set_options
+
+	// EffectAccountHomeDomainUpdated effects occur when an account changes its
+	// home domain.
+	EffectAccountHomeDomainUpdated EffectType = 5 // from set_options
+
+	// EffectAccountFlagsUpdated effects occur when an account changes its
+	// account flags, either clearing or setting.
+	EffectAccountFlagsUpdated EffectType = 6 // from set_options
+
+	// EffectAccountInflationDestinationUpdated effects occur when an account changes its
+	// inflation destination.
+	EffectAccountInflationDestinationUpdated EffectType = 7 // from set_options
+
+	// EffectSignerCreated occurs when an account gains a signer
+	EffectSignerCreated EffectType = 10 // from set_options
+
+	// EffectSignerRemoved occurs when an account loses a signer
+	EffectSignerRemoved EffectType = 11 // from set_options
+
+	// EffectSignerUpdated occurs when an account changes the weight of one of its
+	// signers.
+	EffectSignerUpdated EffectType = 12 // from set_options
+
+	// EffectTrustlineCreated occurs when an account trusts an anchor
+	EffectTrustlineCreated EffectType = 20 // from change_trust
+
+	// EffectTrustlineRemoved occurs when an account removes struct by setting the
+	// limit of a trustline to 0
+	EffectTrustlineRemoved EffectType = 21 // from change_trust
+
+	// EffectTrustlineUpdated occurs when an account changes a trustline's limit
+	EffectTrustlineUpdated EffectType = 22 // from change_trust
+
+	// EffectTrustlineAuthorized occurs when an anchor authorizes another
+	// account's trustline
+	EffectTrustlineAuthorized EffectType = 23 // from allow_trust
+
+	// EffectTrustlineDeauthorized occurs when an anchor revokes access to an
+	// asset it issues.
+	EffectTrustlineDeauthorized EffectType = 24 // from allow_trust
+
+	// EffectTrade occurs when a trade is initiated because of a path payment or
+	// offer operation.
+	EffectTrade EffectType = 33 // from manage_offer, create_passive_offer, path_payment
+
+	// EffectDataCreated occurs when an account gets a new data field
+	EffectDataCreated EffectType = 40 // from manage_data
+
+	// EffectDataRemoved occurs when an account removes a data field
+	EffectDataRemoved EffectType = 41 // from manage_data
+
+	// EffectDataUpdated occurs when an account changes a data field's value
+	EffectDataUpdated EffectType = 42 // from manage_data
+
+	// EffectSequenceBumped occurs when an account bumps their sequence number
+	EffectSequenceBumped EffectType = 43 // from bump_sequence
+)
+
+var effectTypeNames = map[EffectType]string{
+	EffectAccountCreated:                     "account_created",
+	EffectAccountRemoved:                     "account_removed",
+	EffectAccountCredited:                    "account_credited",
+	EffectAccountDebited:                     "account_debited",
+	EffectAccountThresholdsUpdated:           "account_thresholds_updated",
+	EffectAccountHomeDomainUpdated:           "account_home_domain_updated",
+	EffectAccountFlagsUpdated:                "account_flags_updated",
+	EffectAccountInflationDestinationUpdated: "account_inflation_destination_updated",
+	EffectSignerCreated:                      "signer_created",
+	EffectSignerRemoved:                      "signer_removed",
+	EffectSignerUpdated:                      "signer_updated",
+	EffectTrustlineCreated:                   "trustline_created",
+	EffectTrustlineRemoved:                   "trustline_removed",
+	EffectTrustlineUpdated:                   "trustline_updated",
+	EffectTrustlineAuthorized:                "trustline_authorized",
+	EffectTrustlineDeauthorized:              "trustline_deauthorized",
+	EffectTrade:                              "trade",
+	EffectDataCreated:                        "data_created",
+	EffectDataRemoved:                        "data_removed",
+	EffectDataUpdated:                        "data_updated",
+	EffectSequenceBumped:                     "sequence_bumped",
+}
+
+// String returns the name of the effect type used by Horizon, ex.
+// "account_credited".
+func (t EffectType) String() string {
+	if name, ok := effectTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EffectType(%d)", int(t))
+}
+
+// Effect is a single effect of an operation on an account.
+type Effect struct {
+	// OperationIndex is the index of the operation in the transaction.
+	OperationIndex int
+	Account        xdr.AccountId
+	Type           EffectType
+	// Details is a pointer to one of the detail types below, depending on
+	// Type:
+	//
+	//   - AccountCreated for EffectAccountCreated,
+	//   - AccountRemoved for EffectAccountRemoved,
+	//   - BalanceChange for EffectAccountCredited and EffectAccountDebited,
+	//   - ThresholdsUpdate for EffectAccountThresholdsUpdated,
+	//   - HomeDomainUpdate for EffectAccountHomeDomainUpdated,
+	//   - FlagsUpdate for EffectAccountFlagsUpdated,
+	//   - InflationDestinationUpdate for EffectAccountInflationDestinationUpdated,
+	//   - SignerChange for signer effects,
+	//   - TrustlineChange for EffectTrustlineCreated, EffectTrustlineRemoved
+	//     and EffectTrustlineUpdated,
+	//   - TrustlineAuthorization for EffectTrustlineAuthorized and
+	//     EffectTrustlineDeauthorized,
+	//   - Trade for EffectTrade,
+	//   - DataChange for data effects,
+	//   - SequenceBump for EffectSequenceBumped.
+	Details interface{}
+}
+
+// AccountCreated contains details of EffectAccountCreated.
+type AccountCreated struct {
+	StartingBalance xdr.Int64
+}
+
+// AccountRemoved contains details of EffectAccountRemoved.
+type AccountRemoved struct{}
+
+// BalanceChange contains details of EffectAccountCredited and
+// EffectAccountDebited.
+type BalanceChange struct {
+	Asset  xdr.Asset
+	Amount xdr.Int64
+}
+
+// ThresholdsUpdate contains details of EffectAccountThresholdsUpdated. Only
+// thresholds set by the operation are not nil.
+type ThresholdsUpdate struct {
+	LowThreshold  *xdr.Uint32
+	MedThreshold  *xdr.Uint32
+	HighThreshold *xdr.Uint32
+}
+
+// HomeDomainUpdate contains details of EffectAccountHomeDomainUpdated.
+type HomeDomainUpdate struct {
+	HomeDomain string
+}
+
+// FlagsUpdate contains details of EffectAccountFlagsUpdated. Only flags set
+// or cleared by the operation are not nil.
+type FlagsUpdate struct {
+	AuthRequired  *bool
+	AuthRevocable *bool
+	AuthImmutable *bool
+}
+
+// InflationDestinationUpdate contains details of
+// EffectAccountInflationDestinationUpdated.
+type InflationDestinationUpdate struct {
+	InflationDestination xdr.AccountId
+}
+
+// SignerChange contains details of EffectSignerCreated, EffectSignerRemoved
+// and EffectSignerUpdated. Weight is 0 for removed signers.
+type SignerChange struct {
+	// PublicKey is the address of the signer key, ex. "GABC..." for ed25519
+	// keys.
+	PublicKey string
+	Weight    int32
+}
+
+// TrustlineChange contains details of EffectTrustlineCreated,
+// EffectTrustlineRemoved and EffectTrustlineUpdated.
+type TrustlineChange struct {
+	Asset xdr.Asset
+	Limit xdr.Int64
+}
+
+// TrustlineAuthorization contains details of EffectTrustlineAuthorized and
+// EffectTrustlineDeauthorized.
+type TrustlineAuthorization struct {
+	Trustor xdr.AccountId
+	Asset   xdr.Asset
+}
+
+// Trade contains details of EffectTrade from the point of view of the
+// effect's account. Seller is the other side of the trade.
+type Trade struct {
+	Seller       xdr.AccountId
+	OfferID      xdr.Int64
+	SoldAsset    xdr.Asset
+	SoldAmount   xdr.Int64
+	BoughtAsset  xdr.Asset
+	BoughtAmount xdr.Int64
+}
+
+// DataChange contains details of EffectDataCreated, EffectDataRemoved and
+// EffectDataUpdated. Value is nil for removed entries.
+type DataChange struct {
+	Name  string
+	Value []byte
+}
+
+// SequenceBump contains details of EffectSequenceBumped.
+type SequenceBump struct {
+	NewSeq xdr.SequenceNumber
+}
+
+// defaultSignerWeight is the weight of the master key of a new account, the
+// same as keypair.DefaultSignerWeight.
+const defaultSignerWeight = 1
+
+// Effects derives the effects of the transaction `envelope` that produced
+// `b` and `result`, the same way Horizon ingestion does. Failed
+// transactions have no effects.
+func (b *Bundle) Effects(envelope *xdr.TransactionEnvelope, result *xdr.TransactionResult) ([]Effect, error) {
+	results, ok := result.Result.GetResults()
+	if !ok || result.Result.Code != xdr.TransactionResultCodeTxSuccess {
+		return nil, nil
+	}
+
+	operations := envelope.Tx.Operations
+	if len(results) != len(operations) {
+		return nil, fmt.Errorf(
+			"number of operation results (%d) doesn't match number of operations (%d)",
+			len(results),
+			len(operations),
+		)
+	}
+
+	var effects []Effect
+	for i := range operations {
+		e := effectsBuilder{
+			bundle:    b,
+			opIndex:   i,
+			operation: &operations[i],
+			source:    envelope.Tx.SourceAccount,
+		}
+		if operations[i].SourceAccount != nil {
+			e.source = *operations[i].SourceAccount
+		}
+
+		opResult, ok := results[i].GetTr()
+		if !ok {
+			return nil, fmt.Errorf("operation %d has no result", i)
+		}
+
+		if err := e.build(opResult); err != nil {
+			return nil, fmt.Errorf("error deriving effects of operation %d: %v", i, err)
+		}
+		effects = append(effects, e.effects...)
+	}
+
+	return effects, nil
+}
+
+// effectsBuilder derives effects of a single operation.
+type effectsBuilder struct {
+	bundle    *Bundle
+	opIndex   int
+	operation *xdr.Operation
+	source    xdr.AccountId
+	effects   []Effect
+}
+
+func (e *effectsBuilder) add(account xdr.AccountId, typ EffectType, details interface{}) {
+	e.effects = append(e.effects, Effect{
+		OperationIndex: e.opIndex,
+		Account:        account,
+		Type:           typ,
+		Details:        details,
+	})
+}
+
+func (e *effectsBuilder) beforeAndAfter(key xdr.LedgerKey) (*xdr.LedgerEntry, *xdr.LedgerEntry, error) {
+	before, err := e.bundle.StateBefore(key, e.opIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := e.bundle.StateAfter(key, e.opIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, nil
+}
+
+func (e *effectsBuilder) build(result xdr.OperationResultTr) error {
+	body := e.operation.Body
+	native := xdr.MustNewNativeAsset()
+
+	switch body.Type {
+	case xdr.OperationTypeCreateAccount:
+		op := body.MustCreateAccountOp()
+		e.add(op.Destination, EffectAccountCreated, &AccountCreated{StartingBalance: op.StartingBalance})
+		e.add(e.source, EffectAccountDebited, &BalanceChange{Asset: native, Amount: op.StartingBalance})
+		e.add(op.Destination, EffectSignerCreated, &SignerChange{
+			PublicKey: op.Destination.Address(),
+			Weight:    defaultSignerWeight,
+		})
+
+	case xdr.OperationTypePayment:
+		op := body.MustPaymentOp()
+		e.add(op.Destination, EffectAccountCredited, &BalanceChange{Asset: op.Asset, Amount: op.Amount})
+		e.add(e.source, EffectAccountDebited, &BalanceChange{Asset: op.Asset, Amount: op.Amount})
+
+	case xdr.OperationTypePathPaymentStrictReceive:
+		op := body.MustPathPaymentStrictReceiveOp()
+		opResult := result.MustPathPaymentStrictReceiveResult()
+		e.add(op.Destination, EffectAccountCredited, &BalanceChange{Asset: op.DestAsset, Amount: op.DestAmount})
+		e.add(e.source, EffectAccountDebited, &BalanceChange{Asset: op.SendAsset, Amount: opResult.SendAmount()})
+		e.addTrades(opResult.MustSuccess().Offers)
+
+	case xdr.OperationTypePathPaymentStrictSend:
+		op := body.MustPathPaymentStrictSendOp()
+		opResult := result.MustPathPaymentStrictSendResult()
+		e.add(op.Destination, EffectAccountCredited, &BalanceChange{Asset: op.DestAsset, Amount: opResult.DestAmount()})
+		e.add(e.source, EffectAccountDebited, &BalanceChange{Asset: op.SendAsset, Amount: op.SendAmount})
+		e.addTrades(opResult.MustSuccess().Offers)
+
+	case xdr.OperationTypeManageBuyOffer:
+		e.addTrades(result.MustManageBuyOfferResult().MustSuccess().OffersClaimed)
+
+	case xdr.OperationTypeManageSellOffer:
+		e.addTrades(result.MustManageSellOfferResult().MustSuccess().OffersClaimed)
+
+	case xdr.OperationTypeCreatePassiveSellOffer:
+		// KNOWN ISSUE: stellar-core creates results for CreatePassiveOffer
+		// operations with the wrong result arm set.
+		if result.Type == xdr.OperationTypeManageSellOffer {
+			e.addTrades(result.MustManageSellOfferResult().MustSuccess().OffersClaimed)
+		} else {
+			e.addTrades(result.MustCreatePassiveSellOfferResult().MustSuccess().OffersClaimed)
+		}
+
+	case xdr.OperationTypeSetOptions:
+		return e.addSetOptionsEffects(body.MustSetOptionsOp())
+
+	case xdr.OperationTypeChangeTrust:
+		op := body.MustChangeTrustOp()
+		key := xdr.LedgerKey{}
+		if err := key.SetTrustline(e.source, op.Line); err != nil {
+			return err
+		}
+
+		before, after, err := e.beforeAndAfter(key)
+		// When an account trusts itself, the transaction is successful but no
+		// ledger entries are actually modified.
+		if err == ErrMetaNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var typ EffectType
+		switch {
+		case before == nil && after != nil:
+			typ = EffectTrustlineCreated
+		case before != nil && after == nil:
+			typ = EffectTrustlineRemoved
+		case before != nil && after != nil:
+			typ = EffectTrustlineUpdated
+		default:
+			return fmt.Errorf("invalid before and after state of trustline")
+		}
+		e.add(e.source, typ, &TrustlineChange{Asset: op.Line, Limit: op.Limit})
+
+	case xdr.OperationTypeAllowTrust:
+		op := body.MustAllowTrustOp()
+		details := &TrustlineAuthorization{Trustor: op.Trustor, Asset: op.Asset.ToAsset(e.source)}
+		if op.Authorize {
+			e.add(e.source, EffectTrustlineAuthorized, details)
+		} else {
+			e.add(e.source, EffectTrustlineDeauthorized, details)
+		}
+
+	case xdr.OperationTypeAccountMerge:
+		balance := result.MustAccountMergeResult().MustSourceAccountBalance()
+		e.add(e.source, EffectAccountDebited, &BalanceChange{Asset: native, Amount: balance})
+		e.add(body.MustDestination(), EffectAccountCredited, &BalanceChange{Asset: native, Amount: balance})
+		e.add(e.source, EffectAccountRemoved, &AccountRemoved{})
+
+	case xdr.OperationTypeInflation:
+		for _, payout := range result.MustInflationResult().MustPayouts() {
+			e.add(payout.Destination, EffectAccountCredited, &BalanceChange{Asset: native, Amount: payout.Amount})
+		}
+
+	case xdr.OperationTypeManageData:
+		op := body.MustManageDataOp()
+		key := xdr.LedgerKey{}
+		if err := key.SetData(e.source, string(op.DataName)); err != nil {
+			return err
+		}
+
+		before, after, err := e.beforeAndAfter(key)
+		if err != nil {
+			return err
+		}
+
+		details := &DataChange{Name: string(op.DataName)}
+		if after != nil {
+			details.Value = after.Data.MustData().DataValue
+		}
+
+		var typ EffectType
+		switch {
+		case before == nil && after != nil:
+			typ = EffectDataCreated
+		case before != nil && after == nil:
+			typ = EffectDataRemoved
+		case before != nil && after != nil:
+			typ = EffectDataUpdated
+		default:
+			return fmt.Errorf("invalid before and after state of data entry")
+		}
+		e.add(e.source, typ, details)
+
+	case xdr.OperationTypeBumpSequence:
+		// Bumping to a lower sequence number doesn't change the account.
+		if len(e.bundle.OperationsMetas()[e.opIndex].Changes) > 0 {
+			op := body.MustBumpSequenceOp()
+			e.add(e.source, EffectSequenceBumped, &SequenceBump{NewSeq: op.BumpTo})
+		}
+
+	default:
+		return fmt.Errorf("unknown operation type: %s", body.Type)
+	}
+
+	return nil
+}
+
+func (e *effectsBuilder) addTrades(claims []xdr.ClaimOfferAtom) {
+	buyer := e.source
+	for _, claim := range claims {
+		if claim.AmountSold == 0 && claim.AmountBought == 0 {
+			continue
+		}
+
+		seller := claim.SellerId
+		e.add(buyer, EffectTrade, &Trade{
+			Seller:       seller,
+			OfferID:      claim.OfferId,
+			SoldAsset:    claim.AssetBought,
+			SoldAmount:   claim.AmountBought,
+			BoughtAsset:  claim.AssetSold,
+			BoughtAmount: claim.AmountSold,
+		})
+		e.add(seller, EffectTrade, &Trade{
+			Seller:       buyer,
+			OfferID:      claim.OfferId,
+			SoldAsset:    claim.AssetSold,
+			SoldAmount:   claim.AmountSold,
+			BoughtAsset:  claim.AssetBought,
+			BoughtAmount: claim.AmountBought,
+		})
+	}
+}
+
+func (e *effectsBuilder) addSetOptionsEffects(op xdr.SetOptionsOp) error {
+	if op.HomeDomain != nil {
+		e.add(e.source, EffectAccountHomeDomainUpdated, &HomeDomainUpdate{HomeDomain: string(*op.HomeDomain)})
+	}
+
+	if op.LowThreshold != nil || op.MedThreshold != nil || op.HighThreshold != nil {
+		e.add(e.source, EffectAccountThresholdsUpdated, &ThresholdsUpdate{
+			LowThreshold:  op.LowThreshold,
+			MedThreshold:  op.MedThreshold,
+			HighThreshold: op.HighThreshold,
+		})
+	}
+
+	flags := &FlagsUpdate{}
+	setFlags(flags, op.SetFlags, true)
+	setFlags(flags, op.ClearFlags, false)
+	if flags.AuthRequired != nil || flags.AuthRevocable != nil || flags.AuthImmutable != nil {
+		e.add(e.source, EffectAccountFlagsUpdated, flags)
+	}
+
+	if op.InflationDest != nil {
+		e.add(e.source, EffectAccountInflationDestinationUpdated, &InflationDestinationUpdate{
+			InflationDestination: *op.InflationDest,
+		})
+	}
+
+	return e.addSignerEffects()
+}
+
+func setFlags(update *FlagsUpdate, flagsPtr *xdr.Uint32, value bool) {
+	if flagsPtr == nil {
+		return
+	}
+
+	flags := xdr.AccountFlags(*flagsPtr)
+	if flags.IsAuthRequired() {
+		update.AuthRequired = &value
+	}
+	if flags.IsAuthRevocable() {
+		update.AuthRevocable = &value
+	}
+	if flags.IsAuthImmutable() {
+		update.AuthImmutable = &value
+	}
+}
+
+// addSignerEffects adds effects of signers changed by the operation.
+// Unlike Horizon, which iterates over maps, effects are sorted by signer key
+// so the result is deterministic.
+func (e *effectsBuilder) addSignerEffects() error {
+	beforeEntry, afterEntry, err := e.beforeAndAfter(e.source.LedgerKey())
+	if err != nil {
+		return err
+	}
+
+	if beforeEntry == nil || afterEntry == nil {
+		return nil
+	}
+
+	beforeAccount := beforeEntry.Data.MustAccount()
+	afterAccount := afterEntry.Data.MustAccount()
+	before := beforeAccount.SignerSummary()
+	after := afterAccount.SignerSummary()
+
+	for _, key := range sortedKeys(before) {
+		weight, ok := after[key]
+		if !ok {
+			e.add(e.source, EffectSignerRemoved, &SignerChange{PublicKey: key})
+			continue
+		}
+		// Horizon adds update effects for every signer when any signer was
+		// changed.
+		if !signersEqual(before, after) {
+			e.add(e.source, EffectSignerUpdated, &SignerChange{PublicKey: key, Weight: weight})
+		}
+	}
+
+	for _, key := range sortedKeys(after) {
+		if _, ok := before[key]; ok {
+			continue
+		}
+		e.add(e.source, EffectSignerCreated, &SignerChange{PublicKey: key, Weight: after[key]})
+	}
+
+	return nil
+}
+
+func signersEqual(a, b map[string]int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, weight := range a {
+		if otherWeight, ok := b[key]; !ok || otherWeight != weight {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]int32) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
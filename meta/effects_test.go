@@ -0,0 +1,199 @@
+package meta_test
+
+import (
+	. "github.com/stellar/go/meta"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stellar/go/xdr"
+)
+
+var _ = Describe("meta.Bundle#Effects", func() {
+	var (
+		newAccount    = xdr.MustAddress("GCXKG6RN4ONIEPCMNFB732A436Z5PNDSRLGWK7GBLCMQLIFO4S7EYWVU")
+		masterAccount = xdr.MustAddress("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H")
+		gateway       = xdr.MustAddress("GC23QF2HUE52AMXUFUH3AYJAXXGXXV2VHXYYR6EYXETPKDXZSAW67XO4")
+		native        = xdr.MustNewNativeAsset()
+		usd           = xdr.MustNewCreditAsset("USD", gateway.Address())
+	)
+
+	envelope := func(source xdr.AccountId, bodies ...xdr.OperationBody) *xdr.TransactionEnvelope {
+		tx := &xdr.TransactionEnvelope{Tx: xdr.Transaction{SourceAccount: source}}
+		for _, body := range bodies {
+			tx.Tx.Operations = append(tx.Tx.Operations, xdr.Operation{Body: body})
+		}
+		return tx
+	}
+
+	success := func(results ...xdr.OperationResultTr) *xdr.TransactionResult {
+		opResults := make([]xdr.OperationResult, len(results))
+		for i := range results {
+			opResults[i] = xdr.OperationResult{Code: xdr.OperationResultCodeOpInner, Tr: &results[i]}
+		}
+		return &xdr.TransactionResult{
+			Result: xdr.TransactionResultResult{
+				Code:    xdr.TransactionResultCodeTxSuccess,
+				Results: &opResults,
+			},
+		}
+	}
+
+	accountEntry := func(account xdr.AccountId, signers ...xdr.Signer) *xdr.LedgerEntry {
+		return &xdr.LedgerEntry{
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeAccount,
+				Account: &xdr.AccountEntry{
+					AccountId:  account,
+					Thresholds: xdr.Thresholds{1, 0, 0, 0},
+					Signers:    signers,
+				},
+			},
+		}
+	}
+
+	It("derives effects of create account operations", func() {
+		tx := envelope(masterAccount, xdr.OperationBody{
+			Type: xdr.OperationTypeCreateAccount,
+			CreateAccountOp: &xdr.CreateAccountOp{
+				Destination:     newAccount,
+				StartingBalance: 1000000000,
+			},
+		})
+		result := success(xdr.OperationResultTr{
+			Type:                xdr.OperationTypeCreateAccount,
+			CreateAccountResult: &xdr.CreateAccountResult{Code: xdr.CreateAccountResultCodeCreateAccountSuccess},
+		})
+
+		b := bundle(createAccountFeeMeta, createAccountResultMeta)
+		effects, err := b.Effects(tx, result)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effects).To(Equal([]Effect{
+			{Account: newAccount, Type: EffectAccountCreated, Details: &AccountCreated{StartingBalance: 1000000000}},
+			{Account: masterAccount, Type: EffectAccountDebited, Details: &BalanceChange{Asset: native, Amount: 1000000000}},
+			{Account: newAccount, Type: EffectSignerCreated, Details: &SignerChange{PublicKey: newAccount.Address(), Weight: 1}},
+		}))
+		Expect(effects[0].Type.String()).To(Equal("account_created"))
+	})
+
+	It("returns no effects of failed transactions", func() {
+		tx := envelope(masterAccount, xdr.OperationBody{
+			Type:      xdr.OperationTypePayment,
+			PaymentOp: &xdr.PaymentOp{Destination: newAccount, Asset: native, Amount: 10},
+		})
+		result := &xdr.TransactionResult{
+			Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxBadSeq},
+		}
+
+		b := Bundle{}
+		effects, err := b.Effects(tx, result)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effects).To(BeEmpty())
+	})
+
+	It("derives trustline effects from the meta", func() {
+		tx := envelope(newAccount, xdr.OperationBody{
+			Type:          xdr.OperationTypeChangeTrust,
+			ChangeTrustOp: &xdr.ChangeTrustOp{Line: usd, Limit: 0},
+		})
+		result := success(xdr.OperationResultTr{
+			Type:              xdr.OperationTypeChangeTrust,
+			ChangeTrustResult: &xdr.ChangeTrustResult{Code: xdr.ChangeTrustResultCodeChangeTrustSuccess},
+		})
+
+		b := bundle(removeTrustlineFeeMeta, removeTrustlineResultMeta)
+		effects, err := b.Effects(tx, result)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effects).To(Equal([]Effect{
+			{Account: newAccount, Type: EffectTrustlineRemoved, Details: &TrustlineChange{Asset: usd, Limit: 0}},
+		}))
+	})
+
+	It("derives trade effects for both sides", func() {
+		tx := envelope(newAccount, xdr.OperationBody{
+			Type:              xdr.OperationTypeManageSellOffer,
+			ManageSellOfferOp: &xdr.ManageSellOfferOp{Selling: native, Buying: usd, Amount: 20, Price: xdr.Price{N: 1, D: 2}},
+		})
+		result := success(xdr.OperationResultTr{
+			Type: xdr.OperationTypeManageSellOffer,
+			ManageSellOfferResult: &xdr.ManageSellOfferResult{
+				Code: xdr.ManageSellOfferResultCodeManageSellOfferSuccess,
+				Success: &xdr.ManageOfferSuccessResult{
+					OffersClaimed: []xdr.ClaimOfferAtom{
+						{SellerId: gateway, OfferId: 7, AssetSold: usd, AmountSold: 10, AssetBought: native, AmountBought: 20},
+						{SellerId: gateway, OfferId: 8},
+					},
+					Offer: xdr.ManageOfferSuccessResultOffer{Effect: xdr.ManageOfferEffectManageOfferDeleted},
+				},
+			},
+		})
+
+		b := Bundle{TransactionMeta: xdr.TransactionMeta{V: 1, V1: &xdr.TransactionMetaV1{Operations: []xdr.OperationMeta{{}}}}}
+		effects, err := b.Effects(tx, result)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(effects).To(Equal([]Effect{
+			{Account: newAccount, Type: EffectTrade, Details: &Trade{
+				Seller: gateway, OfferID: 7, SoldAsset: native, SoldAmount: 20, BoughtAsset: usd, BoughtAmount: 10,
+			}},
+			{Account: gateway, Type: EffectTrade, Details: &Trade{
+				Seller: newAccount, OfferID: 7, SoldAsset: usd, SoldAmount: 10, BoughtAsset: native, BoughtAmount: 20,
+			}},
+		}))
+	})
+
+	It("derives set options effects from the operation and the meta", func() {
+		signer1 := xdr.MustSigner(gateway.Address())
+		signer2 := xdr.MustSigner(masterAccount.Address())
+		homeDomain := xdr.String32("example.com")
+		high := xdr.Uint32(2)
+		setFlags := xdr.Uint32(xdr.AccountFlagsAuthRequiredFlag)
+		clearFlags := xdr.Uint32(xdr.AccountFlagsAuthRevocableFlag)
+		tx := envelope(newAccount, xdr.OperationBody{
+			Type: xdr.OperationTypeSetOptions,
+			SetOptionsOp: &xdr.SetOptionsOp{
+				HomeDomain:    &homeDomain,
+				HighThreshold: &high,
+				SetFlags:      &setFlags,
+				ClearFlags:    &clearFlags,
+				Signer:        &xdr.Signer{Key: signer2, Weight: 3},
+			},
+		})
+		result := success(xdr.OperationResultTr{
+			Type:             xdr.OperationTypeSetOptions,
+			SetOptionsResult: &xdr.SetOptionsResult{Code: xdr.SetOptionsResultCodeSetOptionsSuccess},
+		})
+
+		b := Bundle{TransactionMeta: xdr.TransactionMeta{V: 1, V1: &xdr.TransactionMetaV1{
+			Operations: []xdr.OperationMeta{{Changes: xdr.LedgerEntryChanges{
+				{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: accountEntry(newAccount, xdr.Signer{Key: signer1, Weight: 1})},
+				{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: accountEntry(newAccount, xdr.Signer{Key: signer2, Weight: 3})},
+			}}},
+		}}}
+		effects, err := b.Effects(tx, result)
+		Expect(err).ToNot(HaveOccurred())
+
+		yes, no := true, false
+		Expect(effects).To(Equal([]Effect{
+			{Account: newAccount, Type: EffectAccountHomeDomainUpdated, Details: &HomeDomainUpdate{HomeDomain: "example.com"}},
+			{Account: newAccount, Type: EffectAccountThresholdsUpdated, Details: &ThresholdsUpdate{HighThreshold: &high}},
+			{Account: newAccount, Type: EffectAccountFlagsUpdated, Details: &FlagsUpdate{AuthRequired: &yes, AuthRevocable: &no}},
+			{Account: newAccount, Type: EffectSignerRemoved, Details: &SignerChange{PublicKey: gateway.Address()}},
+			{Account: newAccount, Type: EffectSignerUpdated, Details: &SignerChange{PublicKey: newAccount.Address(), Weight: 1}},
+			{Account: newAccount, Type: EffectSignerCreated, Details: &SignerChange{PublicKey: masterAccount.Address(), Weight: 3}},
+		}))
+	})
+
+	It("fails when results don't match operations", func() {
+		tx := envelope(masterAccount, xdr.OperationBody{Type: xdr.OperationTypeInflation})
+		b := Bundle{}
+		_, err := b.Effects(tx, success())
+		Expect(err).To(MatchError("number of operation results (0) doesn't match number of operations (1)"))
+	})
+})
+
+const (
+	createAccountFeeMeta    = "AAAAAgAAAAMAAAABAAAAAAAAAABi/B0L0JGythwN1lY0aypo19NHxvLCyO5tBEcCVvwF9w3gtrOnZAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAAAAAAAEAAAACAAAAAAAAAABi/B0L0JGythwN1lY0aypo19NHxvLCyO5tBEcCVvwF9w3gtrOnY/+cAAAAAAAAAAEAAAAAAAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAAA=="
+	createAccountResultMeta = "AAAAAAAAAAEAAAACAAAAAAAAAAIAAAAAAAAAAK6jei3jmoI8TGlD/egc37PXtHKKzWV8wViZBaCu5L5MAAAAADuaygAAAAACAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAAAAAQAAAAIAAAAAAAAAAGL8HQvQkbK2HA3WVjRrKmjX00fG8sLI7m0ERwJW/AX3DeC2s2vJNNQAAAAAAAAAAwAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAA"
+
+	removeTrustlineFeeMeta    = "AAAAAgAAAAMAAAAEAAAAAAAAAACuo3ot45qCPExpQ/3oHN+z17Ryis1lfMFYmQWgruS+TAAAAAJUC+M4AAAAAgAAAAIAAAABAAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAAAAAAAEAAAAFAAAAAAAAAACuo3ot45qCPExpQ/3oHN+z17Ryis1lfMFYmQWgruS+TAAAAAJUC+LUAAAAAgAAAAMAAAABAAAAAAAAAAAAAAAAAQAAAAAAAAAAAAAAAAAAAA=="
+	removeTrustlineResultMeta = "AAAAAAAAAAEAAAADAAAAAQAAAAUAAAAAAAAAAK6jei3jmoI8TGlD/egc37PXtHKKzWV8wViZBaCu5L5MAAAAAlQL4tQAAAACAAAAAwAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAAAAAwAAAAQAAAABAAAAAK6jei3jmoI8TGlD/egc37PXtHKKzWV8wViZBaCu5L5MAAAAAVVTRAAAAAAAtbgXR6E7oDL0LQ+wYSC9zXvXVT3xiPiYuSb1DvmQLe8AAAAAAAAAAAAAAAlQL5AAAAAAAQAAAAAAAAAAAAAAAgAAAAEAAAAArqN6LeOagjxMaUP96Bzfs9e0corNZXzBWJkFoK7kvkwAAAABVVNEAAAAAAC1uBdHoTugMvQtD7BhIL3Ne9dVPfGI+Ji5JvUO+ZAt7w=="
+)
@@ -0,0 +1,198 @@
+package price
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/xdr"
+)
+
+// ErrInvalidPrice is returned when a price doesn't have a positive
+// numerator and denominator.
+var ErrInvalidPrice = errors.New("invalid price")
+
+// Invert returns 1/p. Unlike xdr.Price.Invert it doesn't modify `p`.
+func Invert(p xdr.Price) (xdr.Price, error) {
+	if !isValid(p) {
+		return xdr.Price{}, ErrInvalidPrice
+	}
+	return xdr.Price{N: p.D, D: p.N}, nil
+}
+
+// Cmp compares prices a and b and returns -1 if a < b, 0 if a == b and +1 if
+// a > b. Prices are compared by value so 1/2 and 2/4 are equal. It returns
+// ErrInvalidPrice if any of the prices is not valid.
+func Cmp(a, b xdr.Price) (int, error) {
+	if !isValid(a) || !isValid(b) {
+		return 0, ErrInvalidPrice
+	}
+
+	// Both products fit in int64 as numerators and denominators are int32.
+	l := int64(a.N) * int64(b.D)
+	r := int64(b.N) * int64(a.D)
+	switch {
+	case l < r:
+		return -1, nil
+	case l > r:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// ToRat returns the exact value of the price `p`.
+func ToRat(p xdr.Price) (*big.Rat, error) {
+	if !isValid(p) {
+		return nil, ErrInvalidPrice
+	}
+	return big.NewRat(int64(p.N), int64(p.D)), nil
+}
+
+// FromRat returns the price equal to `r` if its reduced numerator and
+// denominator fit in int32. Otherwise, it returns the best approximation of
+// `r` with a numerator and denominator within int32 selected using `mode`:
+//
+//   - RoundDown returns the closest price lower than `r`,
+//   - RoundUp returns the closest price greater than `r`,
+//   - RoundHalfUp and RoundHalfEven return the closer of the two. A tie is
+//     resolved to the greater price by RoundHalfUp and to the price with an
+//     even numerator by RoundHalfEven.
+//
+// It returns ErrInvalidPrice if `r` is not positive and ErrOverflow if the
+// selected price doesn't fit in int32.
+func FromRat(r *big.Rat, mode amount.RoundingMode) (xdr.Price, error) {
+	if r.Sign() <= 0 {
+		return xdr.Price{}, ErrInvalidPrice
+	}
+
+	maxInt32 := big.NewInt(math.MaxInt32)
+	if r.Num().Cmp(maxInt32) <= 0 && r.Denom().Cmp(maxInt32) <= 0 {
+		return xdr.Price{N: xdr.Int32(r.Num().Int64()), D: xdr.Int32(r.Denom().Int64())}, nil
+	}
+
+	lower, upper := boundingPrices(r)
+	var p fraction
+	switch mode {
+	case amount.RoundDown:
+		p = lower
+	case amount.RoundUp:
+		p = upper
+	case amount.RoundHalfUp, amount.RoundHalfEven:
+		if upper.d == 0 {
+			p = lower
+			break
+		}
+
+		// Compare r - lower with upper - r.
+		twice := new(big.Rat).Mul(r, big.NewRat(2, 1))
+		sum := new(big.Rat).Add(lower.rat(), upper.rat())
+		switch twice.Cmp(sum) {
+		case -1:
+			p = lower
+		case 1:
+			p = upper
+		default:
+			p = upper
+			if mode == amount.RoundHalfEven && upper.n%2 == 1 {
+				p = lower
+			}
+		}
+	default:
+		return xdr.Price{}, errors.New("unknown rounding mode")
+	}
+
+	if p.n == 0 || p.d == 0 {
+		return xdr.Price{}, ErrOverflow
+	}
+	return xdr.Price{N: xdr.Int32(p.n), D: xdr.Int32(p.d)}, nil
+}
+
+// Format returns a decimal representation of the price `p` with exactly
+// `decimals` digits after the decimal point, rounded using `mode`.
+func Format(p xdr.Price, decimals int, mode amount.RoundingMode) (string, error) {
+	r, err := ToRat(p)
+	if err != nil {
+		return "", err
+	}
+	return amount.FormatRat(r, decimals, mode), nil
+}
+
+func isValid(p xdr.Price) bool {
+	return p.N > 0 && p.D > 0
+}
+
+// fraction is a non-negative fraction, possibly 1/0 meaning infinity.
+type fraction struct {
+	n, d int64
+}
+
+func (f fraction) rat() *big.Rat {
+	return big.NewRat(f.n, f.d)
+}
+
+// boundingPrices returns the closest fractions lower and greater than
+// positive `r` with numerators and denominators not greater than
+// math.MaxInt32. The lower fraction can be 0/1 and the greater 1/0 when `r`
+// is out of the range of prices. `r` must not be representable exactly.
+//
+// It walks the Stern-Brocot tree: lower and upper are always neighbours in
+// the tree so there is no fraction between them with a smaller numerator or
+// denominator than their mediant. Moves in the same direction are done in
+// batches so the number of iterations is bounded by the length of the
+// continued fraction of `r`.
+func boundingPrices(r *big.Rat) (fraction, fraction) {
+	lower, upper := fraction{0, 1}, fraction{1, 0}
+	for {
+		// Largest t such that (lower + t*upper) <= r. As r is not equal to any
+		// bounded fraction it's actually always < r.
+		t := steps(r, lower, upper, true)
+		if t > 0 {
+			lower = fraction{lower.n + t*upper.n, lower.d + t*upper.d}
+			continue
+		}
+
+		t = steps(r, upper, lower, false)
+		if t > 0 {
+			upper = fraction{upper.n + t*lower.n, upper.d + t*lower.d}
+			continue
+		}
+
+		return lower, upper
+	}
+}
+
+// steps returns how many times `by` can be added to `from` (adding
+// numerators and denominators) before the result passes `r` or exceeds
+// math.MaxInt32. `below` tells if `from` is lower than `r`.
+func steps(r *big.Rat, from, by fraction, below bool) int64 {
+	// For from = a/b and by = c/d, solve (a + t*c) / (b + t*d) <= r (or >= r)
+	// for t which gives t <= (r*b - a) / (c - r*d) (or the negated
+	// numerator and denominator).
+	num := new(big.Rat).Sub(new(big.Rat).Mul(r, big.NewRat(from.d, 1)), big.NewRat(from.n, 1))
+	den := new(big.Rat).Sub(big.NewRat(by.n, 1), new(big.Rat).Mul(r, big.NewRat(by.d, 1)))
+	if !below {
+		num.Neg(num)
+		den.Neg(den)
+	}
+
+	var t int64 = math.MaxInt32
+	if den.Sign() > 0 {
+		q := new(big.Rat).Quo(num, den)
+		quo := new(big.Int).Quo(q.Num(), q.Denom())
+		if quo.IsInt64() && quo.Int64() < t {
+			t = quo.Int64()
+		}
+	}
+	if by.n > 0 && (math.MaxInt32-from.n)/by.n < t {
+		t = (math.MaxInt32 - from.n) / by.n
+	}
+	if by.d > 0 && (math.MaxInt32-from.d)/by.d < t {
+		t = (math.MaxInt32 - from.d) / by.d
+	}
+	if t < 0 {
+		return 0
+	}
+	return t
+}
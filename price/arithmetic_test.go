@@ -0,0 +1,123 @@
+package price
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvertAndCmp(t *testing.T) {
+	p, err := Invert(xdr.Price{N: 1, D: 3})
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Price{N: 3, D: 1}, p)
+
+	_, err = Invert(xdr.Price{N: 0, D: 3})
+	assert.Equal(t, ErrInvalidPrice, err)
+
+	for _, c := range []struct {
+		a, b     xdr.Price
+		expected int
+	}{
+		{xdr.Price{N: 1, D: 2}, xdr.Price{N: 2, D: 4}, 0},
+		{xdr.Price{N: 1, D: 3}, xdr.Price{N: 1, D: 2}, -1},
+		{xdr.Price{N: math.MaxInt32, D: 1}, xdr.Price{N: math.MaxInt32, D: 2}, 1},
+		{xdr.Price{N: math.MaxInt32, D: math.MaxInt32 - 1}, xdr.Price{N: math.MaxInt32 - 1, D: math.MaxInt32 - 2}, -1},
+	} {
+		cmp, err := Cmp(c.a, c.b)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, cmp, "%v %v", c.a, c.b)
+	}
+
+	_, err = Cmp(xdr.Price{N: 1, D: 2}, xdr.Price{N: 1, D: 0})
+	assert.Equal(t, ErrInvalidPrice, err)
+}
+
+func TestRatConversion(t *testing.T) {
+	r, err := ToRat(xdr.Price{N: 2, D: 4})
+	require.NoError(t, err)
+	assert.Equal(t, big.NewRat(1, 2), r)
+
+	_, err = ToRat(xdr.Price{N: -1, D: 4})
+	assert.Equal(t, ErrInvalidPrice, err)
+
+	// Prices are converted back exactly.
+	for _, p := range []xdr.Price{{N: 1, D: 2}, {N: math.MaxInt32, D: math.MaxInt32 - 1}, {N: 1, D: math.MaxInt32}} {
+		r, err := ToRat(p)
+		require.NoError(t, err)
+		for _, mode := range []amount.RoundingMode{amount.RoundDown, amount.RoundUp, amount.RoundHalfUp} {
+			back, err := FromRat(r, mode)
+			require.NoError(t, err)
+			assert.Equal(t, p, back)
+		}
+	}
+
+	// pi = 3.14159265358979323846...
+	pi, ok := new(big.Rat).SetString("3.14159265358979323846")
+	require.True(t, ok)
+	lower, err := FromRat(pi, amount.RoundDown)
+	require.NoError(t, err)
+	upper, err := FromRat(pi, amount.RoundUp)
+	require.NoError(t, err)
+	nearest, err := FromRat(pi, amount.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Price{N: 1068966896, D: 340262731}, lower)
+	assert.Equal(t, xdr.Price{N: 1480524883, D: 471265707}, upper)
+	assert.Contains(t, []xdr.Price{lower, upper}, nearest)
+	assertBounds(t, pi, lower, upper)
+
+	// A tie between the bounds of pi.
+	lowerRat, _ := ToRat(lower)
+	upperRat, _ := ToRat(upper)
+	tie := new(big.Rat).Add(lowerRat, upperRat)
+	tie.Quo(tie, big.NewRat(2, 1))
+	p, err := FromRat(tie, amount.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, upper, p)
+	p, err = FromRat(tie, amount.RoundHalfEven)
+	require.NoError(t, err)
+	assert.Equal(t, lower, p)
+
+	// Out of range.
+	p, err = FromRat(big.NewRat(3*math.MaxInt32, 2), amount.RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Price{N: math.MaxInt32, D: 1}, p)
+	_, err = FromRat(big.NewRat(3*math.MaxInt32, 2), amount.RoundUp)
+	assert.Equal(t, ErrOverflow, err)
+	_, err = FromRat(big.NewRat(1, 2*math.MaxInt32), amount.RoundDown)
+	assert.Equal(t, ErrOverflow, err)
+	_, err = FromRat(big.NewRat(0, 1), amount.RoundDown)
+	assert.Equal(t, ErrInvalidPrice, err)
+}
+
+// assertBounds checks that lower < r < upper and that there are no prices
+// with small denominators between them.
+func assertBounds(t *testing.T, r *big.Rat, lower, upper xdr.Price) {
+	lowerRat, _ := ToRat(lower)
+	upperRat, _ := ToRat(upper)
+	assert.Equal(t, -1, lowerRat.Cmp(r))
+	assert.Equal(t, 1, upperRat.Cmp(r))
+	for d := int64(1); d < 10000; d++ {
+		n := new(big.Int).Quo(new(big.Int).Mul(r.Num(), big.NewInt(d)), r.Denom())
+		for _, candidate := range []*big.Rat{new(big.Rat).SetFrac(n, big.NewInt(d)), new(big.Rat).SetFrac(n.Add(n, big.NewInt(1)), big.NewInt(d))} {
+			assert.False(t, candidate.Cmp(lowerRat) > 0 && candidate.Cmp(upperRat) < 0, candidate.String())
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s, err := Format(xdr.Price{N: 2, D: 3}, 4, amount.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, "0.6667", s)
+
+	s, err = Format(xdr.Price{N: 2, D: 3}, 4, amount.RoundDown)
+	require.NoError(t, err)
+	assert.Equal(t, "0.6666", s)
+
+	_, err = Format(xdr.Price{N: 2, D: 0}, 4, amount.RoundDown)
+	assert.Equal(t, ErrInvalidPrice, err)
+}
@@ -135,6 +135,20 @@ var configOpts = support.ConfigOptions{
 		},
 		Usage: "comma-separated list of stellar history archives to connect with",
 	},
+	&support.ConfigOption{
+		Name:        "history-archive-cache-path",
+		ConfigKey:   &config.HistoryArchiveCachePath,
+		OptType:     types.String,
+		FlagDefault: "",
+		Usage:       "directory where buckets and checkpoint files downloaded from history archives are cached by experimental ingestion, disabled when empty",
+	},
+	&support.ConfigOption{
+		Name:        "history-archive-cache-size",
+		ConfigKey:   &config.HistoryArchiveCacheSize,
+		OptType:     types.Uint,
+		FlagDefault: uint(10240),
+		Usage:       "size limit of the history archive cache in megabytes, 0 means no limit",
+	},
 	&support.ConfigOption{
 		Name:        "port",
 		ConfigKey:   &config.Port,
@@ -257,6 +271,23 @@ var configOpts = support.ConfigOptions{
 		FlagDefault: uint(4),
 		Usage:       "the maximum number of assets on the path in `/paths` endpoint",
 	},
+	&support.ConfigOption{
+		Name:        "path-finding-timeout",
+		ConfigKey:   &config.PathFindingTimeout,
+		OptType:     types.Int,
+		FlagDefault: 1000,
+		CustomSetValue: func(co *support.ConfigOption) {
+			*(co.ConfigKey.(*time.Duration)) = time.Duration(viper.GetInt(co.Name)) * time.Millisecond
+		},
+		Usage: "defines the time (in milliseconds) after which in-memory path finding used by experimental ingestion stops and returns the best paths found so far, 0 means no limit",
+	},
+	&support.ConfigOption{
+		Name:        "path-finding-max-iterations",
+		ConfigKey:   &config.PathFindingMaxIterations,
+		OptType:     types.Int,
+		FlagDefault: 100000,
+		Usage:       "the maximum number of partial paths expanded by in-memory path finding used by experimental ingestion before it returns the best paths found so far, 0 means no limit",
+	},
 	&support.ConfigOption{
 		Name:      "network-passphrase",
 		ConfigKey: &config.NetworkPassphrase,
@@ -357,7 +388,21 @@ var configOpts = support.ConfigOptions{
 		ConfigKey:   &config.IngestStateReaderTempSet,
 		OptType:     types.String,
 		FlagDefault: "memory",
-		Usage:       "defines where to store temporary objects during state ingestion: `memory` (default, more RAM usage, faster) or `postgres` (less RAM usage, slower)",
+		Usage:       "defines where to store temporary objects during state ingestion: `memory` (default, more RAM usage, faster), `disk` (less RAM usage, requires fast local disk) or `postgres` (less RAM usage, slower)",
+	},
+	&support.ConfigOption{
+		Name:        "ingest-state-reader-temp-set-path",
+		ConfigKey:   &config.IngestStateReaderTempSetPath,
+		OptType:     types.String,
+		FlagDefault: "",
+		Usage:       "directory where temporary objects are stored during state ingestion when `ingest-state-reader-temp-set` is `disk`, defaults to the system temporary directory",
+	},
+	&support.ConfigOption{
+		Name:        "ingest-orderbook-snapshot-path",
+		ConfigKey:   &config.IngestOrderBookSnapshotPath,
+		OptType:     types.String,
+		FlagDefault: "",
+		Usage:       "directory where experimental ingestion saves a snapshot of the in-memory order book at checkpoint ledgers and on shutdown and loads it from on start to make path finding available sooner, disabled when empty",
 	},
 	&support.ConfigOption{
 		Name:        "ingest-disable-state-verification",
@@ -417,7 +462,9 @@ func initConfig() {
 	// Configure log level
 	log.DefaultLogger.Logger.SetLevel(config.LogLevel)
 
-	if config.IngestStateReaderTempSet != "memory" && config.IngestStateReaderTempSet != "postgres" {
+	switch config.IngestStateReaderTempSet {
+	case "memory", "disk", "postgres":
+	default:
 		log.Fatal("Invalid `ingest-state-reader-temp-set` value: " + config.IngestStateReaderTempSet)
 	}
 
@@ -1,8 +1,12 @@
 package horizon
 
 import (
+	"net/http"
+
 	metrics "github.com/rcrowley/go-metrics"
+	supportPipeline "github.com/stellar/go/exp/support/pipeline"
 	"github.com/stellar/go/services/horizon/internal/actions"
+	"github.com/stellar/go/support/log"
 	"github.com/stellar/go/support/render/hal"
 )
 
@@ -89,3 +93,21 @@ func (action *MetricsAction) LoadSnapshot() {
 		action.Snapshot[name] = values
 	})
 }
+
+// pipelineMetricsHandler renders metrics of experimental ingestion pipelines
+// nodes in Prometheus text exposition format. Nothing is rendered when
+// experimental ingestion is disabled.
+func pipelineMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	pipelines := map[string]supportPipeline.NodeMetrics{}
+	app := AppFromContext(r.Context())
+	if app != nil && app.expingester != nil {
+		pipelines = app.expingester.PipelineMetrics()
+	}
+
+	err := supportPipeline.WritePrometheus(w, pipelines)
+	if err != nil {
+		log.Ctx(r.Context()).WithField("err", err).Error("Error writing pipeline metrics")
+	}
+}
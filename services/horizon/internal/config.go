@@ -17,6 +17,14 @@ type Config struct {
 	HistoryArchiveURLs     []string
 	Port                   uint
 
+	// HistoryArchiveCachePath is a directory where buckets and checkpoint
+	// files downloaded from history archives are cached. Caching is disabled
+	// when empty.
+	HistoryArchiveCachePath string
+	// HistoryArchiveCacheSize is the size limit of the history archive cache
+	// in megabytes, 0 means no limit.
+	HistoryArchiveCacheSize uint
+
 	// MaxDBConnections has a priority over all 4 values below.
 	MaxDBConnections            int
 	HorizonDBMaxOpenConnections int
@@ -50,8 +58,23 @@ type Config struct {
 	// * Accounts for signers endpoint
 	EnableExperimentalIngestion bool
 	// IngestStateReaderTempSet defines where to store temporary objects during state
-	// ingestion. Possible options are `memory` and `postgres`.
+	// ingestion. Possible options are `memory`, `disk` and `postgres`.
 	IngestStateReaderTempSet string
+	// IngestStateReaderTempSetPath is a directory where temporary objects are
+	// stored when IngestStateReaderTempSet is `disk`. The system temporary
+	// directory is used when empty.
+	IngestStateReaderTempSetPath string
+	// IngestOrderBookSnapshotPath is a directory where a snapshot of the
+	// in-memory order book is saved at checkpoint ledgers and on shutdown and
+	// loaded from on start.
+	// Snapshots are disabled when empty.
+	IngestOrderBookSnapshotPath string
+	// PathFindingTimeout is the time after which in-memory path finding
+	// stops and returns the best paths found so far. No limit when zero.
+	PathFindingTimeout time.Duration
+	// PathFindingMaxIterations is the maximum number of partial paths
+	// expanded by in-memory path finding. No limit when zero.
+	PathFindingMaxIterations int
 	// IngestFailedTransactions toggles whether to ingest failed transactions
 	IngestFailedTransactions bool
 	// CursorName is the cursor used for ingesting from stellar-core.
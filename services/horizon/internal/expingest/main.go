@@ -14,6 +14,7 @@ import (
 	"github.com/stellar/go/exp/ingest/io"
 	"github.com/stellar/go/exp/ingest/ledgerbackend"
 	"github.com/stellar/go/exp/orderbook"
+	supportPipeline "github.com/stellar/go/exp/support/pipeline"
 	"github.com/stellar/go/services/horizon/internal/db2/history"
 	"github.com/stellar/go/support/db"
 	"github.com/stellar/go/support/errors"
@@ -61,7 +62,20 @@ type Config struct {
 	// Set MaxStreamRetries to 0 if there should be no retry attempts
 	MaxStreamRetries int
 
+	// HistoryArchiveCacheDir is a directory where files downloaded from the
+	// history archive are cached, see historyarchive.CachingArchiveBackend.
+	// Caching is disabled when empty.
+	HistoryArchiveCacheDir string
+	// HistoryArchiveCacheSize is the size limit of the cache in bytes, 0 means
+	// no limit.
+	HistoryArchiveCacheSize int64
+
 	OrderBookGraph *orderbook.OrderBookGraph
+	// OrderBookGraphSnapshotDir is a directory where a snapshot of the order
+	// book graph is saved at checkpoint ledgers and on shutdown. On start the graph is loaded from the
+	// snapshot, and ledgers ingested after the snapshot was saved are replayed,
+	// instead of loading all offers from a database. Disabled when empty.
+	OrderBookGraphSnapshotDir string
 }
 
 type dbQ interface {
@@ -99,12 +113,17 @@ type System struct {
 	historyQ         dbQ
 	historySession   dbSession
 	graph            *orderbook.OrderBookGraph
+	ledgerBackend    ledgerbackend.LedgerBackend
 	retry            retry
 	stateReady       bool
 	stateReadyLock   sync.RWMutex
 	maxStreamRetries int
 	wg               sync.WaitGroup
 	shutdown         chan struct{}
+	// pipelines contains session pipelines, used to report metrics.
+	pipelines map[pType]supportPipeline.PipelineInterface
+
+	orderBookGraphSnapshotDir string
 
 	// stateVerificationRunning is true when verification routine is currently
 	// running.
@@ -132,7 +151,7 @@ func (r alwaysRetry) onError(f func() error) {
 }
 
 func NewSystem(config Config) (*System, error) {
-	archive, err := createArchive(config.HistoryArchiveURL)
+	archive, err := createArchive(config)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating history archive")
 	}
@@ -165,9 +184,15 @@ func NewSystem(config Config) (*System, error) {
 		historySession:           config.HistorySession,
 		historyQ:                 historyQ,
 		graph:                    config.OrderBookGraph,
+		ledgerBackend:            ledgerBackend,
 		retry:                    alwaysRetry{time.Second},
 		disableStateVerification: config.DisableStateVerification,
 		maxStreamRetries:         config.MaxStreamRetries,
+		pipelines: map[pType]supportPipeline.PipelineInterface{
+			statePipeline:  session.StatePipeline,
+			ledgerPipeline: session.LedgerPipeline,
+		},
+		orderBookGraphSnapshotDir: config.OrderBookGraphSnapshotDir,
 	}
 
 	addPipelineHooks(
@@ -211,7 +236,8 @@ func NewSystem(config Config) (*System, error) {
 //   * If instance is a leader, we update the order book graph by running state
 //     pipeline normally.
 //   * If instance is NOT a leader, we build a graph from offers present in a
-//     database. We completely omit state pipeline in this case.
+//     database (or from a snapshot, see Config.OrderBookGraphSnapshotDir). We
+//     completely omit state pipeline in this case.
 // * For resuming:
 //   * If instances is a leader, it runs full ledger pipeline, including updating
 //     a database.
@@ -309,9 +335,9 @@ func (s *System) Run() {
 			log.WithField("last_ledger", lastIngestedLedger).
 				Info("Resuming ingestion system from last processed ledger...")
 
-			err = loadOrderBookGraphFromDB(s.historyQ, s.graph, lastIngestedLedger)
+			err = s.loadOrderBookGraph(lastIngestedLedger)
 			if err != nil {
-				return errors.Wrap(err, "Error loading order book graph from db")
+				return err
 			}
 		}
 
@@ -397,6 +423,16 @@ func (s *System) StateReady() bool {
 	return s.stateReady
 }
 
+// PipelineMetrics returns metrics of state and ledger pipelines nodes, see
+// supportPipeline.WritePrometheus.
+func (s *System) PipelineMetrics() map[string]supportPipeline.NodeMetrics {
+	metrics := make(map[string]supportPipeline.NodeMetrics, len(s.pipelines))
+	for name, p := range s.pipelines {
+		metrics[string(name)] = p.Metrics()
+	}
+	return metrics
+}
+
 func (s *System) setStateReady() {
 	s.stateReadyLock.Lock()
 	defer s.stateReadyLock.Unlock()
@@ -420,16 +456,23 @@ func (s *System) Shutdown() {
 	log.Info("Shutting down ingestion system...")
 	s.session.Shutdown()
 	s.stateVerificationMutex.Lock()
-	defer s.stateVerificationMutex.Unlock()
 	if s.stateVerificationRunning {
 		log.Info("Shutting down state verifier...")
 	}
 	close(s.shutdown)
+	s.stateVerificationMutex.Unlock()
+
+	// Wait for the state verifier to return before saving the snapshot.
+	s.wg.Wait()
+	s.saveOrderBookGraphSnapshot()
 }
 
-func createArchive(archiveURL string) (*historyarchive.Archive, error) {
+func createArchive(config Config) (*historyarchive.Archive, error) {
 	return historyarchive.Connect(
-		archiveURL,
-		historyarchive.ConnectOptions{},
+		config.HistoryArchiveURL,
+		historyarchive.ConnectOptions{
+			CacheDir:  config.HistoryArchiveCacheDir,
+			CacheSize: config.HistoryArchiveCacheSize,
+		},
 	)
 }
@@ -0,0 +1,139 @@
+package expingest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/exp/ingest/ledgerbackend"
+	"github.com/stellar/go/exp/ingest/pipeline"
+	"github.com/stellar/go/exp/orderbook"
+	"github.com/stellar/go/support/errors"
+	logpkg "github.com/stellar/go/support/log"
+)
+
+// orderBookGraphSnapshotPath returns the path of the order book graph snapshot
+// in `dir`. The ingestion version is a part of the file name so snapshots
+// created by older versions are not loaded.
+func orderBookGraphSnapshotPath(dir string) string {
+	return filepath.Join(dir, fmt.Sprintf("orderbook-v%d.snapshot", CurrentVersion))
+}
+
+// loadOrderBookGraph loads the order book graph from a snapshot, if there is
+// one, and replays ledgers ingested after the snapshot was created. If there
+// is no snapshot or it can't be used, the graph is loaded from a database.
+func (s *System) loadOrderBookGraph(lastIngestedLedger uint32) error {
+	if s.orderBookGraphSnapshotDir != "" {
+		err := loadOrderBookGraphFromSnapshot(
+			s.ledgerBackend,
+			s.graph,
+			orderBookGraphSnapshotPath(s.orderBookGraphSnapshotDir),
+			lastIngestedLedger,
+		)
+		if err == nil {
+			return nil
+		}
+
+		log.WithField("err", err).
+			Info("Cannot load order book graph from a snapshot, loading from a database")
+		s.graph.Clear()
+	}
+
+	err := loadOrderBookGraphFromDB(s.historyQ, s.graph, lastIngestedLedger)
+	if err != nil {
+		return errors.Wrap(err, "Error loading order book graph from db")
+	}
+	return nil
+}
+
+// saveOrderBookGraphSnapshot writes a snapshot of the order book graph if
+// snapshots are enabled and the graph has been loaded.
+func (s *System) saveOrderBookGraphSnapshot() {
+	if s.orderBookGraphSnapshotDir == "" || s.graph.LastLedger() == 0 {
+		return
+	}
+
+	start := time.Now()
+	err := s.graph.WriteSnapshotFile(orderBookGraphSnapshotPath(s.orderBookGraphSnapshotDir))
+	if err != nil {
+		log.WithField("err", err).Error("Error saving order book graph snapshot")
+		return
+	}
+
+	log.WithField("duration", time.Since(start).Seconds()).
+		Info("Saved order book graph snapshot")
+}
+
+func loadOrderBookGraphFromSnapshot(
+	backend ledgerbackend.LedgerBackend,
+	graph *orderbook.OrderBookGraph,
+	path string,
+	lastIngestedLedger uint32,
+) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	log.Info("Loading order book graph from a snapshot...")
+	start := time.Now()
+
+	err := graph.ReadSnapshotFile(path)
+	if err != nil {
+		return err
+	}
+
+	snapshotLedger := graph.LastLedger()
+	if snapshotLedger == 0 || snapshotLedger > lastIngestedLedger {
+		return errors.Errorf(
+			"snapshot ledger (%d) is not in range of ingested ledgers [1, %d]",
+			snapshotLedger,
+			lastIngestedLedger,
+		)
+	}
+
+	err = replayOrderBookGraph(backend, graph, snapshotLedger+1, lastIngestedLedger)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(logpkg.F{
+		"snapshot_ledger": snapshotLedger,
+		"last_ledger":     lastIngestedLedger,
+		"duration":        time.Since(start).Seconds(),
+	}).Info("Finished loading order book graph from a snapshot")
+	return nil
+}
+
+// replayOrderBookGraph applies offer changes in ledgers [from, to] to the
+// order book graph.
+func replayOrderBookGraph(
+	backend ledgerbackend.LedgerBackend,
+	graph *orderbook.OrderBookGraph,
+	from, to uint32,
+) error {
+	defer graph.Discard()
+
+	ledgerPipeline := &pipeline.LedgerPipeline{}
+	ledgerPipeline.SetRoot(orderBookGraphLedgerNode(graph))
+
+	for sequence := from; sequence <= to; sequence++ {
+		reader, err := io.NewDBLedgerReader(sequence, backend)
+		if err != nil {
+			return errors.Wrapf(err, "Error reading ledger %d", sequence)
+		}
+
+		err = <-ledgerPipeline.Process(reader)
+		if err != nil {
+			return errors.Wrapf(err, "Error processing ledger %d", sequence)
+		}
+
+		err = graph.Apply(sequence)
+		if err != nil {
+			return errors.Wrapf(err, "Error applying ledger %d", sequence)
+		}
+	}
+
+	return nil
+}
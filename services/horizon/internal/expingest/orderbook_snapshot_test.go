@@ -0,0 +1,105 @@
+package expingest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stellar/go/exp/ingest/ledgerbackend"
+	"github.com/stellar/go/exp/orderbook"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func offerLedgerEntry(offer xdr.OfferEntry) *xdr.LedgerEntry {
+	return &xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type:  xdr.LedgerEntryTypeOffer,
+			Offer: &offer,
+		},
+	}
+}
+
+// streamBackendWithLedgers returns a backend with ledgers [from, to]. The
+// first ledger updates eurOffer amount in upgrade changes.
+func streamBackendWithLedgers(t *testing.T, from, to uint32, updatedOffer xdr.OfferEntry) *ledgerbackend.StreamBackend {
+	var buf bytes.Buffer
+	for sequence := from; sequence <= to; sequence++ {
+		lcm := ledgerbackend.LedgerCloseMeta{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(sequence)},
+			},
+		}
+		if sequence == from {
+			lcm.UpgradesMeta = []xdr.LedgerEntryChanges{
+				{
+					{
+						Type:  xdr.LedgerEntryChangeTypeLedgerEntryState,
+						State: offerLedgerEntry(eurOffer),
+					},
+					{
+						Type:    xdr.LedgerEntryChangeTypeLedgerEntryUpdated,
+						Updated: offerLedgerEntry(updatedOffer),
+					},
+				},
+			}
+		}
+		assert.NoError(t, ledgerbackend.WriteLedgerCloseMeta(&buf, lcm))
+	}
+	return ledgerbackend.NewStreamBackend(ioutil.NopCloser(&buf))
+}
+
+func TestLoadOrderBookGraphFromSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orderbook-snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := orderBookGraphSnapshotPath(dir)
+
+	graph := orderbook.NewOrderBookGraph()
+	assert.NoError(t, graph.AddOffer(eurOffer).Apply(10))
+	assert.NoError(t, graph.WriteSnapshotFile(path))
+
+	updatedOffer := eurOffer
+	updatedOffer.Amount = 100
+
+	loaded := orderbook.NewOrderBookGraph()
+	err = loadOrderBookGraphFromSnapshot(
+		streamBackendWithLedgers(t, 11, 12, updatedOffer),
+		loaded,
+		path,
+		12,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(12), loaded.LastLedger())
+	assert.Equal(t, []xdr.OfferEntry{updatedOffer}, loaded.Offers())
+}
+
+func TestLoadOrderBookGraphFromInvalidSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orderbook-snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := orderBookGraphSnapshotPath(dir)
+
+	loaded := orderbook.NewOrderBookGraph()
+	err = loadOrderBookGraphFromSnapshot(nil, loaded, path, 12)
+	assert.True(t, os.IsNotExist(err))
+
+	graph := orderbook.NewOrderBookGraph()
+	assert.NoError(t, graph.AddOffer(eurOffer).Apply(20))
+	assert.NoError(t, graph.WriteSnapshotFile(path))
+
+	// Snapshot created after the last ingested ledger
+	err = loadOrderBookGraphFromSnapshot(nil, loaded, path, 12)
+	assert.EqualError(t, err, "snapshot ledger (20) is not in range of ingested ledgers [1, 12]")
+
+	// Missing ledgers
+	err = loadOrderBookGraphFromSnapshot(
+		streamBackendWithLedgers(t, 21, 21, eurOffer),
+		loaded,
+		path,
+		22,
+	)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Error reading ledger 22")
+}
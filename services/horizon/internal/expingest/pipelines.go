@@ -285,6 +285,12 @@ func postProcessingHook(
 		return errors.Wrap(err, "Error applying order book changes")
 	}
 
+	// Save a snapshot at checkpoint ledgers so a recent one is available if
+	// Horizon is not shut down gracefully.
+	if system != nil && historyarchive.IsCheckpoint(ledgerSeq) {
+		system.saveOrderBookGraphSnapshot()
+	}
+
 	stateInvalid, err := historyQ.GetExpStateInvalid()
 	if err != nil {
 		log.WithField("err", err).Error("Error getting state invalid value")
@@ -73,6 +73,10 @@ func initIngester(app *App) {
 func initExpIngester(app *App, orderBookGraph *orderbook.OrderBookGraph) {
 	var tempSet ingestio.TempSet = &ingestio.MemoryTempSet{}
 	switch app.config.IngestStateReaderTempSet {
+	case "disk":
+		tempSet = &ingestio.DiskTempSet{
+			Dir: app.config.IngestStateReaderTempSetPath,
+		}
 	case "postgres":
 		tempSet = &ingestio.PostgresTempSet{
 			Session: app.HorizonSession(context.Background()),
@@ -86,12 +90,15 @@ func initExpIngester(app *App, orderBookGraph *orderbook.OrderBookGraph) {
 		// TODO:
 		// Use the first archive for now. We don't have a mechanism to
 		// use multiple archives at the same time currently.
-		HistoryArchiveURL:        app.config.HistoryArchiveURLs[0],
-		StellarCoreURL:           app.config.StellarCoreURL,
-		OrderBookGraph:           orderBookGraph,
-		TempSet:                  tempSet,
-		MaxStreamRetries:         3,
-		DisableStateVerification: app.config.IngestDisableStateVerification,
+		HistoryArchiveURL:         app.config.HistoryArchiveURLs[0],
+		HistoryArchiveCacheDir:    app.config.HistoryArchiveCachePath,
+		HistoryArchiveCacheSize:   int64(app.config.HistoryArchiveCacheSize) << 20,
+		StellarCoreURL:            app.config.StellarCoreURL,
+		OrderBookGraph:            orderBookGraph,
+		OrderBookGraphSnapshotDir: app.config.IngestOrderBookSnapshotPath,
+		TempSet:                   tempSet,
+		MaxStreamRetries:          3,
+		DisableStateVerification:  app.config.IngestDisableStateVerification,
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -100,7 +107,10 @@ func initExpIngester(app *App, orderBookGraph *orderbook.OrderBookGraph) {
 
 func initPathFinder(app *App, orderBookGraph *orderbook.OrderBookGraph) {
 	if app.config.EnableExperimentalIngestion {
-		app.paths = simplepath.NewInMemoryFinder(orderBookGraph)
+		finder := simplepath.NewInMemoryFinder(orderBookGraph)
+		finder.SearchTimeout = app.config.PathFindingTimeout
+		finder.MaxSearchIterations = app.config.PathFindingMaxIterations
+		app.paths = finder
 	} else {
 		app.paths = &simplepath.Finder{app.CoreQ()}
 	}
@@ -1,6 +1,8 @@
 package simplepath
 
 import (
+	"time"
+
 	"github.com/go-errors/errors"
 	"github.com/stellar/go/exp/orderbook"
 	"github.com/stellar/go/services/horizon/internal/paths"
@@ -22,6 +24,12 @@ var (
 // using the experimental in memory orderbook
 type InMemoryFinder struct {
 	graph *orderbook.OrderBookGraph
+	// SearchTimeout is the time after which a single path search stops and
+	// returns the best paths found so far. No limit when zero.
+	SearchTimeout time.Duration
+	// MaxSearchIterations is the maximum number of partial paths expanded by a
+	// single path search. No limit when zero.
+	MaxSearchIterations int
 }
 
 // NewInMemoryFinder constructs a new InMemoryFinder instance
@@ -31,6 +39,15 @@ func NewInMemoryFinder(graph *orderbook.OrderBookGraph) InMemoryFinder {
 	}
 }
 
+// searchLimits returns limits of a path search starting now
+func (finder InMemoryFinder) searchLimits() orderbook.SearchLimits {
+	limits := orderbook.SearchLimits{MaxIterations: finder.MaxSearchIterations}
+	if finder.SearchTimeout > 0 {
+		limits.Deadline = time.Now().Add(finder.SearchTimeout)
+	}
+	return limits
+}
+
 // Find implements the path payments finder interface
 func (finder InMemoryFinder) Find(q paths.Query, maxLength uint) ([]paths.Path, uint32, error) {
 	if finder.graph.IsEmpty() {
@@ -44,7 +61,8 @@ func (finder InMemoryFinder) Find(q paths.Query, maxLength uint) ([]paths.Path,
 		return nil, 0, errors.New("invalid value of maxLength")
 	}
 
-	orderbookPaths, lastLedger, err := finder.graph.FindPaths(
+	orderbookPaths, lastLedger, err := finder.graph.FindPathsWithLimits(
+		finder.searchLimits(),
 		int(maxLength),
 		q.DestinationAsset,
 		q.DestinationAmount,
@@ -89,7 +107,8 @@ func (finder InMemoryFinder) FindFixedPaths(
 		return nil, 0, errors.New("invalid value of maxLength")
 	}
 
-	orderbookPaths, lastLedger, err := finder.graph.FindFixedPaths(
+	orderbookPaths, lastLedger, err := finder.graph.FindFixedPathsWithLimits(
+		finder.searchLimits(),
 		int(maxLength),
 		sourceAsset,
 		amountToSpend,
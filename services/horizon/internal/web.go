@@ -174,6 +174,7 @@ func (w *web) mustInstallActions(
 	r := w.router
 	r.Get("/", RootAction{}.Handle)
 	r.Get("/metrics", MetricsAction{}.Handle)
+	r.Get("/metrics/pipelines", pipelineMetricsHandler)
 
 	// ledger actions
 	r.Route("/ledgers", func(r chi.Router) {
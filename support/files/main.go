@@ -0,0 +1,49 @@
+// Package files contains helpers for working with files on a local disk.
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// TempSuffix is the suffix of temporary files created by WriteAtomic. Files
+// with this suffix are leftovers of interrupted writes and can be removed.
+const TempSuffix = ".tmp"
+
+// WriteAtomic creates or replaces the file at `path` with data written by
+// `write`. The data is written to a temporary file in the same directory,
+// which is synced and renamed to `path`, so readers never see a partially
+// written file and the previous file is preserved if writing fails. The
+// file is created with `perm` permissions.
+func WriteAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*"+TempSuffix)
+	if err != nil {
+		return errors.Wrap(err, "could not create temporary file")
+	}
+	// After a successful rename there is nothing to remove and the error is
+	// ignored.
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err = write(file); err != nil {
+		return err
+	}
+	if err = file.Chmod(perm); err != nil {
+		return errors.Wrap(err, "could not change temporary file permissions")
+	}
+	if err = file.Sync(); err != nil {
+		return errors.Wrap(err, "could not sync temporary file")
+	}
+	if err = file.Close(); err != nil {
+		return errors.Wrap(err, "could not close temporary file")
+	}
+
+	if err = os.Rename(file.Name(), path); err != nil {
+		return errors.Wrap(err, "could not rename temporary file")
+	}
+	return nil
+}
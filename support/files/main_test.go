@@ -0,0 +1,49 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+
+	err = WriteAtomic(path, 0644, func(w io.Writer) error {
+		_, err := w.Write([]byte("first"))
+		return err
+	})
+	require.NoError(t, err)
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(contents))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// Failed write preserves the previous file
+	err = WriteAtomic(path, 0644, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errors.New("write failed")
+	})
+	assert.EqualError(t, err, "write failed")
+
+	contents, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(contents))
+
+	// No temporary files are left
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, names)
+}
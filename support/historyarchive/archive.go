@@ -6,6 +6,7 @@ package historyarchive
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,6 +19,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/stellar/go/xdr"
 )
 
 const hexPrefixPat = "/[0-9a-f]{2}/[0-9a-f]{2}/[0-9a-f]{2}/"
@@ -36,6 +39,33 @@ type ConnectOptions struct {
 	S3Region         string
 	S3Endpoint       string
 	UnsignedRequests bool
+	// GCSEndpoint overrides the Google Cloud Storage API endpoint, for
+	// example to use an emulator. Defaults to $STORAGE_EMULATOR_HOST or
+	// https://storage.googleapis.com.
+	GCSEndpoint string
+	// GCSAccessToken is an OAuth2 access token sent with Google Cloud Storage
+	// requests. Defaults to $GCS_ACCESS_TOKEN. Public buckets can be read
+	// without it.
+	GCSAccessToken string
+	// AzureEndpoint overrides the Azure Blob Storage endpoint of the account,
+	// for example to use Azurite. Defaults to
+	// https://<account>.blob.core.windows.net.
+	AzureEndpoint string
+	// AzureAccountKey is the base64 encoded storage account key used to sign
+	// requests with Shared Key authorization. Defaults to $AZURE_STORAGE_KEY.
+	AzureAccountKey string
+	// AzureSASToken is a shared access signature appended to Azure Blob
+	// Storage requests. Defaults to $AZURE_STORAGE_SAS_TOKEN.
+	AzureSASToken string
+	// CacheDir enables a local on-disk cache of buckets and checkpoint files
+	// in the given directory, see CachingArchiveBackend. Every archive is
+	// cached in its own subdirectory. The cache answers Exists and Size for
+	// cached files without asking the archive, so it should only be enabled
+	// for archives that are read from, not for mirror or diff destinations.
+	CacheDir string
+	// CacheSize is the size limit of the cache of a single archive in bytes,
+	// 0 means no limit.
+	CacheSize int64
 }
 
 type ArchiveBackend interface {
@@ -62,6 +92,9 @@ type ArchiveInterface interface {
 	ListCategoryCheckpoints(cat string, pth string) (chan uint32, chan error)
 	GetXdrStreamForHash(hash Hash) (*XdrStream, error)
 	GetXdrStream(pth string) (*XdrStream, error)
+	GetLedgerHeaderHistoryEntries(ctx context.Context, chk uint32) ([]xdr.LedgerHeaderHistoryEntry, error)
+	GetTransactionHistoryEntries(ctx context.Context, chk uint32) ([]xdr.TransactionHistoryEntry, error)
+	GetTransactionHistoryResultEntries(ctx context.Context, chk uint32) ([]xdr.TransactionHistoryResultEntry, error)
 }
 
 var _ ArchiveInterface = &Archive{}
@@ -242,23 +275,16 @@ func Connect(u string, opts ConnectOptions) (*Archive, error) {
 	if err != nil {
 		return &arch, err
 	}
-	pth := parsed.Path
-	if parsed.Scheme == "s3" {
-		// Inside s3, all paths start _without_ the leading /
-		if len(pth) > 0 && pth[0] == '/' {
-			pth = pth[1:]
-		}
-		arch.backend, err = makeS3Backend(parsed.Host, pth, opts)
-	} else if parsed.Scheme == "file" {
-		pth = path.Join(parsed.Host, pth)
-		arch.backend = makeFsBackend(pth, opts)
-	} else if parsed.Scheme == "http" || parsed.Scheme == "https" {
-		arch.backend = makeHttpBackend(parsed, opts)
-	} else if parsed.Scheme == "mock" {
-		arch.backend = makeMockBackend(opts)
+	factory, ok := lookupBackend(parsed.Scheme)
+	if ok {
+		arch.backend, err = factory(parsed, opts)
 	} else {
 		err = errors.New("unknown URL scheme: '" + parsed.Scheme + "'")
 	}
+
+	if err == nil && opts.CacheDir != "" {
+		arch.backend, err = MakeCachingBackend(arch.backend, archiveCacheDir(opts.CacheDir, u), opts.CacheSize)
+	}
 	return &arch, err
 }
 
@@ -16,6 +16,7 @@ import (
 
 	"github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func GetTestS3Archive() *Archive {
@@ -240,6 +241,44 @@ func TestMirrorThenRepair(t *testing.T) {
 	assert.Equal(t, 0, countMissing(dst, opts))
 }
 
+func TestScanSummary(t *testing.T) {
+	defer cleanup()
+	opts := testOptions()
+	src := GetRandomPopulatedArchive()
+	dst := GetTestMockArchive()
+	Mirror(src, dst, opts)
+
+	has, err := dst.GetCheckpointHAS(0x7f)
+	require.NoError(t, err)
+	buckets, err := has.Buckets()
+	require.NoError(t, err)
+	files := dst.backend.(*MockArchiveBackend).files
+	delete(files, CategoryCheckpointPath("ledger", 0x7f))
+	delete(files, CategoryCheckpointPath("scp", 0xbf))
+	delete(files, BucketPath(buckets[0]))
+
+	for _, scan := range []func(*CommandOptions) error{
+		dst.ScanCheckpointsFast,
+		dst.ScanCheckpointsSlow,
+	} {
+		dst.ClearCachedInfo()
+		require.NoError(t, scan(opts))
+		require.NoError(t, dst.ScanBuckets(opts))
+
+		// Missing scp files are not reported, they are optional.
+		summary := dst.ScanSummary(opts)
+		assert.Equal(t, testRange(), summary.Range)
+		assert.Equal(t, []uint32{0x7f}, summary.MissingCheckpointFiles["ledger"])
+		assert.Empty(t, summary.MissingCheckpointFiles["results"])
+		assert.NotContains(t, summary.MissingCheckpointFiles, "scp")
+		assert.Equal(t, []string{buckets[0].String()}, summary.MissingBuckets)
+		assert.Equal(t, 2, summary.MissingCount())
+		assert.Equal(t, 14, summary.CheckpointFiles["ledger"])
+		assert.Equal(t, 14, summary.CheckpointFiles["scp"])
+		assert.Equal(t, summary.ReferencedBuckets-1, summary.Buckets)
+	}
+}
+
 func (a *Archive) MustGetRootHAS() HistoryArchiveState {
 	has, e := a.GetRootHAS()
 	if e != nil {
@@ -0,0 +1,316 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const azureAPIVersion = "2019-12-12"
+
+func init() {
+	RegisterBackend("azure", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+		container, prefix := parts[0], ""
+		if len(parts) == 2 {
+			prefix = parts[1]
+		}
+		return makeAzureBackend(u.Host, container, prefix, opts)
+	})
+}
+
+// AzureArchiveBackend is an ArchiveBackend storing files as block blobs in an
+// Azure Blob Storage container. Archive URLs have the form
+// azure://account/container/prefix.
+//
+// Requests are signed with the account key (Shared Key authorization) if
+// one is provided, or authorized with a shared access signature. Public
+// containers can be read anonymously.
+type AzureArchiveBackend struct {
+	client    http.Client
+	account   string
+	endpoint  string
+	container string
+	prefix    string
+	key       []byte
+	sasToken  url.Values
+}
+
+type azureBlobList struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (b *AzureArchiveBackend) blobURL(pth string) string {
+	segments := strings.Split(path.Join(b.container, b.prefix, pth), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return b.endpoint + "/" + strings.Join(segments, "/")
+}
+
+func (b *AzureArchiveBackend) do(method, u string, body []byte, header http.Header) (*http.Response, error) {
+	var rdr io.Reader
+	if body != nil {
+		rdr = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u, rdr)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+
+	if b.sasToken != nil {
+		query := req.URL.Query()
+		for k, v := range b.sasToken {
+			query[k] = v
+		}
+		req.URL.RawQuery = query.Encode()
+	} else if b.key != nil {
+		req.Header.Set("Authorization", "SharedKey "+b.account+":"+b.sign(req))
+	}
+	return b.client.Do(req)
+}
+
+// sign computes the Shared Key signature of req, see
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (b *AzureArchiveBackend) sign(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var msHeaders []string
+	for k := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-ms-") {
+			msHeaders = append(msHeaders, k)
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var buf strings.Builder
+	for _, s := range []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		buf.WriteString(s)
+		buf.WriteByte('\n')
+	}
+	for _, k := range msHeaders {
+		buf.WriteString(k + ":" + strings.TrimSpace(req.Header.Get(k)) + "\n")
+	}
+
+	buf.WriteString("/" + b.account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for k := range query {
+		params = append(params, k)
+	}
+	sort.Strings(params)
+	for _, k := range params {
+		values := query[k]
+		sort.Strings(values)
+		buf.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(values, ","))
+	}
+
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(buf.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (b *AzureArchiveBackend) GetFile(pth string) (io.ReadCloser, error) {
+	resp, err := b.do("GET", b.blobURL(pth), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResp(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (b *AzureArchiveBackend) Head(pth string) (*http.Response, error) {
+	resp, err := b.do("HEAD", b.blobURL(pth), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func (b *AzureArchiveBackend) Exists(pth string) (bool, error) {
+	resp, err := b.Head(pth)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		return true, nil
+	} else if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	} else {
+		return false, errors.Errorf("Unkown status code=%d", resp.StatusCode)
+	}
+}
+
+func (b *AzureArchiveBackend) Size(pth string) (int64, error) {
+	resp, err := b.Head(pth)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		return resp.ContentLength, nil
+	} else if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	} else {
+		return 0, errors.Errorf("Unkown status code=%d", resp.StatusCode)
+	}
+}
+
+func (b *AzureArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("x-ms-blob-type", "BlockBlob")
+	resp, err := b.do("PUT", b.blobURL(pth), buf.Bytes(), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResp(resp)
+}
+
+func (b *AzureArchiveBackend) ListFiles(pth string) (chan string, chan error) {
+	ch := make(chan string)
+	errs := make(chan error)
+
+	query := url.Values{}
+	query.Set("restype", "container")
+	query.Set("comp", "list")
+	query.Set("prefix", path.Join(b.prefix, pth))
+	base := b.endpoint + "/" + url.PathEscape(b.container) + "?"
+
+	go func() {
+		defer close(errs)
+		defer close(ch)
+		for {
+			list, err := b.listPage(base + query.Encode())
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, blob := range list.Blobs {
+				ch <- blob.Name
+			}
+			if list.NextMarker == "" {
+				return
+			}
+			query.Set("marker", list.NextMarker)
+		}
+	}()
+	return ch, errs
+}
+
+func (b *AzureArchiveBackend) listPage(u string) (*azureBlobList, error) {
+	resp, err := b.do("GET", u, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = checkResp(resp); err != nil {
+		return nil, err
+	}
+	var list azureBlobList
+	if err = xml.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, errors.Wrap(err, "error decoding blob list")
+	}
+	return &list, nil
+}
+
+func (b *AzureArchiveBackend) CanListFiles() bool {
+	return true
+}
+
+func makeAzureBackend(account, container, prefix string, opts ConnectOptions) (ArchiveBackend, error) {
+	if account == "" {
+		return nil, errors.New("storage account name is empty")
+	}
+	if container == "" {
+		return nil, errors.New("container name is empty")
+	}
+
+	endpoint := opts.AzureEndpoint
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+
+	backend := &AzureArchiveBackend{
+		account:   account,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		container: container,
+		prefix:    prefix,
+	}
+
+	sasToken := opts.AzureSASToken
+	if sasToken == "" {
+		sasToken = os.Getenv("AZURE_STORAGE_SAS_TOKEN")
+	}
+	accountKey := opts.AzureAccountKey
+	if accountKey == "" {
+		accountKey = os.Getenv("AZURE_STORAGE_KEY")
+	}
+
+	if sasToken != "" {
+		query, err := url.ParseQuery(strings.TrimPrefix(sasToken, "?"))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SAS token")
+		}
+		backend.sasToken = query
+	} else if accountKey != "" {
+		key, err := base64.StdEncoding.DecodeString(accountKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid account key")
+		}
+		backend.key = key
+	}
+
+	return backend, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAzure is an in-memory implementation of the parts of the Azure Blob
+// Storage REST API used by AzureArchiveBackend. Like Azurite, it serves the
+// account in the first path segment.
+type fakeAzure struct {
+	mutex     sync.Mutex
+	account   string
+	container string
+	auth      func(r *http.Request) bool
+	pageSize  int
+	blobs     map[string][]byte
+}
+
+func newFakeAzure(account, container string, auth func(r *http.Request) bool) *fakeAzure {
+	return &fakeAzure{
+		account:   account,
+		container: container,
+		auth:      auth,
+		pageSize:  100,
+		blobs:     make(map[string][]byte),
+	}
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if r.Header.Get("x-ms-version") == "" || r.Header.Get("x-ms-date") == "" || !f.auth(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	container := "/" + f.account + "/" + f.container
+	query := r.URL.Query()
+	switch {
+	case r.Method == "GET" && r.URL.Path == container && query.Get("comp") == "list":
+		var names []string
+		for name := range f.blobs {
+			if strings.HasPrefix(name, query.Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		start, _ := strconv.Atoi(query.Get("marker"))
+		var list azureBlobList
+		for i := start; i < len(names) && i < start+f.pageSize; i++ {
+			list.Blobs = append(list.Blobs, struct {
+				Name string `xml:"Name"`
+			}{names[i]})
+		}
+		if start+f.pageSize < len(names) {
+			list.NextMarker = strconv.Itoa(start + f.pageSize)
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"EnumerationResults"`
+			azureBlobList
+		}{azureBlobList: list})
+	case strings.HasPrefix(r.URL.Path, container+"/"):
+		name := strings.TrimPrefix(r.URL.Path, container+"/")
+		switch r.Method {
+		case "PUT":
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil || r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f.blobs[name] = body
+			w.WriteHeader(http.StatusCreated)
+		case "GET", "HEAD":
+			body, ok := f.blobs[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			if r.Method == "GET" {
+				w.Write(body)
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestAzureBackendSASToken(t *testing.T) {
+	fake := newFakeAzure("devstoreaccount1", "archive", func(r *http.Request) bool {
+		return r.URL.Query().Get("sig") == "signature" && r.Header.Get("Authorization") == ""
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	arch, err := Connect("azure://devstoreaccount1/archive/some/prefix", ConnectOptions{
+		AzureEndpoint: server.URL + "/devstoreaccount1",
+		AzureSASToken: "?sv=2019-12-12&sig=signature",
+	})
+	require.NoError(t, err)
+	testRemoteBackend(t, arch)
+
+	fake.mutex.Lock()
+	for name := range fake.blobs {
+		assert.True(t, strings.HasPrefix(name, "some/prefix/"), name)
+	}
+	fake.mutex.Unlock()
+}
+
+func TestAzureBackendSharedKey(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("account key"))
+	var backend *AzureArchiveBackend
+	fake := newFakeAzure("devstoreaccount1", "archive", func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "SharedKey devstoreaccount1:"+backend.sign(r)
+	})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	arch, err := Connect("azure://devstoreaccount1/archive", ConnectOptions{
+		AzureEndpoint:   server.URL + "/devstoreaccount1/",
+		AzureAccountKey: key,
+	})
+	require.NoError(t, err)
+	backend = arch.backend.(*AzureArchiveBackend)
+	testRemoteBackend(t, arch)
+
+	anonymous, err := Connect("azure://devstoreaccount1/archive", ConnectOptions{
+		AzureEndpoint: server.URL + "/devstoreaccount1",
+	})
+	require.NoError(t, err)
+	_, err = anonymous.GetRootHAS()
+	assert.Error(t, err)
+}
+
+func TestAzureSign(t *testing.T) {
+	backend := &AzureArchiveBackend{account: "myaccount", key: []byte("secret")}
+	req, err := http.NewRequest("GET", "https://myaccount.blob.core.windows.net/archive?restype=container&comp=list&prefix=bucket", nil)
+	require.NoError(t, err)
+	req.Header.Set("x-ms-date", "Mon, 02 Dec 2019 10:00:00 GMT")
+	req.Header.Set("x-ms-version", azureAPIVersion)
+
+	signed := backend.sign(req)
+	assert.Equal(t, signed, backend.sign(req))
+
+	req.Header.Set("x-ms-date", "Mon, 02 Dec 2019 10:00:01 GMT")
+	assert.NotEqual(t, signed, backend.sign(req))
+}
+
+func TestAzureURLs(t *testing.T) {
+	for _, u := range []string{"azure:///archive", "azure://account", "azure://account/"} {
+		_, err := Connect(u, ConnectOptions{})
+		assert.Error(t, err, u)
+	}
+
+	arch, err := Connect("azure://account/archive/a/b", ConnectOptions{})
+	require.NoError(t, err)
+	backend := arch.backend.(*AzureArchiveBackend)
+	assert.Equal(t, "https://account.blob.core.windows.net/archive/a/b/bucket/00/bucket.xdr.gz",
+		backend.blobURL("bucket/00/bucket.xdr.gz"))
+}
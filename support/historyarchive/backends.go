@@ -0,0 +1,63 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// BackendFactory creates an ArchiveBackend for an archive URL. It's called by
+// Connect with the parsed URL of the archive, whose scheme is the one the
+// factory was registered for.
+type BackendFactory func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error)
+
+var (
+	backendsMutex sync.RWMutex
+	backends      = make(map[string]BackendFactory)
+)
+
+// RegisterBackend makes a backend available to Connect for archive URLs with
+// the given scheme. It is meant to be called from init functions and panics
+// if the factory is nil or the scheme is already registered.
+func RegisterBackend(scheme string, factory BackendFactory) {
+	backendsMutex.Lock()
+	defer backendsMutex.Unlock()
+	if factory == nil {
+		panic("historyarchive: RegisterBackend factory is nil")
+	}
+	if _, dup := backends[scheme]; dup {
+		panic("historyarchive: RegisterBackend called twice for scheme " + scheme)
+	}
+	backends[scheme] = factory
+}
+
+// unregisterBackend removes the backend registered for the scheme. It's used
+// in tests to leave the registry as they found it.
+func unregisterBackend(scheme string) {
+	backendsMutex.Lock()
+	defer backendsMutex.Unlock()
+	delete(backends, scheme)
+}
+
+// Schemes returns a sorted list of the URL schemes supported by Connect.
+func Schemes() []string {
+	backendsMutex.RLock()
+	defer backendsMutex.RUnlock()
+	schemes := make([]string, 0, len(backends))
+	for scheme := range backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func lookupBackend(scheme string) (BackendFactory, bool) {
+	backendsMutex.RLock()
+	defer backendsMutex.RUnlock()
+	factory, ok := backends[scheme]
+	return factory, ok
+}
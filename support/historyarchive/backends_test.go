@@ -0,0 +1,45 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterBackend(t *testing.T) {
+	var connected *url.URL
+	RegisterBackend("test-register", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		connected = u
+		return makeMockBackend(opts), nil
+	})
+	defer unregisterBackend("test-register")
+
+	arch, err := Connect("test-register://host/path", ConnectOptions{})
+	require.NoError(t, err)
+	assert.NotNil(t, arch.backend)
+	assert.Equal(t, "host", connected.Host)
+	assert.Equal(t, "/path", connected.Path)
+
+	assert.Panics(t, func() {
+		RegisterBackend("test-register", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+			return nil, nil
+		})
+	})
+	assert.Panics(t, func() {
+		RegisterBackend("test-register-nil", nil)
+	})
+
+	schemes := Schemes()
+	for _, scheme := range []string{"azure", "file", "gs", "http", "https", "mock", "s3", "test-register"} {
+		assert.Contains(t, schemes, scheme)
+	}
+
+	_, err = Connect("unknown://host/path", ConnectOptions{})
+	assert.EqualError(t, err, "unknown URL scheme: 'unknown'")
+}
@@ -0,0 +1,398 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"compress/gzip"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/files"
+)
+
+var bucketPathRegexp = regexp.MustCompile("^bucket" + hexPrefixPat + "bucket-([0-9a-f]{64})\\.xdr\\.gz$")
+
+// CachingArchiveBackend is an ArchiveBackend keeping a local on-disk copy of
+// the files fetched from another (usually remote) backend. Only immutable
+// files are cached: buckets and checkpoint files. The root HAS is always
+// fetched from the upstream backend.
+//
+// Buckets are content-addressed, so their hashes are verified both when they
+// are downloaded and every time they are read from the cache. A cached bucket
+// that doesn't match its hash is evicted and an error is returned by Read at
+// the end of the stream.
+//
+// When the total size of the cached files exceeds the size limit the least
+// recently used files are evicted.
+type CachingArchiveBackend struct {
+	upstream ArchiveBackend
+	dir      string
+	maxSize  int64
+
+	mutex   sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	path string
+	size int64
+}
+
+// MakeCachingBackend returns a CachingArchiveBackend wrapping upstream and
+// storing files in dir. Files already present in dir are reused, so the cache
+// survives restarts. maxSize is the size limit of the cache in bytes, 0 means
+// no limit.
+func MakeCachingBackend(upstream ArchiveBackend, dir string, maxSize int64) (*CachingArchiveBackend, error) {
+	if maxSize < 0 {
+		return nil, errors.New("cache size cannot be negative")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "error creating cache directory")
+	}
+
+	b := &CachingArchiveBackend{
+		upstream: upstream,
+		dir:      dir,
+		maxSize:  maxSize,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+
+	if err := b.loadIndex(); err != nil {
+		return nil, errors.Wrap(err, "error loading cache index")
+	}
+
+	return b, nil
+}
+
+// archiveCacheDir returns the subdirectory of dir where files of the archive
+// at archiveURL are cached. Files in different archives have the same paths,
+// so sharing a directory would mix them up.
+func archiveCacheDir(dir, archiveURL string) string {
+	sum := sha256.Sum256([]byte(archiveURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8]))
+}
+
+// loadIndex rebuilds the LRU list from the files in the cache directory,
+// using modification times as the last access time.
+func (b *CachingArchiveBackend) loadIndex() error {
+	type file struct {
+		path string
+		info os.FileInfo
+	}
+	var cached []file
+
+	err := filepath.Walk(b.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(p, files.TempSuffix) {
+			// Leftover of an interrupted download.
+			return os.Remove(p)
+		}
+		rel, err := filepath.Rel(b.dir, p)
+		if err != nil {
+			return err
+		}
+		cached = append(cached, file{filepath.ToSlash(rel), info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].info.ModTime().Before(cached[j].info.ModTime())
+	})
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, f := range cached {
+		b.addLocked(f.path, f.info.Size())
+	}
+	b.evictLocked()
+	return nil
+}
+
+// CacheSize returns the total size of the cached files.
+func (b *CachingArchiveBackend) CacheSize() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.size
+}
+
+func (b *CachingArchiveBackend) GetFile(pth string) (io.ReadCloser, error) {
+	if !isCacheable(pth) {
+		return b.upstream.GetFile(pth)
+	}
+
+	if _, ok := b.touch(pth); ok {
+		local := b.localPath(pth)
+		f, err := os.Open(local)
+		if err == nil {
+			// Persist the access time so the LRU order survives restarts.
+			now := time.Now()
+			os.Chtimes(local, now, now)
+			return b.verifying(pth, f), nil
+		}
+		// The file was removed behind our back, download it again.
+		b.remove(pth)
+	}
+
+	return b.fetch(pth)
+}
+
+func (b *CachingArchiveBackend) Exists(pth string) (bool, error) {
+	if _, ok := b.lookup(pth); ok {
+		return true, nil
+	}
+	return b.upstream.Exists(pth)
+}
+
+func (b *CachingArchiveBackend) Size(pth string) (int64, error) {
+	if size, ok := b.lookup(pth); ok {
+		return size, nil
+	}
+	return b.upstream.Size(pth)
+}
+
+func (b *CachingArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
+	b.remove(pth)
+	return b.upstream.PutFile(pth, in)
+}
+
+func (b *CachingArchiveBackend) ListFiles(pth string) (chan string, chan error) {
+	return b.upstream.ListFiles(pth)
+}
+
+func (b *CachingArchiveBackend) CanListFiles() bool {
+	return b.upstream.CanListFiles()
+}
+
+// fetch downloads pth from the upstream backend into the cache and returns a
+// reader of the cached copy.
+func (b *CachingArchiveBackend) fetch(pth string) (io.ReadCloser, error) {
+	rdr, err := b.upstream.GetFile(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	local := b.localPath(pth)
+	if err = os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		rdr.Close()
+		return nil, err
+	}
+
+	var size int64
+	err = files.WriteAtomic(local, 0600, func(w io.Writer) error {
+		var err error
+		size, err = io.Copy(w, b.verifying(pth, rdr))
+		return err
+	})
+	rdr.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error caching %s", pth)
+	}
+
+	// Open before updating the index: if the file is evicted right away the
+	// open descriptor is still valid.
+	f, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	b.addLocked(pth, size)
+	b.evictLocked()
+	b.mutex.Unlock()
+
+	return f, nil
+}
+
+// verifying wraps rdr so that the bucket hash is checked at the end of the
+// stream when pth is a bucket path.
+func (b *CachingArchiveBackend) verifying(pth string, rdr io.ReadCloser) io.ReadCloser {
+	m := bucketPathRegexp.FindStringSubmatch(pth)
+	if m == nil {
+		return rdr
+	}
+
+	expected := MustDecodeHash(m[1])
+	return newHashVerifyingReader(rdr, expected, func() {
+		b.remove(pth)
+	})
+}
+
+func (b *CachingArchiveBackend) localPath(pth string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(path.Clean(pth)))
+}
+
+// lookup returns the size of pth if it's cached.
+func (b *CachingArchiveBackend) lookup(pth string) (int64, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	elem, ok := b.entries[pth]
+	if !ok {
+		return 0, false
+	}
+	return elem.Value.(*cacheEntry).size, true
+}
+
+// touch marks pth as the most recently used file.
+func (b *CachingArchiveBackend) touch(pth string) (int64, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	elem, ok := b.entries[pth]
+	if !ok {
+		return 0, false
+	}
+	b.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).size, true
+}
+
+func (b *CachingArchiveBackend) remove(pth string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.removeLocked(pth)
+}
+
+func (b *CachingArchiveBackend) addLocked(pth string, size int64) {
+	if elem, ok := b.entries[pth]; ok {
+		entry := elem.Value.(*cacheEntry)
+		b.size += size - entry.size
+		entry.size = size
+		b.lru.MoveToFront(elem)
+		return
+	}
+
+	b.entries[pth] = b.lru.PushFront(&cacheEntry{path: pth, size: size})
+	b.size += size
+}
+
+func (b *CachingArchiveBackend) removeLocked(pth string) {
+	elem, ok := b.entries[pth]
+	if !ok {
+		return
+	}
+	entry := b.lru.Remove(elem).(*cacheEntry)
+	delete(b.entries, pth)
+	b.size -= entry.size
+	os.Remove(b.localPath(pth))
+}
+
+func (b *CachingArchiveBackend) evictLocked() {
+	if b.maxSize == 0 {
+		return
+	}
+
+	for b.size > b.maxSize {
+		oldest := b.lru.Back()
+		if oldest == nil {
+			return
+		}
+		b.removeLocked(oldest.Value.(*cacheEntry).path)
+	}
+}
+
+// isCacheable returns true for immutable archive files.
+func isCacheable(pth string) bool {
+	if strings.HasPrefix(pth, "bucket/") {
+		return true
+	}
+	for _, cat := range Categories() {
+		if strings.HasPrefix(pth, cat+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hashVerifyingReader passes through a gzipped bucket while computing the
+// hash of its uncompressed contents. Read returns an error instead of io.EOF
+// if the hash doesn't match.
+type hashVerifyingReader struct {
+	rdr        io.ReadCloser
+	pipe       *io.PipeWriter
+	result     chan error
+	onMismatch func()
+	done       bool
+}
+
+func newHashVerifyingReader(rdr io.ReadCloser, expected Hash, onMismatch func()) *hashVerifyingReader {
+	pr, pw := io.Pipe()
+	result := make(chan error, 1)
+
+	go func() {
+		// Always drain the pipe so writes never block.
+		defer io.Copy(ioutil.Discard, pr)
+
+		gz, err := gzip.NewReader(pr)
+		if err != nil {
+			result <- err
+			return
+		}
+		hsh := sha256.New()
+		if _, err = io.Copy(hsh, gz); err != nil {
+			result <- err
+			return
+		}
+		result <- checkBucketHash(hsh, expected)
+	}()
+
+	return &hashVerifyingReader{
+		rdr:        rdr,
+		pipe:       pw,
+		result:     result,
+		onMismatch: onMismatch,
+	}
+}
+
+func (r *hashVerifyingReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+
+	n, err := r.rdr.Read(p)
+	if n > 0 {
+		r.pipe.Write(p[:n])
+	}
+
+	if err == io.EOF {
+		r.done = true
+		r.pipe.Close()
+		if verifyErr := <-r.result; verifyErr != nil {
+			r.onMismatch()
+			return n, fmt.Errorf("bucket verification failed: %v", verifyErr)
+		}
+	}
+
+	return n, err
+}
+
+func (r *hashVerifyingReader) Close() error {
+	if !r.done {
+		r.pipe.CloseWithError(errors.New("reader closed"))
+	}
+	return r.rdr.Close()
+}
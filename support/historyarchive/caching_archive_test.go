@@ -0,0 +1,196 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/support/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingBackend struct {
+	ArchiveBackend
+	gets map[string]int
+}
+
+func (b *countingBackend) GetFile(pth string) (io.ReadCloser, error) {
+	b.gets[pth]++
+	return b.ArchiveBackend.GetFile(pth)
+}
+
+func putGzBucket(t *testing.T, backend ArchiveBackend, size int) (Hash, []byte) {
+	raw := make([]byte, size)
+	_, err := rand.Read(raw)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write(raw)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	h := Hash(sha256.Sum256(raw))
+	require.NoError(t, backend.PutFile(BucketPath(h), ioutil.NopCloser(bytes.NewReader(buf.Bytes()))))
+	return h, buf.Bytes()
+}
+
+func readAll(backend ArchiveBackend, pth string) ([]byte, error) {
+	rdr, err := backend.GetFile(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+	return ioutil.ReadAll(rdr)
+}
+
+func makeTestCache(t *testing.T, maxSize int64) (*CachingArchiveBackend, *countingBackend, string) {
+	dir, err := ioutil.TempDir("", "archive-cache")
+	require.NoError(t, err)
+
+	upstream := &countingBackend{makeMockBackend(ConnectOptions{}), map[string]int{}}
+	cache, err := MakeCachingBackend(upstream, dir, maxSize)
+	require.NoError(t, err)
+	return cache, upstream, dir
+}
+
+func TestCachingBackendCachesImmutableFiles(t *testing.T) {
+	cache, upstream, dir := makeTestCache(t, 0)
+	defer os.RemoveAll(dir)
+
+	h, contents := putGzBucket(t, upstream, 1024)
+	ledgerPath := CategoryCheckpointPath("ledger", 63)
+	require.NoError(t, upstream.PutFile(ledgerPath, ioutil.NopCloser(bytes.NewReader([]byte("ledger")))))
+	require.NoError(t, upstream.PutFile(rootHASPath, ioutil.NopCloser(bytes.NewReader([]byte("{}")))))
+
+	for i := 0; i < 3; i++ {
+		read, err := readAll(cache, BucketPath(h))
+		require.NoError(t, err)
+		assert.Equal(t, contents, read)
+
+		read, err = readAll(cache, ledgerPath)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ledger"), read)
+
+		_, err = readAll(cache, rootHASPath)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, upstream.gets[BucketPath(h)])
+	assert.Equal(t, 1, upstream.gets[ledgerPath])
+	assert.Equal(t, 3, upstream.gets[rootHASPath])
+	assert.Equal(t, int64(len(contents)+len("ledger")), cache.CacheSize())
+
+	size, err := cache.Size(BucketPath(h))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), size)
+
+	// The cache is reused after a restart.
+	restarted, err := MakeCachingBackend(upstream, dir, 0)
+	require.NoError(t, err)
+	_, err = readAll(restarted, BucketPath(h))
+	require.NoError(t, err)
+	assert.Equal(t, 1, upstream.gets[BucketPath(h)])
+}
+
+func TestCachingBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, upstream, dir := makeTestCache(t, 0)
+	defer os.RemoveAll(dir)
+
+	h1, c1 := putGzBucket(t, upstream, 1024)
+	h2, c2 := putGzBucket(t, upstream, 1024)
+	h3, c3 := putGzBucket(t, upstream, 1024)
+	cache.maxSize = int64(len(c1) + len(c2) + len(c3) - 1)
+
+	for _, h := range []Hash{h1, h2, h1, h3} {
+		_, err := readAll(cache, BucketPath(h))
+		require.NoError(t, err)
+	}
+
+	// h2 was the least recently used bucket.
+	_, cached := cache.lookup(BucketPath(h2))
+	assert.False(t, cached)
+	_, err := os.Stat(cache.localPath(BucketPath(h2)))
+	assert.True(t, os.IsNotExist(err))
+
+	_, cached = cache.lookup(BucketPath(h1))
+	assert.True(t, cached)
+	_, cached = cache.lookup(BucketPath(h3))
+	assert.True(t, cached)
+	assert.True(t, cache.CacheSize() <= cache.maxSize)
+}
+
+func TestCachingBackendVerifiesBuckets(t *testing.T) {
+	cache, upstream, dir := makeTestCache(t, 0)
+	defer os.RemoveAll(dir)
+
+	h, contents := putGzBucket(t, upstream, 1024)
+	_, err := readAll(cache, BucketPath(h))
+	require.NoError(t, err)
+
+	// Corrupt the cached copy.
+	other, _ := putGzBucket(t, upstream, 1024)
+	otherContents, err := readAll(upstream, BucketPath(other))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(cache.localPath(BucketPath(h)), otherContents, 0644))
+
+	_, err = readAll(cache, BucketPath(h))
+	assert.Error(t, err)
+	_, cached := cache.lookup(BucketPath(h))
+	assert.False(t, cached)
+
+	// The next read downloads the bucket again.
+	read, err := readAll(cache, BucketPath(h))
+	require.NoError(t, err)
+	assert.Equal(t, contents, read)
+	assert.Equal(t, 2, upstream.gets[BucketPath(h)])
+
+	// Corrupted upstream buckets are not cached.
+	require.NoError(t, upstream.PutFile(BucketPath(h), ioutil.NopCloser(bytes.NewReader(otherContents))))
+	require.NoError(t, cache.PutFile(BucketPath(h), ioutil.NopCloser(bytes.NewReader(otherContents))))
+	_, err = readAll(cache, BucketPath(h))
+	assert.Error(t, err)
+	_, cached = cache.lookup(BucketPath(h))
+	assert.False(t, cached)
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(cache.localPath(BucketPath(h))), "*"+files.TempSuffix))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestConnectWithCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	arch, err := Connect("mock://test", ConnectOptions{CacheDir: dir, CacheSize: 1 << 20})
+	require.NoError(t, err)
+	cache, ok := arch.backend.(*CachingArchiveBackend)
+	require.True(t, ok)
+	assert.Equal(t, dir, filepath.Dir(cache.dir))
+
+	// Every archive has its own cache directory
+	other, err := Connect("mock://other", ConnectOptions{CacheDir: dir})
+	require.NoError(t, err)
+	otherCache, ok := other.backend.(*CachingArchiveBackend)
+	require.True(t, ok)
+	assert.NotEqual(t, cache.dir, otherCache.dir)
+
+	h, _ := putGzBucket(t, arch.backend, 100)
+	_, err = readAll(arch.backend, BucketPath(h))
+	require.NoError(t, err)
+	exists, err := other.backend.Exists(BucketPath(h))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
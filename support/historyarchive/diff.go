@@ -0,0 +1,229 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// Kinds of DiffEntry.
+const (
+	// DiffMissing is a file present in the source archive but not in the
+	// destination.
+	DiffMissing = "missing"
+	// DiffExtra is a file present in the destination archive but not in the
+	// source.
+	DiffExtra = "extra"
+	// DiffMismatch is a file present in both archives with different
+	// contents.
+	DiffMismatch = "mismatch"
+	// DiffError is a file that couldn't be read from one of the archives.
+	DiffError = "error"
+)
+
+// DiffEntry is a single difference found by Diff.
+type DiffEntry struct {
+	Kind       string `json:"kind"`
+	Path       string `json:"path"`
+	Checkpoint uint32 `json:"checkpoint,omitempty"`
+	SrcHash    string `json:"src_hash,omitempty"`
+	DstHash    string `json:"dst_hash,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// DiffReport is the result of Diff. It's meant to be serialized to JSON.
+type DiffReport struct {
+	Low         uint32      `json:"low"`
+	High        uint32      `json:"high"`
+	Checkpoints int         `json:"checkpoints"`
+	Files       int         `json:"files"`
+	Buckets     int         `json:"buckets"`
+	Differences []DiffEntry `json:"differences"`
+}
+
+// OK returns true if the archives are identical over the compared range.
+func (r *DiffReport) OK() bool {
+	return len(r.Differences) == 0
+}
+
+// Diff compares the checkpoint files of src and dst over opts.Range, and the
+// buckets referenced by either archive in that range. Files present in both
+// archives are compared by the hash of their uncompressed contents, so
+// archives written with different gzip settings still compare equal. HAS
+// files are compared by their decoded contents.
+//
+// An error is returned only if the root HAS of the source archive can't be
+// read; every other problem is reported as a DiffEntry.
+func Diff(src *Archive, dst *Archive, opts *CommandOptions) (*DiffReport, error) {
+	srcState, err := src.GetRootHAS()
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting source root HAS")
+	}
+	high := srcState.CurrentLedger
+	if dstState, err := dst.GetRootHAS(); err == nil && dstState.CurrentLedger > high {
+		high = dstState.CurrentLedger
+	}
+	rng := opts.Range.clamp(MakeRange(0, high))
+
+	var checkpoints []uint32
+	for chk := range rng.Checkpoints() {
+		checkpoints = append(checkpoints, chk)
+	}
+
+	report := &DiffReport{
+		Low:         rng.Low,
+		High:        rng.High,
+		Checkpoints: len(checkpoints),
+		Differences: []DiffEntry{},
+	}
+
+	var mutex sync.Mutex
+	buckets := make(map[Hash]bool)
+	var paths []string
+	for _, chk := range checkpoints {
+		for _, cat := range Categories() {
+			paths = append(paths, CategoryCheckpointPath(cat, chk))
+		}
+	}
+
+	diffPaths := func(paths []string, chks []uint32) {
+		results := make([]*DiffEntry, len(paths))
+		parallel(len(paths), opts.Concurrency, func(i int) {
+			results[i] = diffPath(src, dst, paths[i], func(has HistoryArchiveState) {
+				hashes, err := has.Buckets()
+				if err != nil {
+					return
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				for _, h := range hashes {
+					buckets[h] = true
+				}
+			})
+		})
+		for i, entry := range results {
+			if entry == nil {
+				continue
+			}
+			if chks != nil {
+				entry.Checkpoint = chks[i/len(Categories())]
+			}
+			report.Differences = append(report.Differences, *entry)
+		}
+	}
+
+	diffPaths(paths, checkpoints)
+	report.Files = len(paths)
+
+	bucketPaths := make([]string, 0, len(buckets))
+	for h := range buckets {
+		bucketPaths = append(bucketPaths, BucketPath(h))
+	}
+	sort.Strings(bucketPaths)
+	diffPaths(bucketPaths, nil)
+	report.Buckets = len(bucketPaths)
+
+	return report, nil
+}
+
+// diffPath compares pth in both archives and returns the difference, if any.
+// noteHAS is called with every HAS file read.
+func diffPath(src *Archive, dst *Archive, pth string, noteHAS func(HistoryArchiveState)) *DiffEntry {
+	srcExists, err := src.backend.Exists(pth)
+	if err != nil {
+		return &DiffEntry{Kind: DiffError, Path: pth, Message: "source: " + err.Error()}
+	}
+	dstExists, err := dst.backend.Exists(pth)
+	if err != nil {
+		return &DiffEntry{Kind: DiffError, Path: pth, Message: "destination: " + err.Error()}
+	}
+
+	switch {
+	case !srcExists && !dstExists:
+		return nil
+	case !dstExists:
+		noteOneSidedHAS(src, pth, noteHAS)
+		return &DiffEntry{Kind: DiffMissing, Path: pth}
+	case !srcExists:
+		noteOneSidedHAS(dst, pth, noteHAS)
+		return &DiffEntry{Kind: DiffExtra, Path: pth}
+	}
+
+	srcHash, err := hashPathContents(src, pth, noteHAS)
+	if err != nil {
+		return &DiffEntry{Kind: DiffError, Path: pth, Message: "source: " + err.Error()}
+	}
+	dstHash, err := hashPathContents(dst, pth, noteHAS)
+	if err != nil {
+		return &DiffEntry{Kind: DiffError, Path: pth, Message: "destination: " + err.Error()}
+	}
+	if srcHash != dstHash {
+		return &DiffEntry{
+			Kind:    DiffMismatch,
+			Path:    pth,
+			SrcHash: srcHash.String(),
+			DstHash: dstHash.String(),
+		}
+	}
+	return nil
+}
+
+// noteOneSidedHAS passes the HAS at pth to noteHAS so that the buckets it
+// references are compared even if the HAS is missing from the other archive.
+func noteOneSidedHAS(arch *Archive, pth string, noteHAS func(HistoryArchiveState)) {
+	if !strings.HasSuffix(pth, ".json") {
+		return
+	}
+	if has, err := arch.GetPathHAS(pth); err == nil {
+		noteHAS(has)
+	}
+}
+
+// hashPathContents returns the hash of the uncompressed contents of pth. HAS
+// files are decoded, passed to noteHAS and hashed in a canonical encoding.
+func hashPathContents(arch *Archive, pth string, noteHAS func(HistoryArchiveState)) (Hash, error) {
+	var h Hash
+	if strings.HasSuffix(pth, ".json") {
+		has, err := arch.GetPathHAS(pth)
+		if err != nil {
+			return h, err
+		}
+		noteHAS(has)
+		buf, err := json.Marshal(has)
+		if err != nil {
+			return h, err
+		}
+		return Hash(sha256.Sum256(buf)), nil
+	}
+
+	rdr, err := arch.backend.GetFile(pth)
+	if err != nil {
+		return h, err
+	}
+	defer rdr.Close()
+	var in io.Reader = rdr
+	if strings.HasSuffix(pth, ".gz") {
+		gz, err := gzip.NewReader(bufReadCloser(rdr))
+		if err != nil {
+			return h, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+	hsh := sha256.New()
+	if _, err := io.Copy(hsh, in); err != nil {
+		return h, err
+	}
+	copy(h[:], hsh.Sum([]byte{}))
+	return h, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	src := GetTestMockArchive()
+	dst := GetTestMockArchive()
+	checkpoints := writeTestChain(t, src, 255)
+	opts := &CommandOptions{Range: MakeRange(0, 255), Concurrency: 4}
+	require.NoError(t, Mirror(src, dst, opts))
+
+	report, err := Diff(src, dst, &CommandOptions{Range: MakeRange(0, 255), Concurrency: 4})
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Differences)
+	assert.Equal(t, 4, report.Checkpoints)
+	assert.Equal(t, 20, report.Files)
+	assert.Equal(t, 1, report.Buckets)
+
+	// Recompressing a file doesn't change its contents.
+	pth := CategoryCheckpointPath("ledger", 63)
+	rdr, err := dst.backend.GetFile(pth)
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(rdr)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	require.NoError(t, err)
+	_, err = w.Write(contents)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, dst.backend.PutFile(pth, ioutil.NopCloser(&buf)))
+
+	// Tamper with a ledger header of checkpoint 0x7f in the destination.
+	data := checkpoints[127]
+	data.LedgerHeaders[5].Header.BaseFee = 200
+	require.NoError(t, dst.WriteCheckpoint(127, data, &CommandOptions{Force: true}))
+
+	// Remove files from both sides.
+	bucket := BucketPath(MustDecodeHash(checkpoints[63].State.CurrentBuckets[0].Curr))
+	delete(dst.backend.(*MockArchiveBackend).files, bucket)
+	delete(dst.backend.(*MockArchiveBackend).files, CategoryCheckpointPath("results", 191))
+	delete(src.backend.(*MockArchiveBackend).files, CategoryCheckpointPath("scp", 255))
+
+	report, err = Diff(src, dst, &CommandOptions{Range: MakeRange(0, 255), Concurrency: 4})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.ElementsMatch(t, []DiffEntry{
+		{
+			Kind:       DiffMismatch,
+			Path:       CategoryCheckpointPath("ledger", 127),
+			Checkpoint: 127,
+			SrcHash:    report.Differences[0].SrcHash,
+			DstHash:    report.Differences[0].DstHash,
+		},
+		{Kind: DiffMissing, Path: CategoryCheckpointPath("results", 191), Checkpoint: 191},
+		{Kind: DiffExtra, Path: CategoryCheckpointPath("scp", 255), Checkpoint: 255},
+		{Kind: DiffMissing, Path: bucket},
+	}, report.Differences)
+	assert.NotEqual(t, report.Differences[0].SrcHash, report.Differences[0].DstHash)
+
+	// Only the requested range is compared.
+	report, err = Diff(src, dst, &CommandOptions{Range: MakeRange(191, 255)})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Checkpoints)
+	assert.Len(t, report.Differences, 3)
+}
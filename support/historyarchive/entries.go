@@ -0,0 +1,135 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"io"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// The methods in this file read the entries of checkpoint files. They don't
+// touch the scan state of the Archive and are safe to call from multiple
+// goroutines. Cancelling ctx stops reading before the next entry or the next
+// read from the backend.
+
+// contextReader fails reads once its context is done.
+type contextReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// forEachCheckpointEntry decodes all the entries of the cat file of checkpoint
+// chk into values returned by newEntry and passes them to handle. It stops at
+// the first error returned by handle.
+func (a *Archive) forEachCheckpointEntry(ctx context.Context, cat string, chk uint32,
+	newEntry func() interface{}, handle func(entry interface{}) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	pth := CategoryCheckpointPath(cat, chk)
+	in, err := a.backend.GetFile(pth)
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", pth)
+	}
+	rdr, err := NewXdrGzStream(contextReader{ctx, in})
+	if err != nil {
+		return errors.Wrapf(err, "error opening %s", pth)
+	}
+	defer rdr.Close()
+
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		entry := newEntry()
+		if err = rdr.ReadOne(entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return errors.Wrapf(err, "error reading %s", pth)
+		}
+		if err = handle(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// ForEachLedgerHeaderHistoryEntry calls fn with every ledger header of
+// checkpoint chk, in order, and stops at the first error it returns.
+func (a *Archive) ForEachLedgerHeaderHistoryEntry(ctx context.Context, chk uint32,
+	fn func(entry *xdr.LedgerHeaderHistoryEntry) error) error {
+	return a.forEachCheckpointEntry(ctx, "ledger", chk,
+		func() interface{} { return &xdr.LedgerHeaderHistoryEntry{} },
+		func(entry interface{}) error { return fn(entry.(*xdr.LedgerHeaderHistoryEntry)) })
+}
+
+// ForEachTransactionHistoryEntry calls fn with every transaction set of
+// checkpoint chk, in order, and stops at the first error it returns.
+func (a *Archive) ForEachTransactionHistoryEntry(ctx context.Context, chk uint32,
+	fn func(entry *xdr.TransactionHistoryEntry) error) error {
+	return a.forEachCheckpointEntry(ctx, "transactions", chk,
+		func() interface{} { return &xdr.TransactionHistoryEntry{} },
+		func(entry interface{}) error { return fn(entry.(*xdr.TransactionHistoryEntry)) })
+}
+
+// ForEachTransactionHistoryResultEntry calls fn with every transaction result
+// set of checkpoint chk, in order, and stops at the first error it returns.
+func (a *Archive) ForEachTransactionHistoryResultEntry(ctx context.Context, chk uint32,
+	fn func(entry *xdr.TransactionHistoryResultEntry) error) error {
+	return a.forEachCheckpointEntry(ctx, "results", chk,
+		func() interface{} { return &xdr.TransactionHistoryResultEntry{} },
+		func(entry interface{}) error { return fn(entry.(*xdr.TransactionHistoryResultEntry)) })
+}
+
+// GetLedgerHeaderHistoryEntries returns the ledger headers of checkpoint chk.
+func (a *Archive) GetLedgerHeaderHistoryEntries(ctx context.Context, chk uint32) ([]xdr.LedgerHeaderHistoryEntry, error) {
+	var entries []xdr.LedgerHeaderHistoryEntry
+	err := a.ForEachLedgerHeaderHistoryEntry(ctx, chk, func(entry *xdr.LedgerHeaderHistoryEntry) error {
+		entries = append(entries, *entry)
+		return nil
+	})
+	return entries, err
+}
+
+// GetTransactionHistoryEntries returns the transaction sets of checkpoint
+// chk. Ledgers with empty transaction sets have no entry.
+func (a *Archive) GetTransactionHistoryEntries(ctx context.Context, chk uint32) ([]xdr.TransactionHistoryEntry, error) {
+	var entries []xdr.TransactionHistoryEntry
+	err := a.ForEachTransactionHistoryEntry(ctx, chk, func(entry *xdr.TransactionHistoryEntry) error {
+		entries = append(entries, *entry)
+		return nil
+	})
+	return entries, err
+}
+
+// GetTransactionHistoryResultEntries returns the transaction result sets of
+// checkpoint chk. Ledgers with no transactions have no entry.
+func (a *Archive) GetTransactionHistoryResultEntries(ctx context.Context, chk uint32) ([]xdr.TransactionHistoryResultEntry, error) {
+	var entries []xdr.TransactionHistoryResultEntry
+	err := a.ForEachTransactionHistoryResultEntry(ctx, chk, func(entry *xdr.TransactionHistoryResultEntry) error {
+		entries = append(entries, *entry)
+		return nil
+	})
+	return entries, err
+}
+
+// GetBucketSize returns the size of the compressed bucket file with the given
+// hash.
+func (a *Archive) GetBucketSize(hash Hash) (int64, error) {
+	return a.backend.Size(a.GetBucketPathForHash(hash))
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCheckpointEntries(t *testing.T) {
+	arch := GetTestMockArchive()
+	checkpoints := writeTestChain(t, arch, 255)
+	ctx := context.Background()
+
+	// Checkpoints can be read concurrently.
+	var wg sync.WaitGroup
+	for chk, data := range checkpoints {
+		wg.Add(1)
+		go func(chk uint32, data CheckpointData) {
+			defer wg.Done()
+			headers, err := arch.GetLedgerHeaderHistoryEntries(ctx, chk)
+			assert.NoError(t, err)
+			assert.Equal(t, data.LedgerHeaders, headers)
+
+			txs, err := arch.GetTransactionHistoryEntries(ctx, chk)
+			assert.NoError(t, err)
+			assert.Equal(t, data.Transactions, txs)
+
+			// Compare the encoded results, empty operation results decode
+			// to nil slices.
+			results, err := arch.GetTransactionHistoryResultEntries(ctx, chk)
+			assert.NoError(t, err)
+			expected, err := HashXdr(&data.Results)
+			assert.NoError(t, err)
+			actual, err := HashXdr(&results)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}(chk, data)
+	}
+	wg.Wait()
+
+	size, err := arch.GetBucketSize(MustDecodeHash(checkpoints[63].State.CurrentBuckets[0].Curr))
+	require.NoError(t, err)
+	assert.True(t, size > 0)
+}
+
+func TestForEachCheckpointEntryErrors(t *testing.T) {
+	arch := GetTestMockArchive()
+	writeTestChain(t, arch, 127)
+
+	// Errors returned by the callback stop the iteration.
+	stop := errors.New("stop")
+	count := 0
+	err := arch.ForEachLedgerHeaderHistoryEntry(context.Background(), 127,
+		func(entry *xdr.LedgerHeaderHistoryEntry) error {
+			count++
+			if count == 3 {
+				return stop
+			}
+			return nil
+		})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 3, count)
+
+	// Cancelling the context stops the iteration.
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	err = arch.ForEachLedgerHeaderHistoryEntry(ctx, 127,
+		func(entry *xdr.LedgerHeaderHistoryEntry) error {
+			count++
+			cancel()
+			return nil
+		})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, count)
+
+	_, err = arch.GetLedgerHeaderHistoryEntries(ctx, 63)
+	assert.Equal(t, context.Canceled, err)
+
+	// Missing files are errors rather than panics.
+	_, err = arch.GetTransactionHistoryEntries(context.Background(), 191)
+	assert.EqualError(t, err, "error opening transactions/00/00/00/transactions-000000bf.xdr.gz: no such file: transactions/00/00/00/transactions-000000bf.xdr.gz")
+}
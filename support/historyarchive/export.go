@@ -0,0 +1,382 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ExportFilter selects the transactions exported by Export. Empty fields
+// match every transaction. A transaction matches if it matches every
+// non-empty field, and a field matches if any of its values does.
+type ExportFilter struct {
+	// Accounts matches transactions with one of the accounts as the source
+	// of the transaction or of an operation, or as the destination, trustor
+	// or merge target of an operation.
+	Accounts []xdr.AccountId
+	// OperationTypes matches transactions with an operation of one of the
+	// types.
+	OperationTypes []xdr.OperationType
+	// Assets matches transactions with an operation sending, receiving,
+	// trading, trusting or authorizing one of the assets.
+	Assets []xdr.Asset
+}
+
+// Empty returns true if the filter matches every transaction.
+func (f *ExportFilter) Empty() bool {
+	return len(f.Accounts) == 0 && len(f.OperationTypes) == 0 && len(f.Assets) == 0
+}
+
+// Match returns true if tx matches the filter.
+func (f *ExportFilter) Match(tx *xdr.TransactionEnvelope) bool {
+	if len(f.Accounts) > 0 && !f.matchAccounts(tx) {
+		return false
+	}
+	if len(f.OperationTypes) > 0 && !f.matchOperationTypes(tx) {
+		return false
+	}
+	if len(f.Assets) > 0 && !f.matchAssets(tx) {
+		return false
+	}
+	return true
+}
+
+func (f *ExportFilter) matchAccount(account *xdr.AccountId) bool {
+	for _, a := range f.Accounts {
+		if account.Equals(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *ExportFilter) matchAccounts(tx *xdr.TransactionEnvelope) bool {
+	if f.matchAccount(&tx.Tx.SourceAccount) {
+		return true
+	}
+	for _, op := range tx.Tx.Operations {
+		accounts := []*xdr.AccountId{op.SourceAccount}
+		switch op.Body.Type {
+		case xdr.OperationTypeCreateAccount:
+			accounts = append(accounts, &op.Body.CreateAccountOp.Destination)
+		case xdr.OperationTypePayment:
+			accounts = append(accounts, &op.Body.PaymentOp.Destination)
+		case xdr.OperationTypePathPaymentStrictReceive:
+			accounts = append(accounts, &op.Body.PathPaymentStrictReceiveOp.Destination)
+		case xdr.OperationTypePathPaymentStrictSend:
+			accounts = append(accounts, &op.Body.PathPaymentStrictSendOp.Destination)
+		case xdr.OperationTypeAllowTrust:
+			accounts = append(accounts, &op.Body.AllowTrustOp.Trustor)
+		case xdr.OperationTypeAccountMerge:
+			accounts = append(accounts, op.Body.Destination)
+		}
+		for _, account := range accounts {
+			if account != nil && f.matchAccount(account) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *ExportFilter) matchOperationTypes(tx *xdr.TransactionEnvelope) bool {
+	for _, op := range tx.Tx.Operations {
+		for _, typ := range f.OperationTypes {
+			if op.Body.Type == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *ExportFilter) matchAssets(tx *xdr.TransactionEnvelope) bool {
+	for _, op := range tx.Tx.Operations {
+		var assets []xdr.Asset
+		switch op.Body.Type {
+		case xdr.OperationTypePayment:
+			assets = []xdr.Asset{op.Body.PaymentOp.Asset}
+		case xdr.OperationTypePathPaymentStrictReceive:
+			body := op.Body.PathPaymentStrictReceiveOp
+			assets = append([]xdr.Asset{body.SendAsset, body.DestAsset}, body.Path...)
+		case xdr.OperationTypePathPaymentStrictSend:
+			body := op.Body.PathPaymentStrictSendOp
+			assets = append([]xdr.Asset{body.SendAsset, body.DestAsset}, body.Path...)
+		case xdr.OperationTypeManageSellOffer:
+			assets = []xdr.Asset{op.Body.ManageSellOfferOp.Selling, op.Body.ManageSellOfferOp.Buying}
+		case xdr.OperationTypeManageBuyOffer:
+			assets = []xdr.Asset{op.Body.ManageBuyOfferOp.Selling, op.Body.ManageBuyOfferOp.Buying}
+		case xdr.OperationTypeCreatePassiveSellOffer:
+			assets = []xdr.Asset{op.Body.CreatePassiveSellOfferOp.Selling, op.Body.CreatePassiveSellOfferOp.Buying}
+		case xdr.OperationTypeChangeTrust:
+			assets = []xdr.Asset{op.Body.ChangeTrustOp.Line}
+		case xdr.OperationTypeAllowTrust:
+			// The issuer of the asset is the source of the operation.
+			issuer := tx.Tx.SourceAccount
+			if op.SourceAccount != nil {
+				issuer = *op.SourceAccount
+			}
+			assets = []xdr.Asset{op.Body.AllowTrustOp.Asset.ToAsset(issuer)}
+		}
+		for _, asset := range assets {
+			for _, a := range f.Assets {
+				if asset.Equals(a) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// Low and High are the first and last ledgers to export.
+	Low  uint32
+	High uint32
+	// NetworkPassphrase is used to hash transactions in order to match them
+	// with their results.
+	NetworkPassphrase string
+	Filter            ExportFilter
+}
+
+// ExportedLedger is a ledger and the transactions of it matching the
+// ExportFilter, in the order they were applied, with their results.
+type ExportedLedger struct {
+	Header       xdr.LedgerHeaderHistoryEntry
+	Transactions []xdr.TransactionEnvelope
+	Results      []xdr.TransactionResultPair
+}
+
+// ExportWriter writes the ledgers exported by Export.
+type ExportWriter interface {
+	WriteLedger(ledger *ExportedLedger) error
+}
+
+// ExportStats are statistics of an Export.
+type ExportStats struct {
+	Ledgers      int `json:"ledgers"`
+	Transactions int `json:"transactions"`
+}
+
+// Export reads the ledgers from opts.Low to opts.High from the archive and
+// writes them, with their transactions matching opts.Filter, to w. If the
+// filter isn't empty, only ledgers with matching transactions are written.
+func (a *Archive) Export(ctx context.Context, opts ExportOptions, w ExportWriter) (ExportStats, error) {
+	var stats ExportStats
+	if opts.High < opts.Low {
+		return stats, errors.Errorf("invalid ledger range [%d, %d]", opts.Low, opts.High)
+	}
+	if opts.NetworkPassphrase == "" {
+		return stats, errors.New("network passphrase is empty")
+	}
+
+	for chk := range MakeRange(opts.Low, opts.High).Checkpoints() {
+		headers, err := a.GetLedgerHeaderHistoryEntries(ctx, chk)
+		if err != nil {
+			return stats, err
+		}
+		txSets := make(map[uint32]*xdr.TransactionSet)
+		err = a.ForEachTransactionHistoryEntry(ctx, chk, func(entry *xdr.TransactionHistoryEntry) error {
+			txSets[uint32(entry.LedgerSeq)] = &entry.TxSet
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+		resultSets := make(map[uint32]*xdr.TransactionResultSet)
+		err = a.ForEachTransactionHistoryResultEntry(ctx, chk, func(entry *xdr.TransactionHistoryResultEntry) error {
+			resultSets[uint32(entry.LedgerSeq)] = &entry.TxResultSet
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+
+		for _, header := range headers {
+			seq := uint32(header.Header.LedgerSeq)
+			if seq < opts.Low || seq > opts.High {
+				continue
+			}
+			ledger, err := exportLedger(header, txSets[seq], resultSets[seq], &opts)
+			if err != nil {
+				return stats, errors.Wrapf(err, "error exporting ledger %d", seq)
+			}
+			if !opts.Filter.Empty() && len(ledger.Transactions) == 0 {
+				continue
+			}
+			if err = w.WriteLedger(ledger); err != nil {
+				return stats, err
+			}
+			stats.Ledgers++
+			stats.Transactions += len(ledger.Transactions)
+		}
+	}
+	return stats, nil
+}
+
+// exportLedger pairs the transactions of a ledger with their results, in the
+// order of the results, and keeps the ones matching the filter.
+func exportLedger(header xdr.LedgerHeaderHistoryEntry, txSet *xdr.TransactionSet,
+	resultSet *xdr.TransactionResultSet, opts *ExportOptions) (*ExportedLedger, error) {
+	ledger := &ExportedLedger{Header: header}
+	if txSet == nil || resultSet == nil {
+		return ledger, nil
+	}
+
+	txs := make(map[xdr.Hash]*xdr.TransactionEnvelope, len(txSet.Txs))
+	for i := range txSet.Txs {
+		h, err := network.HashTransaction(&txSet.Txs[i].Tx, opts.NetworkPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		txs[xdr.Hash(h)] = &txSet.Txs[i]
+	}
+	for _, result := range resultSet.Results {
+		tx, ok := txs[result.TransactionHash]
+		if !ok {
+			return nil, errors.Errorf("no transaction for result %s, wrong network passphrase?",
+				Hash(result.TransactionHash))
+		}
+		if !opts.Filter.Match(tx) {
+			continue
+		}
+		ledger.Transactions = append(ledger.Transactions, *tx)
+		ledger.Results = append(ledger.Results, result)
+	}
+	return ledger, nil
+}
+
+// jsonExportWriter writes newline-delimited JSON records, see
+// NewJSONExportWriter.
+type jsonExportWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONExportWriter returns an ExportWriter writing a newline-delimited JSON
+// record of type "ledger" for every ledger, followed by a record of type
+// "transaction" for every transaction of it. XDR values are encoded with
+// xdr.MarshalJSON.
+func NewJSONExportWriter(out io.Writer) ExportWriter {
+	return &jsonExportWriter{enc: json.NewEncoder(out)}
+}
+
+type jsonExportLedger struct {
+	Type      string          `json:"type"`
+	Ledger    uint32          `json:"ledger"`
+	CloseTime time.Time       `json:"close_time"`
+	Hash      string          `json:"hash"`
+	Header    json.RawMessage `json:"header"`
+}
+
+type jsonExportTransaction struct {
+	Type      string          `json:"type"`
+	Ledger    uint32          `json:"ledger"`
+	CloseTime time.Time       `json:"close_time"`
+	Hash      string          `json:"hash"`
+	Envelope  json.RawMessage `json:"envelope"`
+	Result    json.RawMessage `json:"result"`
+}
+
+func (w *jsonExportWriter) WriteLedger(ledger *ExportedLedger) error {
+	seq := uint32(ledger.Header.Header.LedgerSeq)
+	closeTime := time.Unix(int64(ledger.Header.Header.ScpValue.CloseTime), 0).UTC()
+	header, err := xdr.MarshalJSON(&ledger.Header)
+	if err != nil {
+		return err
+	}
+	err = w.enc.Encode(jsonExportLedger{
+		Type:      "ledger",
+		Ledger:    seq,
+		CloseTime: closeTime,
+		Hash:      Hash(ledger.Header.Hash).String(),
+		Header:    header,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range ledger.Transactions {
+		envelope, err := xdr.MarshalJSON(&ledger.Transactions[i])
+		if err != nil {
+			return err
+		}
+		result, err := xdr.MarshalJSON(&ledger.Results[i])
+		if err != nil {
+			return err
+		}
+		err = w.enc.Encode(jsonExportTransaction{
+			Type:      "transaction",
+			Ledger:    seq,
+			CloseTime: closeTime,
+			Hash:      Hash(ledger.Results[i].TransactionHash).String(),
+			Envelope:  envelope,
+			Result:    result,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// xdrExportWriter writes length-prefixed XDR, see NewXdrExportWriter.
+type xdrExportWriter struct {
+	ledgers      io.Writer
+	transactions io.Writer
+	results      io.Writer
+}
+
+// NewXdrExportWriter returns an ExportWriter writing length-prefixed XDR
+// streams in the format of the uncompressed ledger, transactions and results
+// checkpoint files: a LedgerHeaderHistoryEntry for every ledger to ledgers,
+// and for every ledger with transactions a TransactionHistoryEntry to
+// transactions and a TransactionHistoryResultEntry to results. Any of the
+// writers can be nil to skip it. Note that the exported transaction sets
+// don't hash to the txSetHash of the header if some transactions were
+// filtered out.
+func NewXdrExportWriter(ledgers io.Writer, transactions io.Writer, results io.Writer) ExportWriter {
+	return &xdrExportWriter{ledgers: ledgers, transactions: transactions, results: results}
+}
+
+func (w *xdrExportWriter) WriteLedger(ledger *ExportedLedger) error {
+	if w.ledgers != nil {
+		if err := WriteFramedXdr(w.ledgers, &ledger.Header); err != nil {
+			return err
+		}
+	}
+	if len(ledger.Transactions) == 0 {
+		return nil
+	}
+	seq := ledger.Header.Header.LedgerSeq
+	if w.transactions != nil {
+		err := WriteFramedXdr(w.transactions, &xdr.TransactionHistoryEntry{
+			LedgerSeq: seq,
+			TxSet: xdr.TransactionSet{
+				PreviousLedgerHash: ledger.Header.Header.PreviousLedgerHash,
+				Txs:                ledger.Transactions,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if w.results != nil {
+		err := WriteFramedXdr(w.results, &xdr.TransactionHistoryResultEntry{
+			LedgerSeq:   seq,
+			TxResultSet: xdr.TransactionResultSet{Results: ledger.Results},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
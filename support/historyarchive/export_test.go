@@ -0,0 +1,194 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectExportWriter struct {
+	ledgers []*ExportedLedger
+}
+
+func (w *collectExportWriter) WriteLedger(ledger *ExportedLedger) error {
+	w.ledgers = append(w.ledgers, ledger)
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	arch := GetTestMockArchive()
+	checkpoints := writeTestChain(t, arch, 191)
+	ctx := context.Background()
+	opts := ExportOptions{Low: 60, High: 130, NetworkPassphrase: network.TestNetworkPassphrase}
+
+	var w collectExportWriter
+	stats, err := arch.Export(ctx, opts, &w)
+	require.NoError(t, err)
+	assert.Equal(t, ExportStats{Ledgers: 71, Transactions: 2}, stats)
+	require.Len(t, w.ledgers, 71)
+	assert.Equal(t, xdr.Uint32(60), w.ledgers[0].Header.Header.LedgerSeq)
+	assert.Equal(t, xdr.Uint32(130), w.ledgers[70].Header.Header.LedgerSeq)
+	assert.Equal(t, checkpoints[127].LedgerHeaders[1], w.ledgers[5].Header)
+	assert.Equal(t, checkpoints[127].Transactions[0].TxSet.Txs, w.ledgers[5].Transactions)
+	require.Len(t, w.ledgers[5].Results, 1)
+	assert.Equal(t, checkpoints[127].Results[0].TxResultSet.Results[0].TransactionHash,
+		w.ledgers[5].Results[0].TransactionHash)
+
+	// Only ledgers with matching transactions are exported with a filter.
+	opts.Filter.Accounts = []xdr.AccountId{xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB")}
+	w = collectExportWriter{}
+	stats, err = arch.Export(ctx, opts, &w)
+	require.NoError(t, err)
+	assert.Equal(t, ExportStats{Ledgers: 2, Transactions: 2}, stats)
+	assert.Equal(t, xdr.Uint32(65), w.ledgers[0].Header.Header.LedgerSeq)
+	assert.Equal(t, xdr.Uint32(129), w.ledgers[1].Header.Header.LedgerSeq)
+
+	opts.Filter.OperationTypes = []xdr.OperationType{xdr.OperationTypePayment}
+	stats, err = arch.Export(ctx, opts, &collectExportWriter{})
+	require.NoError(t, err)
+	assert.Equal(t, ExportStats{}, stats)
+
+	// Results can't be matched with transactions hashed for another network.
+	opts.NetworkPassphrase = network.PublicNetworkPassphrase
+	_, err = arch.Export(ctx, opts, &collectExportWriter{})
+	assert.Contains(t, err.Error(), "error exporting ledger 65: no transaction for result")
+
+	opts.NetworkPassphrase = ""
+	_, err = arch.Export(ctx, opts, &collectExportWriter{})
+	assert.EqualError(t, err, "network passphrase is empty")
+}
+
+func TestExportWriters(t *testing.T) {
+	arch := GetTestMockArchive()
+	checkpoints := writeTestChain(t, arch, 127)
+	opts := ExportOptions{Low: 64, High: 66, NetworkPassphrase: network.TestNetworkPassphrase}
+
+	var buf bytes.Buffer
+	_, err := arch.Export(context.Background(), opts, NewJSONExportWriter(&buf))
+	require.NoError(t, err)
+	var types []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		types = append(types, record["type"].(string))
+		if record["type"] == "transaction" {
+			assert.Equal(t, float64(65), record["ledger"])
+			assert.Equal(t, Hash(checkpoints[127].Results[0].TxResultSet.Results[0].TransactionHash).String(),
+				record["hash"])
+			envelope, err := json.Marshal(record["envelope"])
+			require.NoError(t, err)
+			var tx xdr.TransactionEnvelope
+			require.NoError(t, xdr.UnmarshalJSON(envelope, &tx))
+			assert.Equal(t, checkpoints[127].Transactions[0].TxSet.Txs[0], tx)
+		}
+	}
+	assert.Equal(t, []string{"ledger", "ledger", "transaction", "ledger"}, types)
+
+	// The XDR streams are in the format of the checkpoint files.
+	var ledgers, transactions, results bytes.Buffer
+	_, err = arch.Export(context.Background(), opts, NewXdrExportWriter(&ledgers, &transactions, &results))
+	require.NoError(t, err)
+
+	stream := NewXdrStream(ioutil.NopCloser(&ledgers))
+	for _, expected := range checkpoints[127].LedgerHeaders[:3] {
+		var header xdr.LedgerHeaderHistoryEntry
+		require.NoError(t, stream.ReadOne(&header))
+		assert.Equal(t, expected, header)
+	}
+	assert.Equal(t, io.EOF, stream.ReadOne(&xdr.LedgerHeaderHistoryEntry{}))
+
+	var tx xdr.TransactionHistoryEntry
+	stream = NewXdrStream(ioutil.NopCloser(&transactions))
+	require.NoError(t, stream.ReadOne(&tx))
+	assert.Equal(t, checkpoints[127].Transactions[0], tx)
+	assert.Equal(t, io.EOF, stream.ReadOne(&tx))
+
+	var result xdr.TransactionHistoryResultEntry
+	stream = NewXdrStream(ioutil.NopCloser(&results))
+	require.NoError(t, stream.ReadOne(&result))
+	assert.Equal(t, checkpoints[127].Results[0].LedgerSeq, result.LedgerSeq)
+	assert.Equal(t, io.EOF, stream.ReadOne(&result))
+}
+
+func TestExportFilterMatch(t *testing.T) {
+	source := xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB")
+	destination := xdr.MustAddress("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H")
+	other := xdr.MustAddress("GCFXHS4GXL6BVUCXBWXGTITROWLVYXQKQLF4YH5O5JT3YZXCYPAFBJZB")
+	usd := xdr.MustNewCreditAsset("USD", source.Address())
+	eur := xdr.MustNewCreditAsset("EUR", source.Address())
+
+	tx := &xdr.TransactionEnvelope{
+		Tx: xdr.Transaction{
+			SourceAccount: source,
+			Operations: []xdr.Operation{
+				{
+					Body: xdr.OperationBody{
+						Type: xdr.OperationTypePayment,
+						PaymentOp: &xdr.PaymentOp{
+							Destination: destination,
+							Asset:       usd,
+							Amount:      10,
+						},
+					},
+				},
+				{
+					Body: xdr.OperationBody{
+						Type: xdr.OperationTypeAllowTrust,
+						AllowTrustOp: &xdr.AllowTrustOp{
+							Trustor: other,
+							Asset: xdr.AllowTrustOpAsset{
+								Type:       xdr.AssetTypeAssetTypeCreditAlphanum4,
+								AssetCode4: &xdr.AssetCode4{'E', 'U', 'R'},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, c := range []struct {
+		name    string
+		filter  ExportFilter
+		matches bool
+	}{
+		{"empty", ExportFilter{}, true},
+		{"source", ExportFilter{Accounts: []xdr.AccountId{source}}, true},
+		{"destination", ExportFilter{Accounts: []xdr.AccountId{destination}}, true},
+		{"trustor", ExportFilter{Accounts: []xdr.AccountId{other}}, true},
+		{"operation type", ExportFilter{OperationTypes: []xdr.OperationType{xdr.OperationTypeAllowTrust}}, true},
+		{"other operation type", ExportFilter{OperationTypes: []xdr.OperationType{xdr.OperationTypeCreateAccount}}, false},
+		{"asset", ExportFilter{Assets: []xdr.Asset{usd}}, true},
+		{"allowed asset", ExportFilter{Assets: []xdr.Asset{eur}}, true},
+		{"native", ExportFilter{Assets: []xdr.Asset{xdr.MustNewNativeAsset()}}, false},
+		{
+			"any of a kind",
+			ExportFilter{OperationTypes: []xdr.OperationType{xdr.OperationTypeCreateAccount, xdr.OperationTypePayment}},
+			true,
+		},
+		{
+			"all kinds",
+			ExportFilter{
+				Accounts:       []xdr.AccountId{destination},
+				OperationTypes: []xdr.OperationType{xdr.OperationTypeCreateAccount},
+			},
+			false,
+		},
+	} {
+		assert.Equal(t, c.matches, c.filter.Match(tx), c.name)
+	}
+}
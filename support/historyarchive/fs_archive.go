@@ -6,11 +6,18 @@ package historyarchive
 
 import (
 	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 )
 
+func init() {
+	RegisterBackend("file", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		return makeFsBackend(path.Join(u.Host, u.Path), opts), nil
+	})
+}
+
 type FsArchiveBackend struct {
 	prefix string
 }
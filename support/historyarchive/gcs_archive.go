@@ -0,0 +1,208 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const defaultGCSEndpoint = "https://storage.googleapis.com"
+
+func init() {
+	RegisterBackend("gs", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		return makeGCSBackend(u.Host, strings.TrimPrefix(u.Path, "/"), opts)
+	})
+}
+
+// GCSArchiveBackend is an ArchiveBackend storing files in a Google Cloud
+// Storage bucket, using the JSON API. Archive URLs have the form
+// gs://bucket/prefix.
+type GCSArchiveBackend struct {
+	client   http.Client
+	endpoint string
+	bucket   string
+	prefix   string
+	token    string
+}
+
+type gcsObject struct {
+	Name string `json:"name"`
+	Size string `json:"size"`
+}
+
+type gcsObjectList struct {
+	Items         []gcsObject `json:"items"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+func (b *GCSArchiveBackend) objectURL(pth string) string {
+	return b.endpoint + "/storage/v1/b/" + url.PathEscape(b.bucket) +
+		"/o/" + url.PathEscape(path.Join(b.prefix, pth))
+}
+
+func (b *GCSArchiveBackend) do(method, u string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	return b.client.Do(req)
+}
+
+func (b *GCSArchiveBackend) GetFile(pth string) (io.ReadCloser, error) {
+	resp, err := b.do("GET", b.objectURL(pth)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResp(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// stat returns the metadata of pth, or nil if it doesn't exist.
+func (b *GCSArchiveBackend) stat(pth string) (*gcsObject, error) {
+	resp, err := b.do("GET", b.objectURL(pth), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err = checkResp(resp); err != nil {
+		return nil, err
+	}
+	var obj gcsObject
+	if err = json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil, errors.Wrap(err, "error decoding object metadata")
+	}
+	return &obj, nil
+}
+
+func (b *GCSArchiveBackend) Exists(pth string) (bool, error) {
+	obj, err := b.stat(pth)
+	if err != nil {
+		return false, err
+	}
+	return obj != nil, nil
+}
+
+func (b *GCSArchiveBackend) Size(pth string) (int64, error) {
+	obj, err := b.stat(pth)
+	if err != nil || obj == nil {
+		return 0, err
+	}
+	return strconv.ParseInt(obj.Size, 10, 64)
+}
+
+func (b *GCSArchiveBackend) PutFile(pth string, in io.ReadCloser) error {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+
+	u := b.endpoint + "/upload/storage/v1/b/" + url.PathEscape(b.bucket) +
+		"/o?uploadType=media&name=" + url.QueryEscape(path.Join(b.prefix, pth))
+	resp, err := b.do("POST", u, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResp(resp)
+}
+
+func (b *GCSArchiveBackend) ListFiles(pth string) (chan string, chan error) {
+	ch := make(chan string)
+	errs := make(chan error)
+
+	query := url.Values{}
+	query.Set("prefix", path.Join(b.prefix, pth))
+	base := b.endpoint + "/storage/v1/b/" + url.PathEscape(b.bucket) + "/o?"
+
+	go func() {
+		defer close(errs)
+		defer close(ch)
+		for {
+			list, err := b.listPage(base + query.Encode())
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, obj := range list.Items {
+				ch <- obj.Name
+			}
+			if list.NextPageToken == "" {
+				return
+			}
+			query.Set("pageToken", list.NextPageToken)
+		}
+	}()
+	return ch, errs
+}
+
+func (b *GCSArchiveBackend) listPage(u string) (*gcsObjectList, error) {
+	resp, err := b.do("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = checkResp(resp); err != nil {
+		return nil, err
+	}
+	var list gcsObjectList
+	if err = json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, errors.Wrap(err, "error decoding object list")
+	}
+	return &list, nil
+}
+
+func (b *GCSArchiveBackend) CanListFiles() bool {
+	return true
+}
+
+func makeGCSBackend(bucket string, prefix string, opts ConnectOptions) (ArchiveBackend, error) {
+	if bucket == "" {
+		return nil, errors.New("bucket name is empty")
+	}
+
+	endpoint := opts.GCSEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("STORAGE_EMULATOR_HOST")
+	}
+	if endpoint == "" {
+		endpoint = defaultGCSEndpoint
+	} else if !strings.Contains(endpoint, "://") {
+		// STORAGE_EMULATOR_HOST is usually set to host:port.
+		endpoint = "http://" + endpoint
+	}
+
+	token := opts.GCSAccessToken
+	if token == "" {
+		token = os.Getenv("GCS_ACCESS_TOKEN")
+	}
+
+	return &GCSArchiveBackend{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		bucket:   bucket,
+		prefix:   prefix,
+		token:    token,
+	}, nil
+}
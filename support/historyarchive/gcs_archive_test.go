@@ -0,0 +1,170 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGCS is an in-memory implementation of the parts of the Google Cloud
+// Storage JSON API used by GCSArchiveBackend.
+type fakeGCS struct {
+	mutex    sync.Mutex
+	bucket   string
+	token    string
+	pageSize int
+	objects  map[string][]byte
+}
+
+func newFakeGCS(bucket, token string) *fakeGCS {
+	return &fakeGCS{
+		bucket:   bucket,
+		token:    token,
+		pageSize: 100,
+		objects:  make(map[string][]byte),
+	}
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	objects := "/storage/v1/b/" + f.bucket + "/o"
+	query := r.URL.Query()
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/upload"+objects:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[query.Get("name")] = body
+		json.NewEncoder(w).Encode(gcsObject{Name: query.Get("name"), Size: strconv.Itoa(len(body))})
+	case r.Method == "GET" && r.URL.Path == objects:
+		var names []string
+		for name := range f.objects {
+			if strings.HasPrefix(name, query.Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		start, _ := strconv.Atoi(query.Get("pageToken"))
+		var list gcsObjectList
+		for i := start; i < len(names) && i < start+f.pageSize; i++ {
+			list.Items = append(list.Items, gcsObject{Name: names[i]})
+		}
+		if start+f.pageSize < len(names) {
+			list.NextPageToken = strconv.Itoa(start + f.pageSize)
+		}
+		json.NewEncoder(w).Encode(list)
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, objects+"/"):
+		name := strings.TrimPrefix(r.URL.Path, objects+"/")
+		body, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if query.Get("alt") == "media" {
+			w.Write(body)
+			return
+		}
+		json.NewEncoder(w).Encode(gcsObject{Name: name, Size: strconv.Itoa(len(body))})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// testRemoteBackend exercises the ArchiveBackend methods of a freshly created
+// remote archive, then mirrors a random archive into it and scans it.
+func testRemoteBackend(t *testing.T, arch *Archive) {
+	backend := arch.backend
+
+	exists, err := backend.Exists(rootHASPath)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	_, err = backend.GetFile(rootHASPath)
+	assert.Error(t, err)
+
+	require.NoError(t, backend.PutFile(rootHASPath, ioutil.NopCloser(bytes.NewReader([]byte("{}")))))
+	exists, err = backend.Exists(rootHASPath)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	size, err := backend.Size(rootHASPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), size)
+	contents, err := readAll(backend, rootHASPath)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), contents)
+
+	opts := testOptions()
+	src := GetRandomPopulatedArchive()
+	require.NoError(t, Mirror(src, arch, opts))
+	assert.Equal(t, 0, countMissing(arch, opts))
+	assert.Equal(t, opts.Range.Size(), len(arch.checkpointFiles["history"]))
+}
+
+func TestGCSBackend(t *testing.T) {
+	fake := newFakeGCS("test-bucket", "secret")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	arch, err := Connect("gs://test-bucket/some/prefix", ConnectOptions{
+		GCSEndpoint:    server.URL,
+		GCSAccessToken: "secret",
+	})
+	require.NoError(t, err)
+	testRemoteBackend(t, arch)
+
+	fake.mutex.Lock()
+	for name := range fake.objects {
+		assert.True(t, strings.HasPrefix(name, "some/prefix/"), name)
+	}
+	fake.mutex.Unlock()
+
+	unauthorized, err := Connect("gs://test-bucket/some/prefix", ConnectOptions{
+		GCSEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+	_, err = unauthorized.GetRootHAS()
+	assert.Error(t, err)
+
+	// The token is read from $GCS_ACCESS_TOKEN when not set in options
+	previous, isSet := os.LookupEnv("GCS_ACCESS_TOKEN")
+	require.NoError(t, os.Setenv("GCS_ACCESS_TOKEN", "secret"))
+	defer func() {
+		if isSet {
+			os.Setenv("GCS_ACCESS_TOKEN", previous)
+		} else {
+			os.Unsetenv("GCS_ACCESS_TOKEN")
+		}
+	}()
+	fromEnv, err := Connect("gs://test-bucket/some/prefix", ConnectOptions{
+		GCSEndpoint: server.URL,
+	})
+	require.NoError(t, err)
+	_, err = fromEnv.GetRootHAS()
+	assert.NoError(t, err)
+
+	_, err = Connect("gs:///prefix", ConnectOptions{})
+	assert.EqualError(t, err, "bucket name is empty")
+}
@@ -14,6 +14,14 @@ import (
 	"github.com/stellar/go/support/errors"
 )
 
+func init() {
+	factory := func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		return makeHttpBackend(u, opts), nil
+	}
+	RegisterBackend("http", factory)
+	RegisterBackend("https", factory)
+}
+
 type HttpArchiveBackend struct {
 	client http.Client
 	base   url.URL
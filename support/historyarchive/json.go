@@ -5,6 +5,7 @@
 package historyarchive
 
 import (
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
@@ -67,11 +68,16 @@ func DumpXdrAsJson(args []string) error {
 				}
 			}
 			n++
-			buf, err := json.MarshalIndent(tmp, "", "    ")
+			raw, err := xdr.MarshalJSON(tmp)
 			if err != nil {
 				return err
 			}
-			os.Stdout.Write(buf)
+			var buf bytes.Buffer
+			if err = json.Indent(&buf, raw, "", "    "); err != nil {
+				return err
+			}
+			buf.WriteString("\n")
+			os.Stdout.Write(buf.Bytes())
 		}
 		xr.Close()
 	}
@@ -5,13 +5,13 @@
 package historyarchive
 
 import (
-	"github.com/stellar/go/xdr"
-	"io"
+	"context"
+	"fmt"
 	"log"
 	"time"
 )
 
-func (has *HistoryArchiveState) GetChangedBuckets(arch *Archive, prevHas *HistoryArchiveState) (string, int, int64) {
+func (has *HistoryArchiveState) GetChangedBuckets(arch *Archive, prevHas *HistoryArchiveState) (string, int, int64, error) {
 	var (
 		nChangedBytes   int64
 		nChangedBuckets int
@@ -19,88 +19,55 @@ func (has *HistoryArchiveState) GetChangedBuckets(arch *Archive, prevHas *Histor
 	)
 
 	for i, b := range has.CurrentBuckets {
-		if prevHas.CurrentBuckets[i].Curr != b.Curr {
-			nChangedBuckets += 1
-			changedBuckets += "#"
-			nChangedBytes += arch.MustGetBucketSize(MustDecodeHash(b.Curr))
-		} else {
-			changedBuckets += "_"
-		}
-		if prevHas.CurrentBuckets[i].Snap != b.Snap {
+		for _, pair := range [][2]string{
+			{prevHas.CurrentBuckets[i].Curr, b.Curr},
+			{prevHas.CurrentBuckets[i].Snap, b.Snap},
+		} {
+			if pair[0] == pair[1] {
+				changedBuckets += "_"
+				continue
+			}
+			hash, err := DecodeHash(pair[1])
+			if err != nil {
+				return "", 0, 0, err
+			}
 			nChangedBuckets += 1
 			changedBuckets += "#"
-			nChangedBytes += arch.MustGetBucketSize(MustDecodeHash(b.Snap))
-		} else {
-			changedBuckets += "_"
-		}
-	}
-	return changedBuckets, nChangedBuckets, nChangedBytes
-}
-
-func (arch *Archive) MustGetBucketSize(hash Hash) int64 {
-	sz, err := arch.backend.Size(arch.GetBucketPathForHash(hash))
-	if err != nil {
-		panic(err)
-	}
-	return sz
-}
-
-func (arch *Archive) MustGetLedgerHeaderHistoryEntries(chk uint32) []xdr.LedgerHeaderHistoryEntry {
-	path := CategoryCheckpointPath("ledger", chk)
-	rdr, err := arch.GetXdrStream(path)
-	if err != nil {
-		panic(err)
-	}
-	defer rdr.Close()
-	var lhes []xdr.LedgerHeaderHistoryEntry
-	for {
-		lhe := xdr.LedgerHeaderHistoryEntry{}
-		if err = rdr.ReadOne(&lhe); err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				panic(err)
+			// Empty buckets are not stored in the archive.
+			if hash.IsZero() {
+				continue
+			}
+			sz, err := arch.GetBucketSize(hash)
+			if err != nil {
+				return "", 0, 0, err
 			}
+			nChangedBytes += sz
 		}
-		lhes = append(lhes, lhe)
 	}
-	return lhes
+	return changedBuckets, nChangedBuckets, nChangedBytes, nil
 }
 
-func (arch *Archive) MustGetTransactionHistoryEntries(chk uint32) []xdr.TransactionHistoryEntry {
-	path := CategoryCheckpointPath("transactions", chk)
-	rdr, err := arch.GetXdrStream(path)
-	if err != nil {
-		panic(err)
-	}
-	defer rdr.Close()
-	var thes []xdr.TransactionHistoryEntry
-	for {
-		the := xdr.TransactionHistoryEntry{}
-		if err = rdr.ReadOne(&the); err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				panic(err)
-			}
-		}
-		thes = append(thes, the)
-	}
-	return thes
+// LogEntry summarizes a checkpoint, see ForEachLogEntry.
+type LogEntry struct {
+	Ledger       uint32    `json:"ledger"`
+	CloseTime    time.Time `json:"close_time"`
+	Transactions int       `json:"transactions"`
+	// ChangedBuckets is a map of the bucket list with a # for every bucket
+	// that changed since the previous checkpoint, and a _ otherwise.
+	ChangedBuckets      string `json:"changed_buckets"`
+	ChangedBucketsCount int    `json:"changed_buckets_count"`
+	ChangedBytes        int64  `json:"changed_bytes"`
 }
 
-func (arch *Archive) Log(opts *CommandOptions) error {
+// ForEachLogEntry calls fn with the LogEntry of every checkpoint of
+// opts.Range, clamped to the range of the archive, in order.
+func (arch *Archive) ForEachLogEntry(opts *CommandOptions, fn func(LogEntry) error) error {
 	state, e := arch.GetRootHAS()
 	if e != nil {
 		return e
 	}
 	opts.Range = opts.Range.clamp(state.Range())
-
-	log.SetFlags(0)
-	log.Printf("Log of checkpoint files in range: %s", opts.Range)
-	log.Printf("\n")
-	log.Printf("%10s | %10s | %20s | %5s | %s",
-		"ledger", "hex", "close time", "txs", "buckets changed")
+	ctx := context.Background()
 
 	prevHas, err := arch.GetCheckpointHAS(PrevCheckpoint(opts.Range.Low))
 	if err != nil {
@@ -113,23 +80,61 @@ func (arch *Archive) Log(opts *CommandOptions) error {
 			return err
 		}
 
-		changedBuckets, nChangedBuckets, nChangedBytes := has.GetChangedBuckets(arch, &prevHas)
+		changedBuckets, nChangedBuckets, nChangedBytes, err := has.GetChangedBuckets(arch, &prevHas)
+		if err != nil {
+			return err
+		}
 		prevHas = has
 
-		lhes := arch.MustGetLedgerHeaderHistoryEntries(chk)
+		lhes, err := arch.GetLedgerHeaderHistoryEntries(ctx, chk)
+		if err != nil {
+			return err
+		}
+		if len(lhes) == 0 {
+			return fmt.Errorf("no ledger headers in checkpoint 0x%8.8x", chk)
+		}
 		lastlhe := lhes[len(lhes)-1]
-		closeTime := time.Unix(int64(lastlhe.Header.ScpValue.CloseTime), 0)
 
 		nTxs := 0
-		thes := arch.MustGetTransactionHistoryEntries(chk)
+		thes, err := arch.GetTransactionHistoryEntries(ctx, chk)
+		if err != nil {
+			return err
+		}
 		for _, tx := range thes {
 			nTxs += len(tx.TxSet.Txs)
 		}
 
-		log.Printf("%10d | 0x%08x | %20s | %5d | %2d buckets, %10d bytes, %s",
-			has.CurrentLedger, has.CurrentLedger,
-			closeTime.UTC().Format(time.RFC3339),
-			nTxs, nChangedBuckets, nChangedBytes, changedBuckets)
+		err = fn(LogEntry{
+			Ledger:              has.CurrentLedger,
+			CloseTime:           time.Unix(int64(lastlhe.Header.ScpValue.CloseTime), 0).UTC(),
+			Transactions:        nTxs,
+			ChangedBuckets:      changedBuckets,
+			ChangedBucketsCount: nChangedBuckets,
+			ChangedBytes:        nChangedBytes,
+		})
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+func (arch *Archive) Log(opts *CommandOptions) error {
+	log.SetFlags(0)
+	header := false
+	return arch.ForEachLogEntry(opts, func(entry LogEntry) error {
+		if !header {
+			log.Printf("Log of checkpoint files in range: %s", opts.Range)
+			log.Printf("\n")
+			log.Printf("%10s | %10s | %20s | %5s | %s",
+				"ledger", "hex", "close time", "txs", "buckets changed")
+			header = true
+		}
+		log.Printf("%10d | 0x%08x | %20s | %5d | %2d buckets, %10d bytes, %s",
+			entry.Ledger, entry.Ledger,
+			entry.CloseTime.Format(time.RFC3339),
+			entry.Transactions, entry.ChangedBucketsCount, entry.ChangedBytes,
+			entry.ChangedBuckets)
+		return nil
+	})
+}
@@ -9,10 +9,17 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"strings"
 	"sync"
 )
 
+func init() {
+	RegisterBackend("mock", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		return makeMockBackend(opts), nil
+	})
+}
+
 type MockArchiveBackend struct {
 	mutex sync.Mutex
 	files map[string][]byte
@@ -1,6 +1,9 @@
 package historyarchive
 
 import (
+	"context"
+
+	"github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -82,3 +85,18 @@ func (m *MockArchive) GetXdrStream(pth string) (*XdrStream, error) {
 	a := m.Called(pth)
 	return a.Get(0).(*XdrStream), a.Error(1)
 }
+
+func (m *MockArchive) GetLedgerHeaderHistoryEntries(ctx context.Context, chk uint32) ([]xdr.LedgerHeaderHistoryEntry, error) {
+	a := m.Called(ctx, chk)
+	return a.Get(0).([]xdr.LedgerHeaderHistoryEntry), a.Error(1)
+}
+
+func (m *MockArchive) GetTransactionHistoryEntries(ctx context.Context, chk uint32) ([]xdr.TransactionHistoryEntry, error) {
+	a := m.Called(ctx, chk)
+	return a.Get(0).([]xdr.TransactionHistoryEntry), a.Error(1)
+}
+
+func (m *MockArchive) GetTransactionHistoryResultEntries(ctx context.Context, chk uint32) ([]xdr.TransactionHistoryResultEntry, error) {
+	a := m.Called(ctx, chk)
+	return a.Get(0).([]xdr.TransactionHistoryResultEntry), a.Error(1)
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"net/url"
 	"path"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -16,6 +17,17 @@ import (
 	"github.com/stellar/go/support/errors"
 )
 
+func init() {
+	RegisterBackend("s3", func(u *url.URL, opts ConnectOptions) (ArchiveBackend, error) {
+		// Inside s3, all paths start _without_ the leading /
+		pth := u.Path
+		if len(pth) > 0 && pth[0] == '/' {
+			pth = pth[1:]
+		}
+		return makeS3Backend(u.Host, pth, opts)
+	})
+}
+
 type S3ArchiveBackend struct {
 	svc              *s3.S3
 	bucket           string
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -313,6 +314,16 @@ func (arch *Archive) ClearCachedInfo() {
 	}
 	arch.allBuckets = make(map[Hash]bool)
 	arch.referencedBuckets = make(map[Hash]bool)
+	arch.expectLedgerHashes = make(map[uint32]Hash)
+	arch.actualLedgerHashes = make(map[uint32]Hash)
+	arch.expectTxSetHashes = make(map[uint32]Hash)
+	arch.actualTxSetHashes = make(map[uint32]Hash)
+	arch.expectTxResultSetHashes = make(map[uint32]Hash)
+	arch.actualTxResultSetHashes = make(map[uint32]Hash)
+	arch.invalidBuckets = 0
+	arch.invalidLedgers = 0
+	arch.invalidTxSets = 0
+	arch.invalidTxResultSets = 0
 }
 
 func (arch *Archive) ReportCheckpointStats() {
@@ -320,8 +331,7 @@ func (arch *Archive) ReportCheckpointStats() {
 	defer arch.mutex.Unlock()
 	s := make([]string, 0)
 	for _, cat := range Categories() {
-		tab := arch.checkpointFiles[cat]
-		s = append(s, fmt.Sprintf("%d %s", len(tab), cat))
+		s = append(s, fmt.Sprintf("%d %s", arch.countCheckpointFiles(cat), cat))
 	}
 	log.Printf("Archive: %s", strings.Join(s, ", "))
 }
@@ -333,6 +343,18 @@ func (arch *Archive) ReportBucketStats() {
 		len(arch.allBuckets), len(arch.referencedBuckets))
 }
 
+// countCheckpointFiles returns the number of cat files found by the scan. It
+// must be called with arch.mutex held.
+func (arch *Archive) countCheckpointFiles(cat string) int {
+	n := 0
+	for _, present := range arch.checkpointFiles[cat] {
+		if present {
+			n++
+		}
+	}
+	return n
+}
+
 func (arch *Archive) NoteCheckpointFile(cat string, chk uint32, present bool) {
 	arch.mutex.Lock()
 	defer arch.mutex.Unlock()
@@ -363,8 +385,8 @@ func (arch *Archive) CheckCheckpointFilesMissing(opts *CommandOptions) map[strin
 	for _, cat := range Categories() {
 		missing[cat] = make([]uint32, 0)
 		for ix := range opts.Range.Checkpoints() {
-			_, ok := arch.checkpointFiles[cat][ix]
-			if !ok {
+			// Slow scans note absent files too.
+			if !arch.checkpointFiles[cat][ix] {
 				missing[cat] = append(missing[cat], ix)
 			}
 		}
@@ -385,6 +407,73 @@ func (arch *Archive) CheckBucketsMissing() map[Hash]bool {
 	return missing
 }
 
+// ScanSummary is a structured summary of the results of Scan and, if
+// CommandOptions.Verify was set, ReportInvalid.
+type ScanSummary struct {
+	Range Range `json:"range"`
+	// CheckpointFiles is the number of files found in each category.
+	CheckpointFiles map[string]int `json:"checkpoint_files"`
+	// MissingCheckpointFiles lists the checkpoints missing a file of each
+	// required category.
+	MissingCheckpointFiles map[string][]uint32 `json:"missing_checkpoint_files"`
+	Buckets                int                 `json:"buckets"`
+	ReferencedBuckets      int                 `json:"referenced_buckets"`
+	MissingBuckets         []string            `json:"missing_buckets"`
+	InvalidBuckets         int                 `json:"invalid_buckets"`
+	InvalidLedgers         int                 `json:"invalid_ledgers"`
+	InvalidTxSets          int                 `json:"invalid_tx_sets"`
+	InvalidTxResultSets    int                 `json:"invalid_tx_result_sets"`
+}
+
+// MissingCount returns the number of missing checkpoint files and buckets.
+func (s *ScanSummary) MissingCount() int {
+	n := len(s.MissingBuckets)
+	for _, missing := range s.MissingCheckpointFiles {
+		n += len(missing)
+	}
+	return n
+}
+
+// InvalidCount returns the number of objects with unexpected hashes.
+func (s *ScanSummary) InvalidCount() int {
+	return s.InvalidBuckets + s.InvalidLedgers + s.InvalidTxSets + s.InvalidTxResultSets
+}
+
+// ScanSummary returns a summary of the last scan of opts.Range.
+func (arch *Archive) ScanSummary(opts *CommandOptions) ScanSummary {
+	missingCheckpointFiles := arch.CheckCheckpointFilesMissing(opts)
+	missingBuckets := arch.CheckBucketsMissing()
+
+	summary := ScanSummary{
+		Range:                  opts.Range,
+		CheckpointFiles:        make(map[string]int),
+		MissingCheckpointFiles: make(map[string][]uint32),
+		MissingBuckets:         make([]string, 0, len(missingBuckets)),
+	}
+	for cat, missing := range missingCheckpointFiles {
+		if categoryRequired(cat) {
+			summary.MissingCheckpointFiles[cat] = missing
+		}
+	}
+	for bucket := range missingBuckets {
+		summary.MissingBuckets = append(summary.MissingBuckets, bucket.String())
+	}
+	sort.Strings(summary.MissingBuckets)
+
+	arch.mutex.Lock()
+	defer arch.mutex.Unlock()
+	for _, cat := range Categories() {
+		summary.CheckpointFiles[cat] = arch.countCheckpointFiles(cat)
+	}
+	summary.Buckets = len(arch.allBuckets)
+	summary.ReferencedBuckets = len(arch.referencedBuckets)
+	summary.InvalidBuckets = arch.invalidBuckets
+	summary.InvalidLedgers = arch.invalidLedgers
+	summary.InvalidTxSets = arch.invalidTxSets
+	summary.InvalidTxResultSets = arch.invalidTxResultSets
+	return summary
+}
+
 func (arch *Archive) ReportMissing(opts *CommandOptions) error {
 
 	log.Printf("Examining checkpoint files for gaps")
@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"path"
+	"sync"
 )
 
 func makeTicker(onTick func(uint)) chan bool {
@@ -136,3 +137,27 @@ func drainErrors(errs chan error) uint32 {
 	}
 	return count
 }
+
+// parallel calls fn for every index in [0, n) from at most concurrency
+// goroutines.
+func parallel(n int, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,319 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stellar/go/xdr"
+)
+
+// Kinds of VerifyIssue.
+const (
+	IssueMissingFile        = "missing_file"
+	IssueReadError          = "read_error"
+	IssueMissingLedger      = "missing_ledger"
+	IssueLedgerHash         = "ledger_hash"
+	IssuePreviousLedgerHash = "previous_ledger_hash"
+	IssueTxSetHash          = "tx_set_hash"
+	IssueTxResultSetHash    = "tx_result_set_hash"
+	IssueBucketListHash     = "bucket_list_hash"
+)
+
+// VerifyIssue is a single inconsistency found by VerifyRange.
+type VerifyIssue struct {
+	Kind       string `json:"kind"`
+	Checkpoint uint32 `json:"checkpoint"`
+	Ledger     uint32 `json:"ledger,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Expected   string `json:"expected,omitempty"`
+	Actual     string `json:"actual,omitempty"`
+	Message    string `json:"message"`
+}
+
+func (i VerifyIssue) String() string {
+	return fmt.Sprintf("checkpoint 0x%8.8x: %s", i.Checkpoint, i.Message)
+}
+
+// VerifyReport is the result of VerifyRange. It's meant to be serialized to
+// JSON.
+type VerifyReport struct {
+	Low         uint32        `json:"low"`
+	High        uint32        `json:"high"`
+	Checkpoints int           `json:"checkpoints"`
+	Ledgers     int           `json:"ledgers"`
+	Issues      []VerifyIssue `json:"issues"`
+}
+
+// OK returns true if no issues were found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// checkpointVerification is the result of verifying a single checkpoint. The
+// first and last headers are used to check the chain across checkpoints.
+type checkpointVerification struct {
+	issues  []VerifyIssue
+	ledgers int
+	first   *xdr.LedgerHeaderHistoryEntry
+	last    *xdr.LedgerHeaderHistoryEntry
+}
+
+func (v *checkpointVerification) report(issue VerifyIssue) {
+	v.issues = append(v.issues, issue)
+}
+
+// VerifyRange verifies the checkpoints of opts.Range, clamped to the range
+// of the archive, and returns a report of every inconsistency found. For every
+// ledger it checks that:
+//
+//   - the ledger header hashes to the hash recorded next to it,
+//   - the previousLedgerHash of the header matches the hash of the previous
+//     ledger, including across checkpoints,
+//   - the transaction set hashes to the txSetHash of the header,
+//   - the transaction result set hashes to the txSetResultHash of the header,
+//
+// and for every checkpoint that the bucket list hash of its HAS matches the
+// bucketListHash of the checkpoint ledger header.
+//
+// Missing or unreadable files are reported as issues too. An error is
+// returned only if the archive state can't be read.
+func (arch *Archive) VerifyRange(opts *CommandOptions) (*VerifyReport, error) {
+	state, err := arch.GetRootHAS()
+	if err != nil {
+		return nil, err
+	}
+	rng := opts.Range.clamp(state.Range())
+
+	var checkpoints []uint32
+	for chk := range rng.Checkpoints() {
+		checkpoints = append(checkpoints, chk)
+	}
+	results := make([]checkpointVerification, len(checkpoints))
+
+	parallel(len(checkpoints), opts.Concurrency, func(i int) {
+		results[i] = arch.verifyCheckpoint(checkpoints[i])
+	})
+
+	report := &VerifyReport{
+		Low:         rng.Low,
+		High:        rng.High,
+		Checkpoints: len(checkpoints),
+		Issues:      []VerifyIssue{},
+	}
+	for i, result := range results {
+		report.Ledgers += result.ledgers
+		report.Issues = append(report.Issues, result.issues...)
+
+		// Link the first ledger of the checkpoint to the last ledger of
+		// the previous one.
+		if i == 0 || result.first == nil || results[i-1].last == nil {
+			continue
+		}
+		prev := results[i-1].last
+		if result.first.Header.LedgerSeq != prev.Header.LedgerSeq+1 {
+			continue
+		}
+		if result.first.Header.PreviousLedgerHash != prev.Hash {
+			report.Issues = append(report.Issues, VerifyIssue{
+				Kind:       IssuePreviousLedgerHash,
+				Checkpoint: checkpoints[i],
+				Ledger:     uint32(result.first.Header.LedgerSeq),
+				Expected:   Hash(prev.Hash).String(),
+				Actual:     Hash(result.first.Header.PreviousLedgerHash).String(),
+				Message: fmt.Sprintf("ledger %d previous ledger hash doesn't match ledger %d",
+					result.first.Header.LedgerSeq, prev.Header.LedgerSeq),
+			})
+		}
+	}
+	return report, nil
+}
+
+func (arch *Archive) verifyCheckpoint(chk uint32) checkpointVerification {
+	var v checkpointVerification
+
+	var headers []xdr.LedgerHeaderHistoryEntry
+	arch.readCheckpointEntries(&v, chk, "ledger",
+		func() interface{} { return &xdr.LedgerHeaderHistoryEntry{} },
+		func(entry interface{}) error {
+			headers = append(headers, *entry.(*xdr.LedgerHeaderHistoryEntry))
+			return nil
+		})
+
+	txSetHashes := make(map[uint32]Hash)
+	arch.readCheckpointEntries(&v, chk, "transactions",
+		func() interface{} { return &xdr.TransactionHistoryEntry{} },
+		func(entry interface{}) error {
+			the := entry.(*xdr.TransactionHistoryEntry)
+			h, err := HashTxSet(&the.TxSet)
+			txSetHashes[uint32(the.LedgerSeq)] = h
+			return err
+		})
+
+	resultSetHashes := make(map[uint32]Hash)
+	arch.readCheckpointEntries(&v, chk, "results",
+		func() interface{} { return &xdr.TransactionHistoryResultEntry{} },
+		func(entry interface{}) error {
+			thre := entry.(*xdr.TransactionHistoryResultEntry)
+			h, err := HashXdr(&thre.TxResultSet)
+			resultSetHashes[uint32(thre.LedgerSeq)] = h
+			return err
+		})
+
+	low, high := checkpointLedgerRange(chk)
+	if len(headers) > 0 {
+		v.first = &headers[0]
+		v.last = &headers[len(headers)-1]
+	}
+	expectedSeq := low
+	for i := range headers {
+		entry := &headers[i]
+		seq := uint32(entry.Header.LedgerSeq)
+		v.ledgers++
+
+		for ; expectedSeq < seq; expectedSeq++ {
+			v.report(VerifyIssue{
+				Kind:       IssueMissingLedger,
+				Checkpoint: chk,
+				Ledger:     expectedSeq,
+				Message:    fmt.Sprintf("ledger %d is missing", expectedSeq),
+			})
+		}
+		expectedSeq = seq + 1
+
+		if h, err := HashXdr(&entry.Header); err == nil && h != Hash(entry.Hash) {
+			v.report(VerifyIssue{
+				Kind:       IssueLedgerHash,
+				Checkpoint: chk,
+				Ledger:     seq,
+				Expected:   Hash(entry.Hash).String(),
+				Actual:     h.String(),
+				Message:    fmt.Sprintf("ledger %d header doesn't match its hash", seq),
+			})
+		}
+
+		if i > 0 && uint32(headers[i-1].Header.LedgerSeq) == seq-1 &&
+			entry.Header.PreviousLedgerHash != headers[i-1].Hash {
+			v.report(VerifyIssue{
+				Kind:       IssuePreviousLedgerHash,
+				Checkpoint: chk,
+				Ledger:     seq,
+				Expected:   Hash(headers[i-1].Hash).String(),
+				Actual:     Hash(entry.Header.PreviousLedgerHash).String(),
+				Message:    fmt.Sprintf("ledger %d previous ledger hash doesn't match ledger %d", seq, seq-1),
+			})
+		}
+
+		txSetHash, ok := txSetHashes[seq]
+		if !ok {
+			txSetHash = HashEmptyTxSet(Hash(entry.Header.PreviousLedgerHash))
+		}
+		if txSetHash != Hash(entry.Header.ScpValue.TxSetHash) {
+			v.report(VerifyIssue{
+				Kind:       IssueTxSetHash,
+				Checkpoint: chk,
+				Ledger:     seq,
+				Expected:   Hash(entry.Header.ScpValue.TxSetHash).String(),
+				Actual:     txSetHash.String(),
+				Message:    fmt.Sprintf("ledger %d transaction set doesn't match header", seq),
+			})
+		}
+
+		resultSetHash, ok := resultSetHashes[seq]
+		if !ok {
+			resultSetHash = EmptyXdrArrayHash()
+		}
+		if resultSetHash != Hash(entry.Header.TxSetResultHash) {
+			v.report(VerifyIssue{
+				Kind:       IssueTxResultSetHash,
+				Checkpoint: chk,
+				Ledger:     seq,
+				Expected:   Hash(entry.Header.TxSetResultHash).String(),
+				Actual:     resultSetHash.String(),
+				Message:    fmt.Sprintf("ledger %d transaction result set doesn't match header", seq),
+			})
+		}
+	}
+	if len(headers) > 0 {
+		for ; expectedSeq <= high; expectedSeq++ {
+			v.report(VerifyIssue{
+				Kind:       IssueMissingLedger,
+				Checkpoint: chk,
+				Ledger:     expectedSeq,
+				Message:    fmt.Sprintf("ledger %d is missing", expectedSeq),
+			})
+		}
+	}
+
+	pth := CategoryCheckpointPath("history", chk)
+	has, err := arch.GetCheckpointHAS(chk)
+	if err != nil {
+		v.reportReadError(chk, pth, err)
+		return v
+	}
+	if v.last == nil || uint32(v.last.Header.LedgerSeq) != chk {
+		// The checkpoint ledger header is missing, already reported.
+		return v
+	}
+	bucketListHash, err := has.BucketListHash()
+	if err != nil {
+		v.reportReadError(chk, pth, err)
+		return v
+	}
+	if Hash(bucketListHash) != Hash(v.last.Header.BucketListHash) {
+		v.report(VerifyIssue{
+			Kind:       IssueBucketListHash,
+			Checkpoint: chk,
+			Ledger:     chk,
+			Path:       pth,
+			Expected:   Hash(v.last.Header.BucketListHash).String(),
+			Actual:     Hash(bucketListHash).String(),
+			Message:    fmt.Sprintf("bucket list hash of HAS doesn't match ledger %d header", chk),
+		})
+	}
+	return v
+}
+
+// readCheckpointEntries decodes all the entries of a checkpoint file into
+// values returned by newEntry and passes them to handle.
+func (arch *Archive) readCheckpointEntries(v *checkpointVerification, chk uint32, cat string,
+	newEntry func() interface{}, handle func(entry interface{}) error) {
+	pth := CategoryCheckpointPath(cat, chk)
+	exists, err := arch.backend.Exists(pth)
+	if err != nil {
+		v.reportReadError(chk, pth, err)
+		return
+	}
+	if !exists {
+		v.report(VerifyIssue{
+			Kind:       IssueMissingFile,
+			Checkpoint: chk,
+			Path:       pth,
+			Message:    "missing " + pth,
+		})
+		return
+	}
+
+	err = arch.forEachCheckpointEntry(context.Background(), cat, chk, newEntry, handle)
+	if err != nil {
+		// The error already mentions the path.
+		v.report(VerifyIssue{
+			Kind:       IssueReadError,
+			Checkpoint: chk,
+			Path:       pth,
+			Message:    err.Error(),
+		})
+	}
+}
+
+func (v *checkpointVerification) reportReadError(chk uint32, pth string, err error) {
+	v.report(VerifyIssue{
+		Kind:       IssueReadError,
+		Checkpoint: chk,
+		Path:       pth,
+		Message:    fmt.Sprintf("error reading %s: %v", pth, err),
+	})
+}
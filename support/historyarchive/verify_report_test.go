@@ -0,0 +1,148 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestChain writes a valid chain of checkpoints up to high to arch and
+// returns the data of every checkpoint.
+func writeTestChain(t *testing.T, arch *Archive, high uint32) map[uint32]CheckpointData {
+	checkpoints := make(map[uint32]CheckpointData)
+	var prev Hash
+	for chk := range MakeRange(0, high).Checkpoints() {
+		var data CheckpointData
+		data, prev = makeTestCheckpoint(t, arch, chk, prev)
+		require.NoError(t, arch.WriteCheckpoint(chk, data, &CommandOptions{}))
+		checkpoints[chk] = data
+	}
+	return checkpoints
+}
+
+func issueKinds(report *VerifyReport) []string {
+	kinds := []string{}
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
+
+func TestVerifyRangeValidArchive(t *testing.T) {
+	arch := GetTestMockArchive()
+	writeTestChain(t, arch, 255)
+
+	report, err := arch.VerifyRange(&CommandOptions{Range: MakeRange(0, 0xffffffff), Concurrency: 4})
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Issues)
+	assert.Equal(t, uint32(63), report.Low)
+	assert.Equal(t, uint32(255), report.High)
+	assert.Equal(t, 4, report.Checkpoints)
+	assert.Equal(t, 255, report.Ledgers)
+
+	encoded, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"low":63,"high":255,"checkpoints":4,"ledgers":255,"issues":[]}`, string(encoded))
+}
+
+func TestVerifyRangeReportsInconsistencies(t *testing.T) {
+	arch := GetTestMockArchive()
+	checkpoints := writeTestChain(t, arch, 255)
+	force := &CommandOptions{Force: true}
+
+	// Break the chain between checkpoints 0x7f and 0xbf.
+	data, _ := makeTestCheckpoint(t, arch, 191, Hash{})
+	require.NoError(t, arch.WriteCheckpoint(191, data, force))
+
+	// Tamper with a ledger header, a transaction set and a result set of
+	// checkpoint 0x7f, and drop one of its ledgers.
+	data = checkpoints[127]
+	data.LedgerHeaders = append([]xdr.LedgerHeaderHistoryEntry{}, data.LedgerHeaders...)
+	data.LedgerHeaders[10].Header.BaseFee = 200
+	data.Transactions = append([]xdr.TransactionHistoryEntry{}, data.Transactions...)
+	data.Transactions[0].TxSet.Txs = nil
+	data.Results = nil
+	require.NoError(t, arch.WriteCheckpoint(127, data, force))
+	ledgers := append([]xdr.LedgerHeaderHistoryEntry{}, data.LedgerHeaders[:20]...)
+	ledgers = append(ledgers, data.LedgerHeaders[21:]...)
+	w := newXdrGzWriter()
+	for i := range ledgers {
+		require.NoError(t, w.Write(&ledgers[i]))
+	}
+	_, err := w.Close()
+	require.NoError(t, err)
+	require.NoError(t, arch.backend.PutFile(CategoryCheckpointPath("ledger", 127), w.Reader()))
+
+	// Point the HAS of checkpoint 0xff to a different bucket list.
+	has := checkpoints[255].State
+	has.CurrentBuckets[1].Curr = has.CurrentBuckets[0].Curr
+	require.NoError(t, arch.PutCheckpointHAS(255, has, force))
+
+	// Remove the results of checkpoint 0x3f.
+	backend := arch.backend.(*MockArchiveBackend)
+	delete(backend.files, CategoryCheckpointPath("results", 63))
+
+	report, err := arch.VerifyRange(&CommandOptions{Range: MakeRange(0, 255), Concurrency: 4})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Equal(t, 254, report.Ledgers)
+
+	assert.ElementsMatch(t, []string{
+		// checkpoint 0x3f
+		IssueMissingFile,
+		IssueTxResultSetHash,
+		// checkpoint 0x7f
+		IssueMissingLedger,
+		IssueLedgerHash,
+		IssueTxSetHash,
+		IssueTxResultSetHash,
+		// checkpoint 0xbf
+		IssuePreviousLedgerHash,
+		// checkpoint 0xff
+		IssuePreviousLedgerHash,
+		IssueBucketListHash,
+	}, issueKinds(report))
+
+	for _, issue := range report.Issues {
+		switch issue.Kind {
+		case IssueMissingFile:
+			assert.Equal(t, uint32(63), issue.Checkpoint)
+			assert.Equal(t, CategoryCheckpointPath("results", 63), issue.Path)
+		case IssueMissingLedger:
+			assert.Equal(t, uint32(84), issue.Ledger)
+		case IssueLedgerHash:
+			assert.Equal(t, uint32(74), issue.Ledger)
+		case IssuePreviousLedgerHash:
+			// The rewritten checkpoint 0xbf is linked to neither of its
+			// neighbours.
+			if issue.Checkpoint == 191 {
+				assert.Equal(t, uint32(128), issue.Ledger)
+				assert.Equal(t, Hash{}.String(), issue.Actual)
+			} else {
+				assert.Equal(t, uint32(192), issue.Ledger)
+			}
+		case IssueTxSetHash:
+			assert.Equal(t, uint32(65), issue.Ledger)
+		case IssueTxResultSetHash:
+			if issue.Checkpoint == 127 {
+				assert.Equal(t, uint32(65), issue.Ledger)
+			} else {
+				assert.Equal(t, uint32(2), issue.Ledger)
+			}
+		case IssueBucketListHash:
+			assert.Equal(t, uint32(255), issue.Checkpoint)
+		}
+	}
+
+	// Only the requested range is verified.
+	report, err = arch.VerifyRange(&CommandOptions{Range: MakeRange(255, 255)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{IssueBucketListHash}, issueKinds(report))
+}
@@ -0,0 +1,268 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// CheckpointData is the contents of a checkpoint written by WriteCheckpoint.
+type CheckpointData struct {
+	// LedgerHeaders are the headers of all the ledgers of the checkpoint, in
+	// ascending order.
+	LedgerHeaders []xdr.LedgerHeaderHistoryEntry
+	// Transactions are the transaction sets of the checkpoint ledgers, in
+	// ascending order. Ledgers with empty transaction sets can be omitted.
+	Transactions []xdr.TransactionHistoryEntry
+	// Results are the transaction results of the checkpoint ledgers, in
+	// ascending order. Ledgers with no transactions can be omitted.
+	Results []xdr.TransactionHistoryResultEntry
+	// SCP are the SCP messages of the checkpoint ledgers, in ascending order.
+	SCP []xdr.ScpHistoryEntry
+	// State is the bucket list of the checkpoint ledger. The buckets it
+	// references must be present in the archive, see WriteBucket.
+	// CurrentLedger is set to the checkpoint ledger.
+	State HistoryArchiveState
+}
+
+// xdrGzWriter writes framed XDR values into a gzipped buffer while hashing
+// the uncompressed stream.
+type xdrGzWriter struct {
+	buf bytes.Buffer
+	gz  *gzip.Writer
+	hsh hash.Hash
+	out io.Writer
+}
+
+func newXdrGzWriter() *xdrGzWriter {
+	w := &xdrGzWriter{hsh: sha256.New()}
+	w.gz = gzip.NewWriter(&w.buf)
+	w.out = io.MultiWriter(w.gz, w.hsh)
+	return w
+}
+
+func (w *xdrGzWriter) Write(in interface{}) error {
+	return WriteFramedXdr(w.out, in)
+}
+
+// Close flushes the gzip stream and returns the hash of the uncompressed
+// stream.
+func (w *xdrGzWriter) Close() (Hash, error) {
+	var h Hash
+	if err := w.gz.Close(); err != nil {
+		return h, err
+	}
+	copy(h[:], w.hsh.Sum([]byte{}))
+	return h, nil
+}
+
+func (w *xdrGzWriter) Reader() io.ReadCloser {
+	return ioutil.NopCloser(bytes.NewReader(w.buf.Bytes()))
+}
+
+// WriteBucket writes a bucket file containing entries and returns its hash,
+// to be referenced in the HistoryArchiveState of a checkpoint. Empty buckets
+// have the zero hash and are not written. Buckets are content-addressed so an
+// existing bucket is never overwritten.
+func (a *Archive) WriteBucket(entries []xdr.BucketEntry, opts *CommandOptions) (Hash, error) {
+	var h Hash
+	if len(entries) == 0 {
+		return h, nil
+	}
+
+	w := newXdrGzWriter()
+	for i := range entries {
+		if err := w.Write(&entries[i]); err != nil {
+			return h, errors.Wrap(err, "error encoding bucket entry")
+		}
+	}
+	h, err := w.Close()
+	if err != nil {
+		return h, err
+	}
+
+	pth := BucketPath(h)
+	if opts.DryRun {
+		log.Printf("dryrun skipping " + pth)
+		return h, nil
+	}
+	exists, err := a.backend.Exists(pth)
+	if err != nil {
+		return h, err
+	}
+	if exists {
+		return h, nil
+	}
+	return h, a.backend.PutFile(pth, w.Reader())
+}
+
+// WriteCheckpoint writes the ledger, transactions, results and scp files of
+// checkpoint chk, then its HistoryArchiveState and, if chk is newer than the
+// current root HAS, the root `.well-known/stellar-history.json`. The HAS files
+// are written last so readers never see a checkpoint with missing files.
+//
+// The data is checked for consistency with the checkpoint range but hashes
+// are not verified; use Scan with CommandOptions.Verify for that.
+func (a *Archive) WriteCheckpoint(chk uint32, data CheckpointData, opts *CommandOptions) error {
+	if !IsCheckpoint(chk) {
+		return errors.Errorf("ledger %d is not a checkpoint ledger", chk)
+	}
+	if err := data.validate(chk); err != nil {
+		return errors.Wrapf(err, "invalid data for checkpoint 0x%8.8x", chk)
+	}
+
+	has := data.State
+	has.CurrentLedger = chk
+	if has.Version == 0 {
+		has.Version = 1
+	}
+	buckets, err := has.Buckets()
+	if err != nil {
+		return errors.Wrap(err, "invalid HAS")
+	}
+	if !opts.DryRun {
+		for _, bucket := range buckets {
+			exists, err := a.BucketExists(bucket)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return errors.Errorf("bucket %s referenced by HAS is missing", bucket)
+			}
+		}
+	}
+
+	files := []struct {
+		cat   string
+		count int
+		entry func(i int) interface{}
+	}{
+		{"ledger", len(data.LedgerHeaders), func(i int) interface{} { return &data.LedgerHeaders[i] }},
+		{"transactions", len(data.Transactions), func(i int) interface{} { return &data.Transactions[i] }},
+		{"results", len(data.Results), func(i int) interface{} { return &data.Results[i] }},
+		{"scp", len(data.SCP), func(i int) interface{} { return &data.SCP[i] }},
+	}
+	for _, f := range files {
+		w := newXdrGzWriter()
+		for i := 0; i < f.count; i++ {
+			if err := w.Write(f.entry(i)); err != nil {
+				return errors.Wrapf(err, "error encoding %s entry", f.cat)
+			}
+		}
+		if _, err := w.Close(); err != nil {
+			return err
+		}
+		if err := a.putCheckpointFile(CategoryCheckpointPath(f.cat, chk), w.Reader(), opts); err != nil {
+			return errors.Wrapf(err, "error writing %s file", f.cat)
+		}
+	}
+
+	if opts.DryRun {
+		log.Printf("dryrun skipping HAS of checkpoint 0x%8.8x", chk)
+		return nil
+	}
+	if err := a.PutCheckpointHAS(chk, has, opts); err != nil {
+		return errors.Wrap(err, "error writing checkpoint HAS")
+	}
+
+	exists, err := a.backend.Exists(rootHASPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		root, err := a.GetRootHAS()
+		if err != nil {
+			return errors.Wrap(err, "error getting root HAS")
+		}
+		if root.CurrentLedger >= chk {
+			return nil
+		}
+	}
+	return errors.Wrap(a.PutRootHAS(has, opts), "error writing root HAS")
+}
+
+func (a *Archive) putCheckpointFile(pth string, rdr io.ReadCloser, opts *CommandOptions) error {
+	if opts.DryRun {
+		log.Printf("dryrun skipping " + pth)
+		return nil
+	}
+	exists, err := a.backend.Exists(pth)
+	if err != nil {
+		return err
+	}
+	if exists && !opts.Force {
+		log.Printf("skipping existing " + pth)
+		return nil
+	}
+	return a.backend.PutFile(pth, rdr)
+}
+
+// checkpointLedgerRange returns the first and last ledger of checkpoint chk.
+// The first checkpoint has 63 ledgers because ledger 0 doesn't exist.
+func checkpointLedgerRange(chk uint32) (uint32, uint32) {
+	if chk < CheckpointFreq {
+		return 1, chk
+	}
+	return chk - CheckpointFreq + 1, chk
+}
+
+func (d *CheckpointData) validate(chk uint32) error {
+	low, high := checkpointLedgerRange(chk)
+
+	if len(d.LedgerHeaders) != int(high-low+1) {
+		return errors.Errorf("expected %d ledger headers, got %d",
+			high-low+1, len(d.LedgerHeaders))
+	}
+	for i, entry := range d.LedgerHeaders {
+		if seq := uint32(entry.Header.LedgerSeq); seq != low+uint32(i) {
+			return errors.Errorf("ledger header %d has sequence %d, expected %d",
+				i, seq, low+uint32(i))
+		}
+	}
+
+	check := func(name string, count int, seq func(i int) uint32) error {
+		prev := uint32(0)
+		for i := 0; i < count; i++ {
+			s := seq(i)
+			if s < low || s > high {
+				return errors.Errorf("%s entry for ledger %d is outside of checkpoint range [%d, %d]",
+					name, s, low, high)
+			}
+			if s <= prev {
+				return errors.Errorf("%s entries are not in ascending ledger order", name)
+			}
+			prev = s
+		}
+		return nil
+	}
+
+	if err := check("transactions", len(d.Transactions), func(i int) uint32 {
+		return uint32(d.Transactions[i].LedgerSeq)
+	}); err != nil {
+		return err
+	}
+	if err := check("results", len(d.Results), func(i int) uint32 {
+		return uint32(d.Results[i].LedgerSeq)
+	}); err != nil {
+		return err
+	}
+	for _, entry := range d.SCP {
+		if entry.V0 == nil {
+			return errors.Errorf("unsupported scp entry version %d", entry.V)
+		}
+	}
+	return check("scp", len(d.SCP), func(i int) uint32 {
+		return uint32(d.SCP[i].V0.LedgerMessages.LedgerSeq)
+	})
+}
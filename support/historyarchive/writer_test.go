@@ -0,0 +1,229 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package historyarchive
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTestCheckpoint returns the data of a valid checkpoint chk following a
+// ledger with hash prev, with a single bucket written to arch. The second
+// ledger of the checkpoint has a transaction, the other ones have empty
+// transaction sets. It returns the hash of the last ledger header.
+func makeTestCheckpoint(t *testing.T, arch *Archive, chk uint32, prev Hash) (CheckpointData, Hash) {
+	bucket, err := arch.WriteBucket([]xdr.BucketEntry{
+		{
+			Type:      xdr.BucketEntryTypeMetaentry,
+			MetaEntry: &xdr.BucketMetadata{LedgerVersion: 11},
+		},
+		{
+			Type: xdr.BucketEntryTypeDeadentry,
+			DeadEntry: &xdr.LedgerKey{
+				Type: xdr.LedgerEntryTypeAccount,
+				Account: &xdr.LedgerKeyAccount{
+					AccountId: xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB"),
+				},
+			},
+		},
+		{
+			Type: xdr.BucketEntryTypeDeadentry,
+			DeadEntry: &xdr.LedgerKey{
+				Type: xdr.LedgerEntryTypeAccount,
+				Account: &xdr.LedgerKeyAccount{
+					AccountId: xdr.MustAddress("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"),
+				},
+			},
+		},
+	}, &CommandOptions{})
+	require.NoError(t, err)
+
+	var data CheckpointData
+	data.State.Server = "test"
+	data.State.CurrentLedger = chk
+	for i := range data.State.CurrentBuckets {
+		data.State.CurrentBuckets[i].Curr = Hash{}.String()
+		data.State.CurrentBuckets[i].Snap = Hash{}.String()
+	}
+	data.State.CurrentBuckets[0].Curr = bucket.String()
+	bucketListHash, err := data.State.BucketListHash()
+	require.NoError(t, err)
+
+	low, high := checkpointLedgerRange(chk)
+	for seq := low; seq <= high; seq++ {
+		header := xdr.LedgerHeader{
+			LedgerVersion:      11,
+			PreviousLedgerHash: xdr.Hash(prev),
+			ScpValue: xdr.StellarValue{
+				TxSetHash: xdr.Hash(HashEmptyTxSet(prev)),
+				CloseTime: xdr.TimePoint(1500000000 + seq*5),
+			},
+			TxSetResultHash: xdr.Hash(EmptyXdrArrayHash()),
+			LedgerSeq:       xdr.Uint32(seq),
+			BaseFee:         100,
+			BaseReserve:     5000000,
+		}
+		if seq == high {
+			header.BucketListHash = bucketListHash
+		}
+
+		if seq == low+1 {
+			txSet := xdr.TransactionSet{
+				PreviousLedgerHash: xdr.Hash(prev),
+				Txs: []xdr.TransactionEnvelope{{
+					Tx: xdr.Transaction{
+						SourceAccount: xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB"),
+						Fee:           100,
+						SeqNum:        xdr.SequenceNumber(seq),
+						Memo:          xdr.Memo{Type: xdr.MemoTypeMemoNone},
+					},
+				}},
+			}
+			txSetHash, err := HashTxSet(&txSet)
+			require.NoError(t, err)
+			header.ScpValue.TxSetHash = xdr.Hash(txSetHash)
+			txHash, err := network.HashTransaction(&txSet.Txs[0].Tx, network.TestNetworkPassphrase)
+			require.NoError(t, err)
+
+			resultSet := xdr.TransactionResultSet{
+				Results: []xdr.TransactionResultPair{{
+					TransactionHash: xdr.Hash(txHash),
+					Result: xdr.TransactionResult{
+						FeeCharged: 100,
+						Result: xdr.TransactionResultResult{
+							Code:    xdr.TransactionResultCodeTxSuccess,
+							Results: &[]xdr.OperationResult{},
+						},
+					},
+				}},
+			}
+			resultSetHash, err := HashXdr(&resultSet)
+			require.NoError(t, err)
+			header.TxSetResultHash = xdr.Hash(resultSetHash)
+
+			data.Transactions = append(data.Transactions, xdr.TransactionHistoryEntry{
+				LedgerSeq: xdr.Uint32(seq),
+				TxSet:     txSet,
+			})
+			data.Results = append(data.Results, xdr.TransactionHistoryResultEntry{
+				LedgerSeq:   xdr.Uint32(seq),
+				TxResultSet: resultSet,
+			})
+		}
+
+		h, err := HashXdr(&header)
+		require.NoError(t, err)
+		data.LedgerHeaders = append(data.LedgerHeaders, xdr.LedgerHeaderHistoryEntry{
+			Hash:   xdr.Hash(h),
+			Header: header,
+		})
+		prev = h
+	}
+	return data, prev
+}
+
+func TestWriteCheckpoints(t *testing.T) {
+	arch := GetTestMockArchive()
+	opts := &CommandOptions{}
+
+	var prev Hash
+	var data CheckpointData
+	for _, chk := range []uint32{63, 127, 191} {
+		data, prev = makeTestCheckpoint(t, arch, chk, prev)
+		require.NoError(t, arch.WriteCheckpoint(chk, data, opts))
+
+		root, err := arch.GetRootHAS()
+		require.NoError(t, err)
+		assert.Equal(t, chk, root.CurrentLedger)
+	}
+
+	has, err := arch.GetCheckpointHAS(127)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(127), has.CurrentLedger)
+	assert.Equal(t, 1, has.Version)
+	assert.Equal(t, data.State.CurrentBuckets, has.CurrentBuckets)
+
+	headers, err := arch.GetLedgerHeaderHistoryEntries(context.Background(), 191)
+	require.NoError(t, err)
+	assert.Equal(t, data.LedgerHeaders, headers)
+	txs, err := arch.GetTransactionHistoryEntries(context.Background(), 191)
+	require.NoError(t, err)
+	assert.Equal(t, data.Transactions, txs)
+	exists, err := arch.CategoryCheckpointExists("scp", 191)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Buckets are named after the hash of their uncompressed contents.
+	bucket := MustDecodeHash(has.CurrentBuckets[0].Curr)
+	rdr, err := arch.backend.GetFile(BucketPath(bucket))
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(rdr)
+	require.NoError(t, err)
+	hsh := sha256.New()
+	_, err = io.Copy(hsh, gz)
+	require.NoError(t, err)
+	assert.NoError(t, checkBucketHash(hsh, bucket))
+
+	// Writing an older checkpoint doesn't move the root HAS back.
+	data, _ = makeTestCheckpoint(t, arch, 63, Hash{})
+	require.NoError(t, arch.WriteCheckpoint(63, data, &CommandOptions{Force: true}))
+	root, err := arch.GetRootHAS()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(191), root.CurrentLedger)
+
+	// The written archive is complete and its hashes are valid.
+	verifyOpts := &CommandOptions{Range: MakeRange(0, 191), Concurrency: 4, Verify: true}
+	assert.Equal(t, 0, countMissing(arch, verifyOpts))
+	assert.NoError(t, arch.ReportInvalid(verifyOpts))
+}
+
+func TestWriteCheckpointValidation(t *testing.T) {
+	arch := GetTestMockArchive()
+	opts := &CommandOptions{}
+	data, _ := makeTestCheckpoint(t, arch, 127, Hash{})
+
+	assert.EqualError(t, arch.WriteCheckpoint(128, data, opts),
+		"ledger 128 is not a checkpoint ledger")
+	assert.EqualError(t, arch.WriteCheckpoint(191, data, opts),
+		"invalid data for checkpoint 0x000000bf: ledger header 0 has sequence 64, expected 128")
+
+	short := data
+	short.LedgerHeaders = data.LedgerHeaders[1:]
+	assert.EqualError(t, arch.WriteCheckpoint(127, short, opts),
+		"invalid data for checkpoint 0x0000007f: expected 64 ledger headers, got 63")
+
+	txs := data
+	txs.Transactions = []xdr.TransactionHistoryEntry{{LedgerSeq: 100}, {LedgerSeq: 100}}
+	assert.EqualError(t, arch.WriteCheckpoint(127, txs, opts),
+		"invalid data for checkpoint 0x0000007f: transactions entries are not in ascending ledger order")
+
+	results := data
+	results.Results = []xdr.TransactionHistoryResultEntry{{LedgerSeq: 10}}
+	assert.EqualError(t, arch.WriteCheckpoint(127, results, opts),
+		"invalid data for checkpoint 0x0000007f: results entry for ledger 10 is outside of checkpoint range [64, 127]")
+
+	missing := data
+	missing.State.CurrentBuckets[1].Curr = EmptyXdrArrayHash().String()
+	assert.EqualError(t, arch.WriteCheckpoint(127, missing, opts),
+		"bucket "+EmptyXdrArrayHash().String()+" referenced by HAS is missing")
+
+	// Nothing was written.
+	exists, err := arch.CategoryCheckpointExists("ledger", 127)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, arch.WriteCheckpoint(127, data, &CommandOptions{DryRun: true}))
+	exists, err = arch.CategoryCheckpointExists("ledger", 127)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
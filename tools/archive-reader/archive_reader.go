@@ -3,70 +3,76 @@ package main
 import (
 	"flag"
 	"fmt"
-	stdio "io"
 	"log"
+	"os"
 
 	"github.com/stellar/go/exp/ingest/adapters"
 	"github.com/stellar/go/exp/ingest/io"
 	"github.com/stellar/go/support/historyarchive"
 )
 
+const usage = `Usage: archive-reader -ledger LEDGER [flags] QUERY
+
+Queries the ledger state of a checkpoint from its bucket list. QUERY is one of:
+
+  count                         number of entries of every type
+  account ACCOUNT               the account entry
+  trustline ACCOUNT ASSET       the trust line of the account to the asset
+  offer SELLER OFFER_ID         the offer entry
+  data ACCOUNT NAME             the data entry
+  trustlines ASSET              all trust lines to the asset
+
+Assets are "native" or "CODE:ISSUER".
+
+Flags:
+`
+
 func main() {
 	ledgerPtr := flag.Uint64("ledger", 0, "`ledger to analyze` (tip: has to be of the form `ledger = 64*n - 1`, where n is > 0)")
+	archiveURL := flag.String("archive", "s3://history.stellar.org/prd/core-live/core_live_001/", "history archive to read")
+	s3Region := flag.String("s3-region", "eu-west-1", "S3 region of the archive")
+	format := flag.String("format", "json", "output format: json or csv")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 	var seqNum uint32 = uint32(*ledgerPtr)
 
-	if seqNum == 0 {
+	if seqNum == 0 || flag.NArg() == 0 {
 		flag.Usage()
-		return
+		os.Exit(2)
+	}
+	if *format != "json" && *format != "csv" {
+		log.Fatalf("unknown output format: %s", *format)
 	}
 
-	archive, e := archive()
+	archive, e := archive(*archiveURL, *s3Region)
 	if e != nil {
-		panic(e)
+		log.Fatal(e)
 	}
 	haa := adapters.MakeHistoryArchiveAdapter(archive)
 
 	sr, e := haa.GetState(seqNum, &io.MemoryTempSet{}, 0)
 	if e != nil {
-		panic(e)
+		log.Fatal(e)
 	}
+	defer sr.Close()
 
-	accounts := map[string]bool{}
-	var i uint64 = 0
-	var count uint64 = 0
-	for {
-		le, e := sr.Read()
-		if e != nil {
-			panic(e)
-		}
-		if e == stdio.EOF {
-			log.Printf("total seen %d entries of which %d were accounts", i, count)
-			return
-		}
-
-		if ae, valid := le.State.Data.GetAccount(); valid {
-			addr := ae.AccountId.Address()
-			if _, exists := accounts[addr]; exists {
-				log.Fatalf("error, total seen %d entries of which %d were unique accounts; repeated account: %s", i, count, addr)
-			}
-
-			accounts[addr] = true
-			count += 1
-		}
-		i += 1
-
-		if i%1000 == 0 {
-			log.Printf("seen %d entries of which %d were accounts", i, count)
-		}
+	records, e := runQuery(sr, flag.Args())
+	if e != nil {
+		log.Fatal(e)
+	}
+	if e = writeRecords(os.Stdout, *format, records); e != nil {
+		log.Fatal(e)
 	}
 }
 
-func archive() (*historyarchive.Archive, error) {
+func archive(url string, s3Region string) (*historyarchive.Archive, error) {
 	return historyarchive.Connect(
-		fmt.Sprintf("s3://history.stellar.org/prd/core-live/core_live_001/"),
+		url,
 		historyarchive.ConnectOptions{
-			S3Region:         "eu-west-1",
+			S3Region:         s3Region,
 			UnsignedRequests: true,
 		},
 	)
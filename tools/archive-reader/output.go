@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	stdio "io"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// writeRecords writes records to out in format, "csv" or "json". CSV output
+// starts with the header of the first record, so all records must have the
+// same columns.
+func writeRecords(out stdio.Writer, format string, records []record) error {
+	switch format {
+	case "csv":
+		w := csv.NewWriter(out)
+		if len(records) > 0 {
+			if err := w.Write(records[0].header); err != nil {
+				return err
+			}
+		}
+		for _, r := range records {
+			if err := w.Write(r.values); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	case "json":
+		objects := make([]map[string]string, 0, len(records))
+		for _, r := range records {
+			object := make(map[string]string, len(r.header))
+			for i, name := range r.header {
+				object[name] = r.values[i]
+			}
+			objects = append(objects, object)
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objects)
+	default:
+		return errors.Errorf("unknown output format: %s", format)
+	}
+}
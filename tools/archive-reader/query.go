@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/base64"
+	stdio "io"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/exp/ingest/io"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// forEachEntry calls fn with every ledger entry read from sr until fn returns
+// false or there are no more entries.
+func forEachEntry(sr io.StateReader, fn func(entry xdr.LedgerEntry) bool) error {
+	for {
+		change, err := sr.Read()
+		if err == stdio.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error reading state")
+		}
+		if change.State == nil {
+			continue
+		}
+		if !fn(*change.State) {
+			return nil
+		}
+	}
+}
+
+// findEntry returns the ledger entry with the given key, or nil if there is
+// no such entry in the state.
+func findEntry(sr io.StateReader, key xdr.LedgerKey) (*xdr.LedgerEntry, error) {
+	var found *xdr.LedgerEntry
+	err := forEachEntry(sr, func(entry xdr.LedgerEntry) bool {
+		if entry.Data.Type != key.Type {
+			return true
+		}
+		if entryKey := entry.LedgerKey(); entryKey.Equals(key) {
+			found = &entry
+			return false
+		}
+		return true
+	})
+	return found, err
+}
+
+// countEntries returns the number of ledger entries of every type.
+func countEntries(sr io.StateReader) (map[xdr.LedgerEntryType]int, error) {
+	counts := map[xdr.LedgerEntryType]int{}
+	err := forEachEntry(sr, func(entry xdr.LedgerEntry) bool {
+		counts[entry.Data.Type]++
+		return true
+	})
+	return counts, err
+}
+
+// assetTrustlines returns all the trust lines to asset.
+func assetTrustlines(sr io.StateReader, asset xdr.Asset) ([]xdr.LedgerEntry, error) {
+	var entries []xdr.LedgerEntry
+	err := forEachEntry(sr, func(entry xdr.LedgerEntry) bool {
+		if tl, ok := entry.Data.GetTrustLine(); ok && tl.Asset.Equals(asset) {
+			entries = append(entries, entry)
+		}
+		return true
+	})
+	return entries, err
+}
+
+// entryTypeName returns the name of typ as in "trustline".
+func entryTypeName(typ xdr.LedgerEntryType) string {
+	return strings.ToLower(strings.TrimPrefix(typ.String(), "LedgerEntryType"))
+}
+
+// assetString formats asset as accepted by xdr.BuildAssets: "native" or
+// "CODE:ISSUER".
+func assetString(asset xdr.Asset) string {
+	var typ, code, issuer string
+	asset.MustExtract(&typ, &code, &issuer)
+	if asset.Type == xdr.AssetTypeAssetTypeNative {
+		return typ
+	}
+	return code + ":" + issuer
+}
+
+// record is a row of the query results, with the column names in header.
+type record struct {
+	header []string
+	values []string
+}
+
+// entryRecord flattens entry into a record with a column per field.
+func entryRecord(entry xdr.LedgerEntry) record {
+	lastModified := strconv.FormatUint(uint64(entry.LastModifiedLedgerSeq), 10)
+	switch entry.Data.Type {
+	case xdr.LedgerEntryTypeAccount:
+		account := entry.Data.MustAccount()
+		inflationDest := ""
+		if account.InflationDest != nil {
+			inflationDest = account.InflationDest.Address()
+		}
+		return record{
+			header: []string{"account_id", "balance", "seq_num", "num_subentries",
+				"inflation_dest", "flags", "home_domain", "last_modified_ledger"},
+			values: []string{
+				account.AccountId.Address(),
+				amount.String(account.Balance),
+				strconv.FormatInt(int64(account.SeqNum), 10),
+				strconv.FormatUint(uint64(account.NumSubEntries), 10),
+				inflationDest,
+				strconv.FormatUint(uint64(account.Flags), 10),
+				string(account.HomeDomain),
+				lastModified,
+			},
+		}
+	case xdr.LedgerEntryTypeTrustline:
+		tl := entry.Data.MustTrustLine()
+		return record{
+			header: []string{"account_id", "asset", "balance", "limit", "flags", "last_modified_ledger"},
+			values: []string{
+				tl.AccountId.Address(),
+				assetString(tl.Asset),
+				amount.String(tl.Balance),
+				amount.String(tl.Limit),
+				strconv.FormatUint(uint64(tl.Flags), 10),
+				lastModified,
+			},
+		}
+	case xdr.LedgerEntryTypeOffer:
+		offer := entry.Data.MustOffer()
+		return record{
+			header: []string{"seller_id", "offer_id", "selling", "buying", "amount", "price",
+				"flags", "last_modified_ledger"},
+			values: []string{
+				offer.SellerId.Address(),
+				strconv.FormatInt(int64(offer.OfferId), 10),
+				assetString(offer.Selling),
+				assetString(offer.Buying),
+				amount.String(offer.Amount),
+				offer.Price.String(),
+				strconv.FormatUint(uint64(offer.Flags), 10),
+				lastModified,
+			},
+		}
+	case xdr.LedgerEntryTypeData:
+		data := entry.Data.MustData()
+		return record{
+			header: []string{"account_id", "name", "value", "last_modified_ledger"},
+			values: []string{
+				data.AccountId.Address(),
+				string(data.DataName),
+				base64.StdEncoding.EncodeToString(data.DataValue),
+				lastModified,
+			},
+		}
+	default:
+		return record{
+			header: []string{"type", "last_modified_ledger"},
+			values: []string{entryTypeName(entry.Data.Type), lastModified},
+		}
+	}
+}
+
+// parseAsset parses a single asset as accepted by xdr.BuildAssets.
+func parseAsset(s string) (xdr.Asset, error) {
+	assets, err := xdr.BuildAssets(s)
+	if err != nil {
+		return xdr.Asset{}, err
+	}
+	if len(assets) != 1 {
+		return xdr.Asset{}, errors.Errorf("invalid asset: %s", s)
+	}
+	return assets[0], nil
+}
+
+// parseKey returns the key of the entry looked up by the query args, as in
+// "trustline GABC... USD:GDEF...".
+func parseKey(args []string) (xdr.LedgerKey, error) {
+	var key xdr.LedgerKey
+	expected := map[string]int{"account": 2, "trustline": 3, "offer": 3, "data": 3}
+	if n, ok := expected[args[0]]; !ok || len(args) != n {
+		return key, errors.Errorf("invalid query: %s", strings.Join(args, " "))
+	}
+	account, err := xdr.AddressToAccountId(args[1])
+	if err != nil {
+		return key, errors.Wrapf(err, "invalid account %s", args[1])
+	}
+
+	switch args[0] {
+	case "account":
+		err = key.SetAccount(account)
+	case "trustline":
+		var asset xdr.Asset
+		if asset, err = parseAsset(args[2]); err == nil {
+			err = key.SetTrustline(account, asset)
+		}
+	case "offer":
+		var id uint64
+		if id, err = strconv.ParseUint(args[2], 10, 64); err == nil {
+			err = key.SetOffer(account, id)
+		}
+	case "data":
+		err = key.SetData(account, args[2])
+	}
+	return key, err
+}
+
+// runQuery runs the query args against the state read from sr and returns
+// its results. Queries are:
+//
+//	count                         number of entries of every type
+//	account ACCOUNT               the account entry
+//	trustline ACCOUNT ASSET       the trust line of the account to the asset
+//	offer SELLER OFFER_ID         the offer entry
+//	data ACCOUNT NAME             the data entry
+//	trustlines ASSET              all trust lines to the asset
+//
+// Assets are "native" or "CODE:ISSUER".
+func runQuery(sr io.StateReader, args []string) ([]record, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no query given")
+	}
+
+	switch args[0] {
+	case "count":
+		if len(args) != 1 {
+			return nil, errors.Errorf("invalid query: %s", strings.Join(args, " "))
+		}
+		counts, err := countEntries(sr)
+		if err != nil {
+			return nil, err
+		}
+		var records []record
+		for _, typ := range []xdr.LedgerEntryType{
+			xdr.LedgerEntryTypeAccount,
+			xdr.LedgerEntryTypeTrustline,
+			xdr.LedgerEntryTypeOffer,
+			xdr.LedgerEntryTypeData,
+		} {
+			records = append(records, record{
+				header: []string{"type", "count"},
+				values: []string{entryTypeName(typ), strconv.Itoa(counts[typ])},
+			})
+		}
+		return records, nil
+	case "trustlines":
+		if len(args) != 2 {
+			return nil, errors.Errorf("invalid query: %s", strings.Join(args, " "))
+		}
+		asset, err := parseAsset(args[1])
+		if err != nil {
+			return nil, err
+		}
+		entries, err := assetTrustlines(sr, asset)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]record, 0, len(entries))
+		for _, entry := range entries {
+			records = append(records, entryRecord(entry))
+		}
+		return records, nil
+	default:
+		key, err := parseKey(args)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := findEntry(sr, key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, errors.Errorf("entry not found: %s", strings.Join(args, " "))
+		}
+		return []record{entryRecord(*entry)}, nil
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	stdio "io"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceStateReader is a StateReader reading entries from a slice.
+type sliceStateReader struct {
+	entries []xdr.LedgerEntry
+}
+
+func (r *sliceStateReader) GetSequence() uint32 { return 63 }
+
+func (r *sliceStateReader) Read() (xdr.LedgerEntryChange, error) {
+	if len(r.entries) == 0 {
+		return xdr.LedgerEntryChange{}, stdio.EOF
+	}
+	entry := r.entries[0]
+	r.entries = r.entries[1:]
+	return xdr.LedgerEntryChange{
+		Type:  xdr.LedgerEntryChangeTypeLedgerEntryState,
+		State: &entry,
+	}, nil
+}
+
+func (r *sliceStateReader) Close() error { return nil }
+
+const (
+	testAccount = "GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB"
+	testIssuer  = "GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"
+)
+
+func testState() *sliceStateReader {
+	usd := xdr.MustNewCreditAsset("USD", testIssuer)
+	return &sliceStateReader{entries: []xdr.LedgerEntry{
+		{
+			LastModifiedLedgerSeq: 10,
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeAccount,
+				Account: &xdr.AccountEntry{
+					AccountId:  xdr.MustAddress(testAccount),
+					Balance:    100000000,
+					SeqNum:     42,
+					HomeDomain: "example.com",
+				},
+			},
+		},
+		{
+			LastModifiedLedgerSeq: 11,
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeTrustline,
+				TrustLine: &xdr.TrustLineEntry{
+					AccountId: xdr.MustAddress(testAccount),
+					Asset:     usd,
+					Balance:   50000000,
+					Limit:     1000000000,
+					Flags:     1,
+				},
+			},
+		},
+		{
+			LastModifiedLedgerSeq: 12,
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeTrustline,
+				TrustLine: &xdr.TrustLineEntry{
+					AccountId: xdr.MustAddress(testIssuer),
+					Asset:     xdr.MustNewCreditAsset("EUR", testAccount),
+					Limit:     1000000000,
+				},
+			},
+		},
+		{
+			LastModifiedLedgerSeq: 13,
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeOffer,
+				Offer: &xdr.OfferEntry{
+					SellerId: xdr.MustAddress(testAccount),
+					OfferId:  7,
+					Selling:  xdr.MustNewNativeAsset(),
+					Buying:   usd,
+					Amount:   10000000,
+					Price:    xdr.Price{N: 1, D: 2},
+				},
+			},
+		},
+		{
+			LastModifiedLedgerSeq: 14,
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeData,
+				Data: &xdr.DataEntry{
+					AccountId: xdr.MustAddress(testAccount),
+					DataName:  "name",
+					DataValue: xdr.DataValue("value"),
+				},
+			},
+		},
+	}}
+}
+
+func TestRunQuery(t *testing.T) {
+	records, err := runQuery(testState(), []string{"count"})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, writeRecords(&buf, "csv", records))
+	assert.Equal(t, "type,count\naccount,1\ntrustline,2\noffer,1\ndata,1\n", buf.String())
+
+	records, err = runQuery(testState(), []string{"account", testAccount})
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, writeRecords(&buf, "csv", records))
+	assert.Equal(t,
+		"account_id,balance,seq_num,num_subentries,inflation_dest,flags,home_domain,last_modified_ledger\n"+
+			testAccount+",10.0000000,42,0,,0,example.com,10\n",
+		buf.String())
+
+	records, err = runQuery(testState(), []string{"trustline", testAccount, "USD:" + testIssuer})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{testAccount, "USD:" + testIssuer, "5.0000000", "100.0000000", "1", "11"}, records[0].values)
+
+	records, err = runQuery(testState(), []string{"offer", testAccount, "7"})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{testAccount, "7", "native", "USD:" + testIssuer, "1.0000000", "0.5000000", "0", "13"}, records[0].values)
+
+	records, err = runQuery(testState(), []string{"data", testAccount, "name"})
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, writeRecords(&buf, "json", records))
+	assert.JSONEq(t, `[{
+		"account_id": "`+testAccount+`",
+		"name": "name",
+		"value": "dmFsdWU=",
+		"last_modified_ledger": "14"
+	}]`, buf.String())
+
+	records, err = runQuery(testState(), []string{"trustlines", "EUR:" + testAccount})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, testIssuer, records[0].values[0])
+
+	_, err = runQuery(testState(), []string{"offer", testAccount, "8"})
+	assert.EqualError(t, err, "entry not found: offer "+testAccount+" 8")
+
+	_, err = runQuery(testState(), []string{"account"})
+	assert.EqualError(t, err, "invalid query: account")
+
+	_, err = runQuery(testState(), []string{"trustline", testAccount, "USD"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/historyarchive"
+	"github.com/stellar/go/xdr"
+)
+
+// ExportOptions are the options of the export command.
+type ExportOptions struct {
+	Format            string
+	OutDir            string
+	NetworkPassphrase string
+	Accounts          []string
+	OperationTypes    []string
+	Assets            string
+}
+
+// filter parses the filter flags.
+func (eo *ExportOptions) filter() (historyarchive.ExportFilter, error) {
+	var filter historyarchive.ExportFilter
+	for _, address := range eo.Accounts {
+		account, err := xdr.AddressToAccountId(address)
+		if err != nil {
+			return filter, errors.Wrapf(err, "invalid account %s", address)
+		}
+		filter.Accounts = append(filter.Accounts, account)
+	}
+	for _, name := range eo.OperationTypes {
+		typ, err := parseOperationType(name)
+		if err != nil {
+			return filter, err
+		}
+		filter.OperationTypes = append(filter.OperationTypes, typ)
+	}
+	assets, err := xdr.BuildAssets(eo.Assets)
+	if err != nil {
+		return filter, errors.Wrap(err, "invalid assets")
+	}
+	filter.Assets = assets
+	return filter, nil
+}
+
+// normalizeOperationType reduces "payment", "PAYMENT" and
+// "OperationTypePayment" to the same string.
+func normalizeOperationType(name string) string {
+	name = strings.TrimPrefix(name, "OperationType")
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+// parseOperationType returns the operation type named name, as in
+// "path_payment_strict_send" or "OperationTypePathPaymentStrictSend".
+func parseOperationType(name string) (xdr.OperationType, error) {
+	var typ xdr.OperationType
+	for v := int32(0); typ.ValidEnum(v); v++ {
+		if normalizeOperationType(xdr.OperationType(v).String()) == normalizeOperationType(name) {
+			return xdr.OperationType(v), nil
+		}
+	}
+	return typ, fmt.Errorf("unknown operation type: %s", name)
+}
+
+// exportWriter returns the writer for the export format and a function
+// closing the files it writes to.
+func (eo *ExportOptions) exportWriter() (historyarchive.ExportWriter, func() error, error) {
+	switch eo.Format {
+	case "json":
+		return historyarchive.NewJSONExportWriter(os.Stdout), func() error { return nil }, nil
+	case "xdr":
+		if err := os.MkdirAll(eo.OutDir, 0755); err != nil {
+			return nil, nil, err
+		}
+		var files []*os.File
+		closeAll := func() error {
+			var err error
+			for _, f := range files {
+				if e := f.Close(); e != nil && err == nil {
+					err = e
+				}
+			}
+			return err
+		}
+		for _, name := range []string{"ledger.xdr", "transactions.xdr", "results.xdr"} {
+			f, err := os.Create(filepath.Join(eo.OutDir, name))
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			files = append(files, f)
+		}
+		return historyarchive.NewXdrExportWriter(files[0], files[1], files[2]), closeAll, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown export format: %s", eo.Format)
+	}
+}
+
+func export(a string, opts *Options, eo *ExportOptions, m *metrics) error {
+	filter, err := eo.filter()
+	if err != nil {
+		return err
+	}
+	arch, err := historyarchive.Connect(a, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
+	state, err := arch.GetRootHAS()
+	if err != nil {
+		return errors.Wrap(err, "Error getting HAS")
+	}
+	low, high := uint32(opts.Low), opts.High
+	if opts.Last != -1 {
+		low = 0
+		if state.CurrentLedger > uint32(opts.Last) {
+			low = state.CurrentLedger - uint32(opts.Last)
+		}
+		high = state.CurrentLedger
+	}
+	if low == 0 {
+		low = 1
+	}
+	if high > state.CurrentLedger {
+		high = state.CurrentLedger
+	}
+
+	w, closeWriter, err := eo.exportWriter()
+	if err != nil {
+		return err
+	}
+	log.Printf("exporting ledgers [%d, %d] from %v\n", low, high, a)
+	stats, err := arch.Export(context.Background(), historyarchive.ExportOptions{
+		Low:               low,
+		High:              high,
+		NetworkPassphrase: eo.NetworkPassphrase,
+		Filter:            filter,
+	}, w)
+	if cerr := closeWriter(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	m.gauge("exported_ledgers", "Number of ledgers written by export.",
+		float64(stats.Ledgers), "archive", a)
+	m.gauge("exported_transactions", "Number of transactions written by export.",
+		float64(stats.Transactions), "archive", a)
+	log.Printf("Exported %d ledgers and %d transactions", stats.Ledgers, stats.Transactions)
+	return nil
+}
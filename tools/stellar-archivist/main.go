@@ -5,48 +5,76 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/stellar/go/network"
 	"github.com/stellar/go/support/errors"
 	"github.com/stellar/go/support/historyarchive"
 )
 
-func status(a string, opts *Options) {
-	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
+func status(a string, opts *Options, m *metrics) error {
+	arch, err := historyarchive.Connect(a, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
 	state, err := arch.GetRootHAS()
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "Error getting HAS"))
+		return errors.Wrap(err, "Error getting HAS")
 	}
 	buckets, err := state.Buckets()
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "Error getting buckets"))
+		return errors.Wrap(err, "Error getting buckets")
 	}
 	summ, nz, err := state.LevelSummary()
 	if err != nil {
-		log.Fatal(errors.Wrap(err, "Error getting level summary"))
+		return errors.Wrap(err, "Error getting level summary")
+	}
+	m.gauge("latest_checkpoint", "Current ledger of the root HAS of the archive.",
+		float64(state.CurrentLedger), "archive", a)
+	newest := ""
+	if len(buckets) > 0 {
+		newest = buckets[0].String()
+	}
+
+	if opts.JSONOutput() {
+		return printJSON(struct {
+			Archive        string `json:"archive"`
+			Server         string `json:"server"`
+			CurrentLedger  uint32 `json:"current_ledger"`
+			CurrentBuckets string `json:"current_buckets"`
+			NonzeroLevels  int    `json:"nonzero_levels"`
+			NewestBucket   string `json:"newest_bucket"`
+		}{a, state.Server, state.CurrentLedger, summ, nz, newest})
 	}
 	fmt.Printf("\n")
 	fmt.Printf("       Archive: %s\n", a)
 	fmt.Printf("        Server: %s\n", state.Server)
 	fmt.Printf(" CurrentLedger: %d (0x%8.8x)\n", state.CurrentLedger, state.CurrentLedger)
 	fmt.Printf("CurrentBuckets: %s (%d nonzero levels)\n", summ, nz)
-	fmt.Printf(" Newest bucket: %s\n", buckets[0])
+	fmt.Printf(" Newest bucket: %s\n", newest)
 	fmt.Printf("\n")
+	return nil
 }
 
 type Options struct {
-	Low         int
-	High        uint32
-	Last        int
-	Recent      bool
-	Profile     bool
-	CommandOpts historyarchive.CommandOptions
-	ConnectOpts historyarchive.ConnectOptions
+	Low          int
+	High         uint32
+	Last         int
+	Recent       bool
+	SinceLastRun bool
+	StateFile    string
+	Output       string
+	MetricsFile  string
+	Profile      bool
+	CommandOpts  historyarchive.CommandOptions
+	ConnectOpts  historyarchive.ConnectOptions
 }
 
 func (opts *Options) SetRange(srcArch *historyarchive.Archive, dstArch *historyarchive.Archive) {
@@ -89,6 +117,47 @@ func (opts *Options) SetRange(srcArch *historyarchive.Archive, dstArch *historya
 
 }
 
+// JSONOutput returns true if commands should print JSON to stdout.
+func (opts *Options) JSONOutput() bool {
+	return opts.Output == "json"
+}
+
+// Run runs the command and, if --metrics-file was given, writes its metrics
+// along with the duration and outcome of the run. It exits on errors.
+func (opts *Options) Run(command string, archive string, fn func(m *metrics) error) {
+	if opts.Output != "text" && opts.Output != "json" {
+		log.Fatalf("unknown output format: %s", opts.Output)
+	}
+	start := time.Now()
+	m := &metrics{}
+	err := fn(m)
+
+	if opts.MetricsFile != "" {
+		success := 1.0
+		if err != nil {
+			success = 0
+		}
+		m.gauge("last_run_success", "Whether the last run of the command succeeded.",
+			success, "command", command, "archive", archive)
+		m.gauge("last_run_duration_seconds", "Duration of the last run of the command.",
+			time.Since(start).Seconds(), "command", command, "archive", archive)
+		m.gauge("last_run_timestamp_seconds", "Time the last run of the command finished.",
+			float64(time.Now().Unix()), "command", command, "archive", archive)
+		if werr := m.write(opts.MetricsFile); werr != nil {
+			log.Print(errors.Wrap(werr, "Error writing metrics file"))
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
 func (opts *Options) MaybeProfile() {
 	if opts.Profile {
 		go func() {
@@ -97,49 +166,214 @@ func (opts *Options) MaybeProfile() {
 	}
 }
 
-func logArchive(a string, opts *Options) {
-	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
+func logArchive(a string, opts *Options, m *metrics) error {
+	arch, err := historyarchive.Connect(a, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
 	opts.SetRange(arch, nil)
-	arch.Log(&opts.CommandOpts)
+	if !opts.JSONOutput() {
+		return arch.Log(&opts.CommandOpts)
+	}
+	entries := []historyarchive.LogEntry{}
+	err = arch.ForEachLogEntry(&opts.CommandOpts, func(entry historyarchive.LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return printJSON(entries)
 }
 
-func scan(a string, opts *Options) {
-	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
+func scan(a string, opts *Options, m *metrics) error {
+	arch, err := historyarchive.Connect(a, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
 	opts.SetRange(arch, nil)
+	if state, err := arch.GetRootHAS(); err == nil {
+		m.gauge("latest_checkpoint", "Current ledger of the root HAS of the archive.",
+			float64(state.CurrentLedger), "archive", a)
+	}
 	e1 := arch.Scan(&opts.CommandOpts)
 	e2 := arch.ReportMissing(&opts.CommandOpts)
 	e3 := arch.ReportInvalid(&opts.CommandOpts)
+
+	summary := arch.ScanSummary(&opts.CommandOpts)
+	for cat, missing := range summary.MissingCheckpointFiles {
+		m.gauge("missing_checkpoint_files", "Number of checkpoint files missing from the archive.",
+			float64(len(missing)), "archive", a, "category", cat)
+	}
+	m.gauge("missing_buckets", "Number of buckets referenced by the archive and missing from it.",
+		float64(len(summary.MissingBuckets)), "archive", a)
+	m.gauge("missing", "Number of checkpoint files and buckets missing from the archive.",
+		float64(summary.MissingCount()), "archive", a)
+	m.gauge("buckets", "Number of buckets in the archive.",
+		float64(summary.Buckets), "archive", a)
+	if opts.CommandOpts.Verify {
+		m.gauge("invalid", "Number of objects of the archive with unexpected hashes.",
+			float64(summary.InvalidCount()), "archive", a)
+	}
+	if opts.JSONOutput() {
+		err := printJSON(struct {
+			Archive string `json:"archive"`
+			historyarchive.ScanSummary
+		}{a, summary})
+		if err != nil {
+			return err
+		}
+	}
+
 	if e1 != nil {
-		log.Fatal(e1)
+		return e1
 	}
 	if e2 != nil {
-		log.Fatal(e2)
+		return e2
 	}
-	if e3 != nil {
-		log.Fatal(e3)
+	return e3
+}
+
+func verify(a string, opts *Options, m *metrics) error {
+	arch, err := historyarchive.Connect(a, opts.ConnectOpts)
+	if err != nil {
+		return err
 	}
+	opts.SetRange(arch, nil)
+	report, err := arch.VerifyRange(&opts.CommandOpts)
+	if err != nil {
+		return err
+	}
+	m.gauge("verify_issues", "Number of inconsistencies found by verify.",
+		float64(len(report.Issues)), "archive", a)
+	m.gauge("verified_ledgers", "Number of ledgers checked by verify.",
+		float64(report.Ledgers), "archive", a)
+	if opts.JSONOutput() {
+		if err = printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+	}
+	if !report.OK() {
+		return fmt.Errorf("Found %d inconsistencies in %d checkpoints", len(report.Issues), report.Checkpoints)
+	}
+	log.Printf("Verified %d ledgers in %d checkpoints", report.Ledgers, report.Checkpoints)
+	return nil
+}
+
+// copyResult is the JSON output of mirror and repair.
+type copyResult struct {
+	Src    string               `json:"src"`
+	Dst    string               `json:"dst"`
+	Range  historyarchive.Range `json:"range"`
+	DryRun bool                 `json:"dry_run"`
 }
 
-func mirror(src string, dst string, opts *Options) {
-	srcArch := historyarchive.MustConnect(src, opts.ConnectOpts)
-	dstArch := historyarchive.MustConnect(dst, opts.ConnectOpts)
+// connectDestination connects to an archive which is written to or compared
+// against. Destinations are never cached: the cache would report files which
+// are missing or different in the destination.
+func connectDestination(dst string, opts *Options) (*historyarchive.Archive, error) {
+	connectOpts := opts.ConnectOpts
+	connectOpts.CacheDir = ""
+	return historyarchive.Connect(dst, connectOpts)
+}
+
+func mirror(src string, dst string, opts *Options, m *metrics) error {
+	srcArch, err := historyarchive.Connect(src, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
+	dstArch, err := connectDestination(dst, opts)
+	if err != nil {
+		return err
+	}
 	opts.SetRange(srcArch, dstArch)
+	if opts.SinceLastRun {
+		upToDate, err := opts.SetRangeSinceLastRun(srcArch, src, dst)
+		if err != nil {
+			return err
+		}
+		if upToDate {
+			log.Printf("%v is up to date with %v\n", dst, src)
+			if opts.JSONOutput() {
+				return printJSON(copyResult{Src: src, Dst: dst, DryRun: opts.CommandOpts.DryRun})
+			}
+			return nil
+		}
+	}
 	log.Printf("mirroring %v -> %v\n", src, dst)
-	e := historyarchive.Mirror(srcArch, dstArch, &opts.CommandOpts)
-	if e != nil {
-		log.Fatal(e)
+	if err = historyarchive.Mirror(srcArch, dstArch, &opts.CommandOpts); err != nil {
+		return err
+	}
+	m.gauge("mirrored_checkpoint", "Last checkpoint copied by mirror.",
+		float64(opts.CommandOpts.Range.High), "src", src, "dst", dst)
+	if opts.SinceLastRun && !opts.CommandOpts.DryRun {
+		state := mirrorState{Src: src, Dst: dst, LastCheckpoint: opts.CommandOpts.Range.High}
+		if err := state.save(opts.StateFile); err != nil {
+			return errors.Wrap(err, "Error saving mirror state")
+		}
+	}
+	if opts.JSONOutput() {
+		return printJSON(copyResult{src, dst, opts.CommandOpts.Range, opts.CommandOpts.DryRun})
+	}
+	return nil
+}
+
+func diff(src string, dst string, opts *Options, m *metrics) error {
+	srcArch, err := historyarchive.Connect(src, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
+	dstArch, err := connectDestination(dst, opts)
+	if err != nil {
+		return err
+	}
+	opts.SetRange(srcArch, nil)
+	log.Printf("comparing %v -> %v\n", src, dst)
+	report, err := historyarchive.Diff(srcArch, dstArch, &opts.CommandOpts)
+	if err != nil {
+		return err
+	}
+	m.gauge("diff_differences", "Number of differences found by diff.",
+		float64(len(report.Differences)), "src", src, "dst", dst)
+	if opts.JSONOutput() {
+		if err = printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range report.Differences {
+			fmt.Printf("%-8s %s %s\n", d.Kind, d.Path, d.Message)
+		}
+	}
+	if !report.OK() {
+		return fmt.Errorf("Found %d differences in %d files and %d buckets",
+			len(report.Differences), report.Files, report.Buckets)
 	}
+	log.Printf("Compared %d files and %d buckets", report.Files, report.Buckets)
+	return nil
 }
 
-func repair(src string, dst string, opts *Options) {
-	srcArch := historyarchive.MustConnect(src, opts.ConnectOpts)
-	dstArch := historyarchive.MustConnect(dst, opts.ConnectOpts)
+func repair(src string, dst string, opts *Options, m *metrics) error {
+	srcArch, err := historyarchive.Connect(src, opts.ConnectOpts)
+	if err != nil {
+		return err
+	}
+	dstArch, err := connectDestination(dst, opts)
+	if err != nil {
+		return err
+	}
 	opts.SetRange(srcArch, dstArch)
 	log.Printf("repairing %v -> %v\n", src, dst)
-	e := historyarchive.Repair(srcArch, dstArch, &opts.CommandOpts)
-	if e != nil {
-		log.Fatal(e)
+	if err = historyarchive.Repair(srcArch, dstArch, &opts.CommandOpts); err != nil {
+		return err
+	}
+	if opts.JSONOutput() {
+		return printJSON(copyResult{src, dst, opts.CommandOpts.Range, opts.CommandOpts.DryRun})
 	}
+	return nil
 }
 
 func main() {
@@ -184,6 +418,20 @@ func main() {
 		"number of recent ledgers to act on",
 	)
 
+	rootCmd.PersistentFlags().BoolVar(
+		&opts.SinceLastRun,
+		"since-last-run",
+		false,
+		"mirror only the checkpoints published since the last run recorded in the state file",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.StateFile,
+		"state-file",
+		"stellar-archivist-state.json",
+		"file recording the last mirrored checkpoint for --since-last-run",
+	)
+
 	rootCmd.PersistentFlags().IntVarP(
 		&opts.CommandOpts.Concurrency,
 		"concurrency",
@@ -206,6 +454,41 @@ func main() {
 		"S3 endpoint to use",
 	)
 
+	rootCmd.PersistentFlags().StringVar(
+		&opts.ConnectOpts.GCSEndpoint,
+		"gcs-endpoint",
+		"",
+		"Google Cloud Storage endpoint to use",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.ConnectOpts.GCSAccessToken,
+		"gcs-access-token",
+		"",
+		"OAuth2 access token for Google Cloud Storage (default $GCS_ACCESS_TOKEN)",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.ConnectOpts.AzureEndpoint,
+		"azure-endpoint",
+		"",
+		"Azure Blob Storage endpoint to use",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.ConnectOpts.CacheDir,
+		"cache-dir",
+		"",
+		"directory to cache buckets and checkpoint files downloaded from source archives in",
+	)
+
+	rootCmd.PersistentFlags().Int64Var(
+		&opts.ConnectOpts.CacheSize,
+		"cache-size",
+		0,
+		"size limit of the cache in bytes, 0 means no limit",
+	)
+
 	rootCmd.PersistentFlags().BoolVarP(
 		&opts.CommandOpts.DryRun,
 		"dryrun",
@@ -236,6 +519,21 @@ func main() {
 		"decode and re-encode all buckets",
 	)
 
+	rootCmd.PersistentFlags().StringVarP(
+		&opts.Output,
+		"output",
+		"o",
+		"text",
+		"output format of commands: text or json",
+	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&opts.MetricsFile,
+		"metrics-file",
+		"",
+		"write metrics of the command to this file in the Prometheus text format, for the node_exporter textfile collector",
+	)
+
 	rootCmd.PersistentFlags().BoolVar(
 		&opts.Profile,
 		"profile",
@@ -246,7 +544,10 @@ func main() {
 	rootCmd.AddCommand(&cobra.Command{
 		Use: "status",
 		Run: func(cmd *cobra.Command, args []string) {
-			status(firstArg(args), &opts)
+			a := firstArg(args)
+			opts.Run("status", a, func(m *metrics) error {
+				return status(a, &opts, m)
+			})
 		},
 	})
 
@@ -254,7 +555,10 @@ func main() {
 		Use: "log",
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.MaybeProfile()
-			logArchive(firstArg(args), &opts)
+			a := firstArg(args)
+			opts.Run("log", a, func(m *metrics) error {
+				return logArchive(a, &opts, m)
+			})
 		},
 	})
 
@@ -262,7 +566,21 @@ func main() {
 		Use: "scan",
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.MaybeProfile()
-			scan(firstArg(args), &opts)
+			a := firstArg(args)
+			opts.Run("scan", a, func(m *metrics) error {
+				return scan(a, &opts, m)
+			})
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "verify",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.MaybeProfile()
+			a := firstArg(args)
+			opts.Run("verify", a, func(m *metrics) error {
+				return verify(a, &opts, m)
+			})
 		},
 	})
 
@@ -271,7 +589,20 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.MaybeProfile()
 			src, dst := srcDst(args)
-			mirror(src, dst, &opts)
+			opts.Run("mirror", dst, func(m *metrics) error {
+				return mirror(src, dst, &opts, m)
+			})
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use: "diff",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.MaybeProfile()
+			src, dst := srcDst(args)
+			opts.Run("diff", dst, func(m *metrics) error {
+				return diff(src, dst, &opts, m)
+			})
 		},
 	})
 
@@ -280,10 +611,68 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.MaybeProfile()
 			src, dst := srcDst(args)
-			repair(src, dst, &opts)
+			opts.Run("repair", dst, func(m *metrics) error {
+				return repair(src, dst, &opts, m)
+			})
 		},
 	})
 
+	var exportOpts ExportOptions
+	exportCmd := &cobra.Command{
+		Use: "export",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.MaybeProfile()
+			a := firstArg(args)
+			opts.Run("export", a, func(m *metrics) error {
+				return export(a, &opts, &exportOpts, m)
+			})
+		},
+	}
+
+	exportCmd.Flags().StringVar(
+		&exportOpts.Format,
+		"format",
+		"json",
+		"export format: json, newline-delimited to stdout, or xdr, length-prefixed to files in --out-dir",
+	)
+
+	exportCmd.Flags().StringVar(
+		&exportOpts.OutDir,
+		"out-dir",
+		".",
+		"directory to write ledger.xdr, transactions.xdr and results.xdr to with --format xdr",
+	)
+
+	exportCmd.Flags().StringVar(
+		&exportOpts.NetworkPassphrase,
+		"network-passphrase",
+		network.PublicNetworkPassphrase,
+		"passphrase of the network of the archive",
+	)
+
+	exportCmd.Flags().StringSliceVar(
+		&exportOpts.Accounts,
+		"account",
+		nil,
+		"export only transactions involving one of these accounts",
+	)
+
+	exportCmd.Flags().StringSliceVar(
+		&exportOpts.OperationTypes,
+		"operation-type",
+		nil,
+		"export only transactions with an operation of one of these types, as in path_payment_strict_send",
+	)
+
+	exportCmd.Flags().StringVar(
+		&exportOpts.Assets,
+		"asset",
+		"",
+		"export only transactions involving one of these assets, as in native,USD:GABC...",
+	)
+
+	rootCmd.AddCommand(exportCmd)
+
 	rootCmd.AddCommand(&cobra.Command{
 		Use: "dumpxdr",
 		Run: func(cmd *cobra.Command, args []string) {
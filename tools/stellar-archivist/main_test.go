@@ -5,9 +5,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
 	"github.com/stellar/go/support/historyarchive"
+	"github.com/stellar/go/xdr"
 	"github.com/stretchr/testify/assert"
-	"testing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLastOption(t *testing.T) {
@@ -45,3 +51,106 @@ func TestRecentOption(t *testing.T) {
 	assert.Equal(t, uint32(0x3f), opts.CommandOpts.Range.Low)
 	assert.Equal(t, uint32(0xbf), opts.CommandOpts.Range.High)
 }
+
+func TestSinceLastRunOption(t *testing.T) {
+	src_arch := historyarchive.MustConnect("mock://test1", historyarchive.ConnectOptions{})
+	var src_has historyarchive.HistoryArchiveState
+	src_has.CurrentLedger = uint32(0xbf)
+	src_arch.PutRootHAS(src_has, &historyarchive.CommandOptions{Force: true})
+
+	dir, err := ioutil.TempDir("", "stellar-archivist")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var opts Options
+	opts.StateFile = filepath.Join(dir, "state.json")
+	opts.CommandOpts.Range = historyarchive.MakeRange(0, 0xffffffff)
+
+	// Without state the range is left untouched.
+	upToDate, err := opts.SetRangeSinceLastRun(src_arch, "mock://test1", "mock://test2")
+	require.NoError(t, err)
+	assert.False(t, upToDate)
+	assert.Equal(t, historyarchive.MakeRange(0, 0xffffffff), opts.CommandOpts.Range)
+
+	state := mirrorState{Src: "mock://test1", Dst: "mock://test2", LastCheckpoint: 0x3f}
+	require.NoError(t, state.save(opts.StateFile))
+	loaded, err := loadMirrorState(opts.StateFile)
+	require.NoError(t, err)
+	assert.Equal(t, state, *loaded)
+
+	upToDate, err = opts.SetRangeSinceLastRun(src_arch, "mock://test1", "mock://test2")
+	require.NoError(t, err)
+	assert.False(t, upToDate)
+	assert.Equal(t, uint32(0x7f), opts.CommandOpts.Range.Low)
+	assert.Equal(t, uint32(0xbf), opts.CommandOpts.Range.High)
+
+	// State recorded for another pair of archives is ignored.
+	opts.CommandOpts.Range = historyarchive.MakeRange(0, 0xffffffff)
+	upToDate, err = opts.SetRangeSinceLastRun(src_arch, "mock://test1", "mock://test3")
+	require.NoError(t, err)
+	assert.False(t, upToDate)
+	assert.Equal(t, historyarchive.MakeRange(0, 0xffffffff), opts.CommandOpts.Range)
+
+	state.LastCheckpoint = 0xbf
+	require.NoError(t, state.save(opts.StateFile))
+	upToDate, err = opts.SetRangeSinceLastRun(src_arch, "mock://test1", "mock://test2")
+	require.NoError(t, err)
+	assert.True(t, upToDate)
+}
+
+func TestMetricsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stellar-archivist")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	pth := filepath.Join(dir, "archivist.prom")
+
+	m := &metrics{}
+	m.gauge("missing_checkpoint_files", "Number of missing files.", 3, "archive", "mock://test", "category", "ledger")
+	m.gauge("missing_checkpoint_files", "Number of missing files.", 0, "archive", "mock://test", "category", "results")
+	m.gauge("latest_checkpoint", "Latest checkpoint.", 0x3bf, "archive", `a"b\c`)
+	m.gauge("last_run_duration_seconds", "Duration.", 1.5)
+	require.NoError(t, m.write(pth))
+
+	buf, err := ioutil.ReadFile(pth)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP stellar_archivist_missing_checkpoint_files Number of missing files.
+# TYPE stellar_archivist_missing_checkpoint_files gauge
+stellar_archivist_missing_checkpoint_files{archive="mock://test",category="ledger"} 3
+stellar_archivist_missing_checkpoint_files{archive="mock://test",category="results"} 0
+# HELP stellar_archivist_latest_checkpoint Latest checkpoint.
+# TYPE stellar_archivist_latest_checkpoint gauge
+stellar_archivist_latest_checkpoint{archive="a\"b\\c"} 959
+# HELP stellar_archivist_last_run_duration_seconds Duration.
+# TYPE stellar_archivist_last_run_duration_seconds gauge
+stellar_archivist_last_run_duration_seconds 1.5
+`, string(buf))
+
+	info, err := os.Stat(pth)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestExportFilterOptions(t *testing.T) {
+	for _, name := range []string{"path_payment_strict_send", "PATH_PAYMENT_STRICT_SEND", "OperationTypePathPaymentStrictSend"} {
+		typ, err := parseOperationType(name)
+		require.NoError(t, err)
+		assert.Equal(t, xdr.OperationTypePathPaymentStrictSend, typ)
+	}
+	_, err := parseOperationType("pay")
+	assert.EqualError(t, err, "unknown operation type: pay")
+
+	eo := ExportOptions{
+		Accounts:       []string{"GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB"},
+		OperationTypes: []string{"create_account", "payment"},
+		Assets:         "native",
+	}
+	filter, err := eo.filter()
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.AccountId{xdr.MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB")}, filter.Accounts)
+	assert.Equal(t, []xdr.OperationType{xdr.OperationTypeCreateAccount, xdr.OperationTypePayment}, filter.OperationTypes)
+	assert.Equal(t, []xdr.Asset{xdr.MustNewNativeAsset()}, filter.Assets)
+
+	eo.Accounts = []string{"GABC"}
+	_, err = eo.filter()
+	assert.Error(t, err)
+}
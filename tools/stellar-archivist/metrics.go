@@ -0,0 +1,84 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const metricsPrefix = "stellar_archivist_"
+
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+// metrics collects gauges to be written in the Prometheus text format, to be
+// picked up by the textfile collector of node_exporter.
+type metrics struct {
+	families []*metricFamily
+}
+
+// gauge adds a sample of the gauge name. labels are pairs of label names and
+// values.
+func (m *metrics) gauge(name string, help string, value float64, labels ...string) {
+	name = metricsPrefix + name
+	var family *metricFamily
+	for _, f := range m.families {
+		if f.name == name {
+			family = f
+			break
+		}
+	}
+	if family == nil {
+		family = &metricFamily{name: name, help: help}
+		m.families = append(m.families, family)
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", labels[i], quoteLabelValue(labels[i+1])))
+	}
+	sample := metricSample{value: value}
+	if len(pairs) > 0 {
+		sample.labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+	family.samples = append(family.samples, sample)
+}
+
+// quoteLabelValue escapes a label value as required by the text format.
+func quoteLabelValue(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	return `"` + v + `"`
+}
+
+func (m *metrics) bytes() []byte {
+	var buf bytes.Buffer
+	for _, f := range m.families {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", f.name)
+		for _, s := range f.samples {
+			fmt.Fprintf(&buf, "%s%s %s\n", f.name, s.labels,
+				strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+	return buf.Bytes()
+}
+
+// write writes the metrics to pth.
+func (m *metrics) write(pth string) error {
+	return writeFileAtomic(pth, m.bytes())
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Stellar Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/files"
+	"github.com/stellar/go/support/historyarchive"
+)
+
+// mirrorState is recorded by `mirror --since-last-run` so that the next run
+// only copies the checkpoints published since, without scanning the
+// destination archive.
+type mirrorState struct {
+	Src            string `json:"src"`
+	Dst            string `json:"dst"`
+	LastCheckpoint uint32 `json:"last_checkpoint"`
+}
+
+// loadMirrorState reads the state file at pth. It returns nil if the file
+// doesn't exist.
+func loadMirrorState(pth string) (*mirrorState, error) {
+	buf, err := ioutil.ReadFile(pth)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state mirrorState
+	if err = json.Unmarshal(buf, &state); err != nil {
+		return nil, errors.Wrapf(err, "invalid state file %s", pth)
+	}
+	return &state, nil
+}
+
+// save writes the state to pth.
+func (s mirrorState) save(pth string) error {
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(pth, buf)
+}
+
+// writeFileAtomic writes buf to pth, see files.WriteAtomic. The file is
+// readable by everyone so it can be picked up by other tools.
+func writeFileAtomic(pth string, buf []byte) error {
+	return files.WriteAtomic(pth, 0644, func(w io.Writer) error {
+		_, err := w.Write(buf)
+		return err
+	})
+}
+
+// SetRangeSinceLastRun narrows the range to the checkpoints of srcArch
+// published after the last checkpoint mirrored from src to dst, as recorded
+// in the state file. The range is left untouched if there is no state for
+// src and dst. It returns true if there is nothing new to mirror.
+func (opts *Options) SetRangeSinceLastRun(srcArch *historyarchive.Archive, src string, dst string) (bool, error) {
+	state, err := loadMirrorState(opts.StateFile)
+	if err != nil {
+		return false, errors.Wrap(err, "Error loading mirror state")
+	}
+	if state == nil || state.Src != src || state.Dst != dst {
+		return false, nil
+	}
+	has, err := srcArch.GetRootHAS()
+	if err != nil {
+		return false, errors.Wrap(err, "Error getting HAS")
+	}
+	if has.CurrentLedger <= state.LastCheckpoint {
+		return true, nil
+	}
+	opts.CommandOpts.Range = historyarchive.MakeRange(
+		historyarchive.NextCheckpoint(state.LastCheckpoint+1), has.CurrentLedger)
+	return false, nil
+}
@@ -2,13 +2,11 @@ package commands
 
 import (
 	"encoding/hex"
-	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/stellar/go/exp/crypto/derivation"
-	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/support/errors"
 	"github.com/tyler-smith/go-bip39"
 )
@@ -61,18 +59,13 @@ var AccountsCmd = &cobra.Command{
 
 		println("")
 
-		for i := uint32(startID); i < startID+count; i++ {
-			key, err := masterKey.Derive(derivation.FirstHardenedIndex + i)
-			if err != nil {
-				return errors.Wrap(err, "Error deriving child key")
-			}
-
-			kp, err := keypair.FromRawSeed(key.RawSeed())
-			if err != nil {
-				return errors.Wrap(err, "Error creating key pair")
-			}
+		accounts, err := deriveAccounts(mnemonic, password, startID, count)
+		if err != nil {
+			return err
+		}
 
-			println(fmt.Sprintf(derivation.StellarAccountPathFormat, i), kp.Address(), kp.Seed())
+		for _, account := range accounts {
+			println(account.Path, account.PublicKey, account.SecretKey)
 		}
 
 		return nil
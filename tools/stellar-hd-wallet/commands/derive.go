@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/exp/crypto/derivation"
+	"github.com/stellar/go/exp/crypto/keystore"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/support/errors"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	mnemonicEnvVar         = "STELLAR_HD_WALLET_MNEMONIC"
+	passphraseEnvVar       = "STELLAR_HD_WALLET_PASSPHRASE"
+	keystorePasswordEnvVar = "STELLAR_HD_WALLET_KEYSTORE_PASSWORD"
+)
+
+var (
+	mnemonicFile, passphraseFile, keystorePasswordFile string
+	deriveFormat                                       string
+	deriveCount, deriveStartID                         uint32
+)
+
+var DeriveCmd = &cobra.Command{
+	Use:   "derive",
+	Short: "Derive accounts non-interactively from an existing mnemonic code",
+	Long: "Derives accounts from a mnemonic code and passphrase read from files or the " +
+		mnemonicEnvVar + " and " + passphraseEnvVar + " environment variables. " +
+		"Supported output formats are text, json, csv and keystore. The keystore format " +
+		"is a keysBlob accepted by the keystore service, encrypted with a password read " +
+		"from --keystore-password-file or " + keystorePasswordEnvVar + ".",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mnemonic, passphrase, err := readMnemonic()
+		if err != nil {
+			return err
+		}
+
+		accounts, err := deriveAccounts(mnemonic, passphrase, deriveStartID, deriveCount)
+		if err != nil {
+			return err
+		}
+
+		switch deriveFormat {
+		case "text":
+			for _, account := range accounts {
+				println(account.Path, account.PublicKey, account.SecretKey)
+			}
+			return nil
+		case "json":
+			return writeJSON(accounts)
+		case "csv":
+			return writeCSV(accounts)
+		case "keystore":
+			password, err := readSecret(keystorePasswordFile, keystorePasswordEnvVar)
+			if err != nil {
+				return errors.Wrap(err, "Error reading keystore password")
+			}
+			return writeKeystore(accounts, password)
+		default:
+			return errors.Errorf("Invalid format %s, allowed values: text, json, csv, keystore", deriveFormat)
+		}
+	},
+}
+
+func init() {
+	DeriveCmd.Flags().StringVar(&mnemonicFile, "mnemonic-file", "", "file to read the mnemonic code from (default $"+mnemonicEnvVar+")")
+	DeriveCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file to read the passphrase from (default $"+passphraseEnvVar+")")
+	DeriveCmd.Flags().StringVar(&keystorePasswordFile, "keystore-password-file", "", "file to read the keystore encryption password from (default $"+keystorePasswordEnvVar+")")
+	DeriveCmd.Flags().StringVarP(&deriveFormat, "format", "f", "text", "output format: text, json, csv or keystore")
+	DeriveCmd.Flags().Uint32VarP(&deriveCount, "count", "c", 10, "number of accounts to derive")
+	DeriveCmd.Flags().Uint32VarP(&deriveStartID, "start", "s", 0, "ID of the first account to derive")
+}
+
+// account is a key pair derived from a mnemonic code.
+type account struct {
+	Index     uint32 `json:"index"`
+	Path      string `json:"path"`
+	PublicKey string `json:"public_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// deriveAccounts derives count accounts starting at startID from mnemonic
+// and passphrase. Account IDs must be lower than 2^31 because they are
+// derived as hardened keys.
+func deriveAccounts(mnemonic, passphrase string, startID, count uint32) ([]account, error) {
+	if uint64(startID)+uint64(count) > uint64(derivation.FirstHardenedIndex) {
+		return nil, errors.Errorf("Account IDs must be lower than %d", derivation.FirstHardenedIndex)
+	}
+
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, errors.New("Invalid words or checksum")
+	}
+
+	masterKey, err := derivation.DeriveForPath(derivation.StellarAccountPrefix, seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error deriving master key")
+	}
+
+	accounts := make([]account, 0, count)
+	for i := startID; i < startID+count; i++ {
+		key, err := masterKey.Derive(derivation.FirstHardenedIndex + i)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error deriving child key")
+		}
+
+		kp, err := keypair.FromRawSeed(key.RawSeed())
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating key pair")
+		}
+
+		accounts = append(accounts, account{
+			Index:     i,
+			Path:      fmt.Sprintf(derivation.StellarAccountPathFormat, i),
+			PublicKey: kp.Address(),
+			SecretKey: kp.Seed(),
+		})
+	}
+
+	return accounts, nil
+}
+
+// readMnemonic reads the mnemonic code and the passphrase from the files
+// passed in flags or the environment.
+func readMnemonic() (string, string, error) {
+	mnemonic, err := readSecret(mnemonicFile, mnemonicEnvVar)
+	if err != nil {
+		return "", "", errors.Wrap(err, "Error reading mnemonic")
+	}
+
+	if mnemonic == "" {
+		return "", "", errors.Errorf("Mnemonic is required, use --mnemonic-file or %s", mnemonicEnvVar)
+	}
+
+	passphrase, err := readSecret(passphraseFile, passphraseEnvVar)
+	if err != nil {
+		return "", "", errors.Wrap(err, "Error reading passphrase")
+	}
+
+	// Normalize whitespace so mnemonic files can contain one word per line.
+	return strings.Join(strings.Fields(mnemonic), " "), passphrase, nil
+}
+
+// readSecret returns the contents of file without the trailing newline or, if
+// file is empty, the value of envVar.
+func readSecret(file, envVar string) (string, error) {
+	if file == "" {
+		return os.Getenv(envVar), nil
+	}
+
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+func writeJSON(v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeCSV(accounts []account) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"index", "path", "public_key", "secret_key"}); err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		err := writer.Write([]string{
+			fmt.Sprintf("%d", account.Index),
+			account.Path,
+			account.PublicKey,
+			account.SecretKey,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeKeystore writes accounts as a keystore service PUT /keys request body.
+func writeKeystore(accounts []account, password string) error {
+	if password == "" {
+		return errors.Errorf("Keystore password is required, use --keystore-password-file or %s", keystorePasswordEnvVar)
+	}
+
+	keys := make([]keystore.EncryptedKeyData, 0, len(accounts))
+	for _, account := range accounts {
+		key, err := keystore.EncryptKey(account.PublicKey, keystore.RawKeyData{
+			KeyType:    keystore.KeyTypePlaintext,
+			PublicKey:  account.PublicKey,
+			PrivateKey: account.SecretKey,
+			Path:       account.Path,
+		}, password)
+		if err != nil {
+			return errors.Wrap(err, "Error encrypting key")
+		}
+		keys = append(keys, key)
+	}
+
+	blob, err := keystore.KeysBlob(keys)
+	if err != nil {
+		return errors.Wrap(err, "Error encoding keys blob")
+	}
+
+	return writeJSON(struct {
+		KeysBlob string `json:"keysBlob"`
+	}{blob})
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/exp/crypto/keystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMnemonic = "cable spray genius state float twenty onion head street palace net private method loan turn phrase state blanket interest dry amazing dress blast tube"
+
+func setUpMnemonic(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "stellar-hd-wallet")
+	require.NoError(t, err)
+
+	mnemonicFile = filepath.Join(dir, "mnemonic")
+	passphraseFile = filepath.Join(dir, "passphrase")
+	keystorePasswordFile = filepath.Join(dir, "keystore-password")
+	require.NoError(t, ioutil.WriteFile(mnemonicFile, []byte(testMnemonic+"\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(passphraseFile, []byte("p4ssphr4se\n"), 0600))
+	require.NoError(t, ioutil.WriteFile(keystorePasswordFile, []byte("password"), 0600))
+
+	deriveStartID = 1
+	deriveCount = 2
+	out = &bytes.Buffer{}
+
+	return func() {
+		mnemonicFile, passphraseFile, keystorePasswordFile = "", "", ""
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDeriveFormats(t *testing.T) {
+	defer setUpMnemonic(t)()
+
+	deriveFormat = "csv"
+	require.NoError(t, DeriveCmd.RunE(nil, []string{}))
+	assert.Equal(t, `index,path,public_key,secret_key
+1,m/44'/148'/1',GDY47CJARRHHL66JH3RJURDYXAMIQ5DMXZLP3TDAUJ6IN2GUOFX4OJOC,SBQPDFUGLMWJYEYXFRM5TQX3AX2BR47WKI4FDS7EJQUSEUUVY72MZPJF
+2,m/44'/148'/2',GCLAQF5H5LGJ2A6ACOMNEHSWYDJ3VKVBUBHDWFGRBEPAVZ56L4D7JJID,SAF2LXRW6FOSVQNC4HHIIDURZL4SCGCG7UEGG23ZQG6Q2DKIGMPZV6BZ
+`, out.(*bytes.Buffer).String())
+
+	out = &bytes.Buffer{}
+	deriveFormat = "json"
+	require.NoError(t, DeriveCmd.RunE(nil, []string{}))
+	var accounts []account
+	require.NoError(t, json.Unmarshal(out.(*bytes.Buffer).Bytes(), &accounts))
+	assert.Equal(t, []account{
+		{
+			Index:     1,
+			Path:      "m/44'/148'/1'",
+			PublicKey: "GDY47CJARRHHL66JH3RJURDYXAMIQ5DMXZLP3TDAUJ6IN2GUOFX4OJOC",
+			SecretKey: "SBQPDFUGLMWJYEYXFRM5TQX3AX2BR47WKI4FDS7EJQUSEUUVY72MZPJF",
+		},
+		{
+			Index:     2,
+			Path:      "m/44'/148'/2'",
+			PublicKey: "GCLAQF5H5LGJ2A6ACOMNEHSWYDJ3VKVBUBHDWFGRBEPAVZ56L4D7JJID",
+			SecretKey: "SAF2LXRW6FOSVQNC4HHIIDURZL4SCGCG7UEGG23ZQG6Q2DKIGMPZV6BZ",
+		},
+	}, accounts)
+
+	out = &bytes.Buffer{}
+	deriveFormat = "keystore"
+	require.NoError(t, DeriveCmd.RunE(nil, []string{}))
+	var request struct {
+		KeysBlob string `json:"keysBlob"`
+	}
+	require.NoError(t, json.Unmarshal(out.(*bytes.Buffer).Bytes(), &request))
+	keys, err := keystore.ParseKeysBlob(request.KeysBlob)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	raw, err := keystore.DecryptKey(keys[1], "password")
+	require.NoError(t, err)
+	assert.Equal(t, keystore.RawKeyData{
+		KeyType:    keystore.KeyTypePlaintext,
+		PublicKey:  "GCLAQF5H5LGJ2A6ACOMNEHSWYDJ3VKVBUBHDWFGRBEPAVZ56L4D7JJID",
+		PrivateKey: "SAF2LXRW6FOSVQNC4HHIIDURZL4SCGCG7UEGG23ZQG6Q2DKIGMPZV6BZ",
+		Path:       "m/44'/148'/2'",
+	}, raw)
+
+	deriveStartID = 2147483647
+	deriveCount = 2
+	assert.EqualError(t, DeriveCmd.RunE(nil, []string{}), "Account IDs must be lower than 2147483648")
+	deriveStartID = 1
+
+	deriveFormat = "xml"
+	assert.EqualError(t, DeriveCmd.RunE(nil, []string{}), "Invalid format xml, allowed values: text, json, csv, keystore")
+	deriveFormat = "text"
+}
+
+func TestVerify(t *testing.T) {
+	defer setUpMnemonic(t)()
+
+	verifyID = 3
+	verifyPublicKey = "GBC36J4KG7ZSIQ5UOSJFQNUP4IBRN6LVUFAHQWT2ODEQ7Y3ASWC5ZN3B"
+	require.NoError(t, VerifyCmd.RunE(nil, []string{}))
+	assert.Contains(t, out.(*bytes.Buffer).String(), "OK: m/44'/148'/3' GBC36J4KG7ZSIQ5UOSJFQNUP4IBRN6LVUFAHQWT2ODEQ7Y3ASWC5ZN3B")
+
+	verifyID = 4
+	err := VerifyCmd.RunE(nil, []string{})
+	assert.EqualError(t, err, "Verification failed: m/44'/148'/4' derives GA6NHA4KPH5LFYD6LZH35SIX3DU5CWU3GX6GCKPJPPTQCCQPP627E3CB, expected GBC36J4KG7ZSIQ5UOSJFQNUP4IBRN6LVUFAHQWT2ODEQ7Y3ASWC5ZN3B")
+
+	verifyID = 4294967295
+	assert.EqualError(t, VerifyCmd.RunE(nil, []string{}), "Account IDs must be lower than 2147483648")
+
+	// Wrong passphrase
+	require.NoError(t, ioutil.WriteFile(passphraseFile, []byte("wrong"), 0600))
+	verifyID = 3
+	assert.Error(t, VerifyCmd.RunE(nil, []string{}))
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/support/errors"
+)
+
+var verifyPublicKey string
+var verifyID uint32
+
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify that a mnemonic code derives an expected account",
+	Long: "Checks that the account derived from a mnemonic code and passphrase (read the " +
+		"same way as in the derive command) at a given ID matches the expected public key. " +
+		"Use it to check backups of mnemonic codes.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := keypair.ParseAddress(verifyPublicKey); err != nil {
+			return errors.New("Invalid or missing --public-key")
+		}
+
+		mnemonic, passphrase, err := readMnemonic()
+		if err != nil {
+			return err
+		}
+
+		accounts, err := deriveAccounts(mnemonic, passphrase, verifyID, 1)
+		if err != nil {
+			return err
+		}
+		if len(accounts) != 1 {
+			return errors.Errorf("Expected 1 derived account, got %d", len(accounts))
+		}
+
+		if accounts[0].PublicKey != verifyPublicKey {
+			return errors.Errorf(
+				"Verification failed: %s derives %s, expected %s",
+				accounts[0].Path, accounts[0].PublicKey, verifyPublicKey,
+			)
+		}
+
+		println("OK:", accounts[0].Path, accounts[0].PublicKey)
+		return nil
+	},
+}
+
+func init() {
+	VerifyCmd.Flags().StringVar(&mnemonicFile, "mnemonic-file", "", "file to read the mnemonic code from (default $"+mnemonicEnvVar+")")
+	VerifyCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file to read the passphrase from (default $"+passphraseEnvVar+")")
+	VerifyCmd.Flags().StringVarP(&verifyPublicKey, "public-key", "p", "", "expected public key (G...)")
+	VerifyCmd.Flags().Uint32VarP(&verifyID, "id", "i", 0, "ID of the account to derive")
+}
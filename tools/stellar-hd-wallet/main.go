@@ -15,6 +15,8 @@ var mainCmd = &cobra.Command{
 func init() {
 	mainCmd.AddCommand(commands.NewCmd)
 	mainCmd.AddCommand(commands.AccountsCmd)
+	mainCmd.AddCommand(commands.DeriveCmd)
+	mainCmd.AddCommand(commands.VerifyCmd)
 }
 
 func main() {
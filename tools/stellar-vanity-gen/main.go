@@ -1,58 +1,260 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/howeyc/gopass"
 	"github.com/stellar/go/keypair"
 )
 
-var prefix string
+// passwordEnvVar is the environment variable the output file password is read
+// from. When it's not set the password is read from the terminal.
+const passwordEnvVar = "STELLAR_VANITY_GEN_PASSWORD"
 
-const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+var (
+	prefixes, suffixes, regexps patternFlag
+
+	workers            = flag.Int("workers", runtime.NumCPU(), "number of search goroutines")
+	count              = flag.Int("count", 1, "number of keys to find for every pattern")
+	outFile            = flag.String("out", "stellar-vanity-gen.keys", "file encrypted matching keys are appended to")
+	statsFile          = flag.String("stats", "stellar-vanity-gen.stats.json", "file progress statistics are checkpointed to")
+	checkpointInterval = flag.Duration("checkpoint-interval", 30*time.Second, "how often progress statistics are checkpointed")
+)
+
+func init() {
+	flag.Var(&prefixes, "prefix", "address prefix to search for, after the leading G and the second character (can be repeated)")
+	flag.Var(&suffixes, "suffix", "address suffix to search for (can be repeated)")
+	flag.Var(&regexps, "regex", "regular expression the whole address must match (can be repeated)")
+	flag.Usage = usage
+}
 
 func main() {
+	flag.Parse()
+
+	// Support the original `stellar-vanity-gen PREFIX` form.
+	for _, arg := range flag.Args() {
+		if err := prefixes.Set(arg); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	patterns, err := buildPatterns(prefixes, suffixes, regexps)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	if len(os.Args) != 2 {
+	if len(patterns) == 0 {
 		usage()
 		os.Exit(1)
 	}
 
-	prefix = strings.ToUpper(os.Args[1])
-	checkPlausible()
+	if *workers < 1 || *count < 1 {
+		fmt.Println("-workers and -count must be positive")
+		os.Exit(1)
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := newOutput(*outFile, password)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+
+	stats, err := loadStats(*statsFile, patterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Searching with %d workers, matching keys are written to %s\n", *workers, *outFile)
+	for _, p := range patterns {
+		if attempts, ok := p.ExpectedAttempts(); ok {
+			fmt.Printf("  %-30s expected attempts: %.0f\n", p, attempts)
+		} else {
+			fmt.Printf("  %-30s expected attempts: unknown\n", p)
+		}
+	}
+
+	s := &search{
+		patterns: patterns,
+		stats:    stats,
+		out:      out,
+		found:    make([]int, len(patterns)),
+		done:     make(chan struct{}),
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run()
+		}()
+	}
 
+	ticker := time.NewTicker(*checkpointInterval)
+	defer ticker.Stop()
+
+loop:
 	for {
-		kp, err := keypair.Random()
+		select {
+		case <-ticker.C:
+			s.checkpoint()
+		case <-signals:
+			fmt.Println("Interrupted, saving progress")
+			s.stop()
+			break loop
+		case <-s.done:
+			break loop
+		}
+	}
+
+	wg.Wait()
+	s.checkpoint()
+}
+
+// search holds the state shared by all search goroutines.
+type search struct {
+	patterns []*pattern
+	stats    *stats
+	out      *output
+
+	// attempts is the number of keys generated since the start of this run.
+	attempts uint64
+	// found is the number of keys found for every pattern in this run.
+	found []int
+
+	mutex    sync.Mutex
+	done     chan struct{}
+	stopOnce sync.Once
+}
 
+func (s *search) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		kp, err := keypair.Random()
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		// NOTE: the first letter of an address will always be G, and the second letter will be one of only a few
-		// possibilities in the base32 alphabet, so we are actually searching for the vanity value after this 2
-		// character prefix.
-		if strings.HasPrefix(kp.Address()[2:], prefix) {
-			fmt.Println("Found!")
-			fmt.Printf("Secret seed: %s\n", kp.Seed())
-			fmt.Printf("Public: %s\n", kp.Address())
-			os.Exit(0)
+		atomic.AddUint64(&s.attempts, 1)
+
+		address := kp.Address()
+		for i, p := range s.patterns {
+			if p.Match(address) {
+				s.record(i, kp)
+			}
 		}
 	}
 }
 
-func usage() {
-	fmt.Printf("Usage:\n\tstellar-vanity-gen PREFIX\n")
+func (s *search) record(i int, kp *keypair.Full) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.found[i] >= *count {
+		return
+	}
+
+	if err := s.out.Write(kp, s.patterns[i].String()); err != nil {
+		log.Fatal(err)
+	}
+
+	s.found[i]++
+	s.stats.Patterns[i].Found++
+	fmt.Printf("Found %s for %s (%d/%d)\n", kp.Address(), s.patterns[i], s.found[i], *count)
+
+	for _, found := range s.found {
+		if found < *count {
+			return
+		}
+	}
+
+	s.stop()
+}
+
+func (s *search) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
 }
 
-// aborts the attempt if a desired character is not a valid base32 digit
-func checkPlausible() {
-	for _, r := range prefix {
-		if !strings.ContainsRune(alphabet, r) {
-			fmt.Printf("Invalid prefix: %s is not in the base32 alphabet\n", strconv.QuoteRune(r))
-			os.Exit(1)
+// checkpoint prints the progress so far and saves it to the stats file.
+func (s *search) checkpoint() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	attempts := atomic.LoadUint64(&s.attempts)
+	s.stats.update(attempts)
+
+	rate := s.stats.rate()
+	fmt.Printf("%d attempts in %s (%.0f keys/s)\n", s.stats.Attempts, s.stats.Elapsed(), rate)
+	for i, p := range s.patterns {
+		expected, ok := p.ExpectedAttempts()
+		if !ok || rate == 0 {
+			fmt.Printf("  %-30s found %d/%d in this run, %d in total\n", p, s.found[i], *count, s.stats.Patterns[i].Found)
+			continue
 		}
+
+		eta := time.Duration(expected / rate * float64(time.Second))
+		fmt.Printf(
+			"  %-30s found %d/%d in this run, %d in total, expected time per key: %s\n",
+			p, s.found[i], *count, s.stats.Patterns[i].Found, eta.Round(time.Second),
+		)
+	}
+
+	if err := s.stats.save(*statsFile); err != nil {
+		log.Print(err)
+	}
+}
+
+func readPassword() (string, error) {
+	if password := os.Getenv(passwordEnvVar); password != "" {
+		return password, nil
+	}
+
+	fmt.Print("Output file password: ")
+	password, err := gopass.GetPasswd()
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Print("Repeat password: ")
+	repeated, err := gopass.GetPasswd()
+	if err != nil {
+		return "", err
+	}
+
+	if string(password) != string(repeated) {
+		return "", fmt.Errorf("passwords do not match")
 	}
+
+	return string(password), nil
+}
+
+func usage() {
+	fmt.Printf("Usage:\n\tstellar-vanity-gen [options] [PREFIX...]\n\nOptions:\n")
+	flag.PrintDefaults()
+	fmt.Printf("\nThe output file password is read from %s or prompted for.\n", passwordEnvVar)
 }
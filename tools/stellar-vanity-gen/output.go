@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/stellar/go/exp/crypto/keystore"
+	"github.com/stellar/go/keypair"
+)
+
+// output appends matching keys to a file, one JSON encoded
+// keystore.EncryptedKeyData per line. The keys can be decrypted with the
+// password using keystore.DecryptKey.
+type output struct {
+	file     *os.File
+	password string
+}
+
+func newOutput(path, password string) (*output, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &output{file: file, password: password}, nil
+}
+
+// Write encrypts kp and appends it to the output file. The matched pattern is
+// stored in the `extra` field of the key.
+func (o *output) Write(kp *keypair.Full, pattern string) error {
+	key, err := keystore.EncryptKey(kp.Address(), keystore.RawKeyData{
+		KeyType:    keystore.KeyTypePlaintext,
+		PublicKey:  kp.Address(),
+		PrivateKey: kp.Seed(),
+		Extra:      map[string]string{"pattern": pattern},
+	}, o.password)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := o.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return o.file.Sync()
+}
+
+func (o *output) Close() error {
+	return o.file.Close()
+}
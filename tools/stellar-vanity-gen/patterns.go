@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+// addressLength is the length of a strkey encoded account ID.
+const addressLength = 56
+
+// prefixOffset is the number of leading address characters that are skipped
+// when matching prefixes.
+//
+// NOTE: the first letter of an address will always be G, and the second letter
+// will be one of only a few possibilities in the base32 alphabet, so we are
+// actually searching for the vanity value after this 2 character prefix.
+const prefixOffset = 2
+
+// patternFlag is a flag.Value that can be set multiple times.
+type patternFlag []string
+
+func (f *patternFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *patternFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+type patternKind int
+
+const (
+	prefixPattern patternKind = iota
+	suffixPattern
+	regexPattern
+)
+
+// pattern is a single search criterion for an address.
+type pattern struct {
+	kind  patternKind
+	value string
+	re    *regexp.Regexp
+}
+
+func buildPatterns(prefixes, suffixes, regexps []string) ([]*pattern, error) {
+	var patterns []*pattern
+
+	for _, prefix := range prefixes {
+		p, err := newAffixPattern(prefixPattern, prefix)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+
+	for _, suffix := range suffixes {
+		p, err := newAffixPattern(suffixPattern, suffix)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+
+	for _, expr := range regexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regex %s: %v", expr, err)
+		}
+		patterns = append(patterns, &pattern{kind: regexPattern, value: expr, re: re})
+	}
+
+	return patterns, nil
+}
+
+// newAffixPattern aborts the attempt if a desired character is not a valid
+// base32 digit or the value can never fit in an address.
+func newAffixPattern(kind patternKind, value string) (*pattern, error) {
+	value = strings.ToUpper(value)
+	for _, r := range value {
+		if !strings.ContainsRune(alphabet, r) {
+			return nil, fmt.Errorf("Invalid pattern %s: %s is not in the base32 alphabet", value, strconv.QuoteRune(r))
+		}
+	}
+
+	if value == "" || len(value) > addressLength-prefixOffset {
+		return nil, fmt.Errorf("Invalid pattern %q: length must be between 1 and %d", value, addressLength-prefixOffset)
+	}
+
+	return &pattern{kind: kind, value: value}, nil
+}
+
+// Match returns true if address satisfies the pattern.
+func (p *pattern) Match(address string) bool {
+	switch p.kind {
+	case prefixPattern:
+		return strings.HasPrefix(address[prefixOffset:], p.value)
+	case suffixPattern:
+		return strings.HasSuffix(address, p.value)
+	default:
+		return p.re.MatchString(address)
+	}
+}
+
+// ExpectedAttempts returns the expected number of random keys that need to be
+// generated to find a single match. The second return value is false if it
+// can't be estimated, which is the case for regular expressions.
+//
+// Every base32 character after the prefix offset carries 5 bits of uniformly
+// distributed key or checksum data, so every matched character divides the
+// probability of a match by 32.
+func (p *pattern) ExpectedAttempts() (float64, bool) {
+	if p.kind == regexPattern {
+		return 0, false
+	}
+
+	return math.Pow(float64(len(alphabet)), float64(len(p.value))), true
+}
+
+func (p *pattern) String() string {
+	switch p.kind {
+	case prefixPattern:
+		return "prefix:" + p.value
+	case suffixPattern:
+		return "suffix:" + p.value
+	default:
+		return "regex:" + p.value
+	}
+}
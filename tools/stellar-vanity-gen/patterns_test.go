@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatterns(t *testing.T) {
+	address := "GB3JDWCQJCWMJ3IILWIGDTQJJC5567PGVEVXSCVPEQOTDN64VJBDQBYX"
+
+	patterns, err := buildPatterns(
+		[]string{"3jd", "GB3"},
+		[]string{"bYx"},
+		[]string{"^G.*QBYX$", "STELLAR"},
+	)
+	require.NoError(t, err)
+	require.Len(t, patterns, 5)
+
+	assert.True(t, patterns[0].Match(address))
+	assert.False(t, patterns[1].Match(address))
+	assert.True(t, patterns[2].Match(address))
+	assert.True(t, patterns[3].Match(address))
+	assert.False(t, patterns[4].Match(address))
+
+	assert.Equal(t, "prefix:3JD", patterns[0].String())
+	assert.Equal(t, "suffix:BYX", patterns[2].String())
+	assert.Equal(t, "regex:^G.*QBYX$", patterns[3].String())
+
+	expected, ok := patterns[0].ExpectedAttempts()
+	assert.True(t, ok)
+	assert.Equal(t, float64(32*32*32), expected)
+
+	_, ok = patterns[3].ExpectedAttempts()
+	assert.False(t, ok)
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	_, err := buildPatterns([]string{"AB1"}, nil, nil)
+	assert.EqualError(t, err, "Invalid pattern AB1: '1' is not in the base32 alphabet")
+
+	_, err = buildPatterns(nil, []string{""}, nil)
+	assert.Error(t, err)
+
+	_, err = buildPatterns(nil, nil, []string{"("})
+	assert.Error(t, err)
+}
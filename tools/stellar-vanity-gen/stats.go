@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/stellar/go/support/files"
+)
+
+// stats are the progress statistics checkpointed to the stats file. They are
+// accumulated over all runs using the same stats file.
+type stats struct {
+	Attempts uint64         `json:"attempts"`
+	Seconds  float64        `json:"seconds"`
+	Patterns []patternStats `json:"patterns"`
+	Updated  time.Time      `json:"updated"`
+
+	// baseAttempts and baseSeconds are the values loaded from the stats file
+	// before this run started.
+	baseAttempts uint64
+	baseSeconds  float64
+	started      time.Time
+}
+
+type patternStats struct {
+	Pattern          string   `json:"pattern"`
+	ExpectedAttempts *float64 `json:"expected_attempts,omitempty"`
+	// Found is the number of keys found in all runs. The -count limit
+	// applies to keys found in a single run.
+	Found int `json:"found"`
+}
+
+// loadStats reads the stats saved at path and resets the statistics if
+// they were collected for a different set of patterns.
+func loadStats(path string, patterns []*pattern) (*stats, error) {
+	s := &stats{}
+
+	contents, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(contents, s); err != nil {
+			return nil, err
+		}
+	}
+
+	if !s.matches(patterns) {
+		s = &stats{}
+		for _, p := range patterns {
+			ps := patternStats{Pattern: p.String()}
+			if expected, ok := p.ExpectedAttempts(); ok {
+				ps.ExpectedAttempts = &expected
+			}
+			s.Patterns = append(s.Patterns, ps)
+		}
+	}
+
+	s.baseAttempts = s.Attempts
+	s.baseSeconds = s.Seconds
+	s.started = time.Now()
+	return s, nil
+}
+
+func (s *stats) matches(patterns []*pattern) bool {
+	if len(s.Patterns) != len(patterns) {
+		return false
+	}
+
+	for i, p := range patterns {
+		if s.Patterns[i].Pattern != p.String() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// update sets the totals given the number of attempts made in this run.
+func (s *stats) update(attempts uint64) {
+	s.Attempts = s.baseAttempts + attempts
+	s.Seconds = s.baseSeconds + time.Since(s.started).Seconds()
+	s.Updated = time.Now()
+}
+
+// Elapsed returns the total time spent searching.
+func (s *stats) Elapsed() time.Duration {
+	return time.Duration(s.Seconds * float64(time.Second)).Round(time.Second)
+}
+
+// rate returns the number of keys generated per second.
+func (s *stats) rate() float64 {
+	if s.Seconds == 0 {
+		return 0
+	}
+	return float64(s.Attempts) / s.Seconds
+}
+
+// save atomically writes the stats to path.
+func (s *stats) save(path string) error {
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return files.WriteAtomic(path, 0644, func(w io.Writer) error {
+		_, err := w.Write(contents)
+		return err
+	})
+}
@@ -0,0 +1,678 @@
+package xdr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// MarshalJSON encodes v, an XDR value or a pointer to one, to canonical
+// JSON:
+//
+//   - structs are objects with a field per struct field, named as in the XDR
+//     definition (the Go field name with a lowercase first letter),
+//   - unions are objects with the discriminant, named after the switch field,
+//     and the arm selected by it, if it isn't void,
+//   - enums are the names of their values, as in "OperationTypePayment",
+//   - 64-bit integers are strings, other integers are numbers,
+//   - fixed-length opaques are hex strings and variable-length opaques are
+//     base64 strings,
+//   - account IDs, node IDs and signer keys are strkeys, assets are "native"
+//     or "CODE:ISSUER" and asset codes are strings,
+//   - optional values are null when absent.
+//
+// Strings and asset codes that can't be represented losslessly, such as
+// strings that aren't valid UTF-8, are encoded as {"base64": "..."} and
+// {"hex": "..."} respectively, so that UnmarshalJSON always returns a value
+// with the same binary encoding.
+func MarshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, reflect.ValueOf(v), "value"); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes JSON produced by MarshalJSON into v, which must be a
+// pointer to an XDR value. Besides the canonical encoding it accepts 64-bit
+// integers as numbers, enums as integers and unions for assets, account IDs
+// and signer keys. Struct fields missing from the JSON are left zero, unknown
+// fields are errors.
+func UnmarshalJSON(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("xdr: UnmarshalJSON requires a non-nil pointer")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var parsed interface{}
+	if err := dec.Decode(&parsed); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("xdr: unexpected data after JSON value")
+	}
+	return decodeJSON(parsed, rv.Elem(), "value")
+}
+
+// xdrUnion is implemented by the generated union types.
+type xdrUnion interface {
+	SwitchFieldName() string
+	ArmForSwitch(sw int32) (string, bool)
+}
+
+// xdrEnum is implemented by the generated enum types.
+type xdrEnum interface {
+	ValidEnum(v int32) bool
+}
+
+var (
+	unionType       = reflect.TypeOf((*xdrUnion)(nil)).Elem()
+	enumType        = reflect.TypeOf((*xdrEnum)(nil)).Elem()
+	accountIDType   = reflect.TypeOf(AccountId{})
+	nodeIDType      = reflect.TypeOf(NodeId{})
+	publicKeyType   = reflect.TypeOf(PublicKey{})
+	signerKeyType   = reflect.TypeOf(SignerKey{})
+	assetType       = reflect.TypeOf(Asset{})
+	assetCode4Type  = reflect.TypeOf(AssetCode4{})
+	assetCode12Type = reflect.TypeOf(AssetCode12{})
+)
+
+// jsonEnumNames maps the enum types to the names of their values. It must
+// list every enum of xdr_generated.go.
+var jsonEnumNames = map[reflect.Type]map[int32]string{
+	reflect.TypeOf(ScpStatementType(0)):                   scpStatementTypeMap,
+	reflect.TypeOf(AssetType(0)):                          assetTypeMap,
+	reflect.TypeOf(ThresholdIndexes(0)):                   thresholdIndexesMap,
+	reflect.TypeOf(LedgerEntryType(0)):                    ledgerEntryTypeMap,
+	reflect.TypeOf(AccountFlags(0)):                       accountFlagsMap,
+	reflect.TypeOf(TrustLineFlags(0)):                     trustLineFlagsMap,
+	reflect.TypeOf(OfferEntryFlags(0)):                    offerEntryFlagsMap,
+	reflect.TypeOf(EnvelopeType(0)):                       envelopeTypeMap,
+	reflect.TypeOf(StellarValueType(0)):                   stellarValueTypeMap,
+	reflect.TypeOf(LedgerUpgradeType(0)):                  ledgerUpgradeTypeMap,
+	reflect.TypeOf(BucketEntryType(0)):                    bucketEntryTypeMap,
+	reflect.TypeOf(LedgerEntryChangeType(0)):              ledgerEntryChangeTypeMap,
+	reflect.TypeOf(ErrorCode(0)):                          errorCodeMap,
+	reflect.TypeOf(IpAddrType(0)):                         ipAddrTypeMap,
+	reflect.TypeOf(MessageType(0)):                        messageTypeMap,
+	reflect.TypeOf(OperationType(0)):                      operationTypeMap,
+	reflect.TypeOf(MemoType(0)):                           memoTypeMap,
+	reflect.TypeOf(CreateAccountResultCode(0)):            createAccountResultCodeMap,
+	reflect.TypeOf(PaymentResultCode(0)):                  paymentResultCodeMap,
+	reflect.TypeOf(PathPaymentStrictReceiveResultCode(0)): pathPaymentStrictReceiveResultCodeMap,
+	reflect.TypeOf(PathPaymentStrictSendResultCode(0)):    pathPaymentStrictSendResultCodeMap,
+	reflect.TypeOf(ManageSellOfferResultCode(0)):          manageSellOfferResultCodeMap,
+	reflect.TypeOf(ManageOfferEffect(0)):                  manageOfferEffectMap,
+	reflect.TypeOf(ManageBuyOfferResultCode(0)):           manageBuyOfferResultCodeMap,
+	reflect.TypeOf(SetOptionsResultCode(0)):               setOptionsResultCodeMap,
+	reflect.TypeOf(ChangeTrustResultCode(0)):              changeTrustResultCodeMap,
+	reflect.TypeOf(AllowTrustResultCode(0)):               allowTrustResultCodeMap,
+	reflect.TypeOf(AccountMergeResultCode(0)):             accountMergeResultCodeMap,
+	reflect.TypeOf(InflationResultCode(0)):                inflationResultCodeMap,
+	reflect.TypeOf(ManageDataResultCode(0)):               manageDataResultCodeMap,
+	reflect.TypeOf(BumpSequenceResultCode(0)):             bumpSequenceResultCodeMap,
+	reflect.TypeOf(OperationResultCode(0)):                operationResultCodeMap,
+	reflect.TypeOf(TransactionResultCode(0)):              transactionResultCodeMap,
+	reflect.TypeOf(CryptoKeyType(0)):                      cryptoKeyTypeMap,
+	reflect.TypeOf(PublicKeyType(0)):                      publicKeyTypeMap,
+	reflect.TypeOf(SignerKeyType(0)):                      signerKeyTypeMap,
+}
+
+// jsonFieldName returns the JSON name of the Go field name.
+func jsonFieldName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	buf.Write(encoded)
+	return nil
+}
+
+// jsonAssetCode returns code without its trailing zeros, if that's lossless.
+func jsonAssetCode(code []byte) (string, bool) {
+	trimmed := bytes.TrimRight(code, "\x00")
+	if len(trimmed) == 0 || bytes.IndexByte(trimmed, 0) != -1 {
+		return "", false
+	}
+	for _, c := range trimmed {
+		if c < 0x21 || c > 0x7e || c == ':' {
+			return "", false
+		}
+	}
+	return string(trimmed), true
+}
+
+// jsonAssetString returns the "CODE:ISSUER" form of asset, if it's lossless.
+func jsonAssetString(asset Asset) (string, bool) {
+	switch asset.Type {
+	case AssetTypeAssetTypeNative:
+		return "native", true
+	case AssetTypeAssetTypeCreditAlphanum4:
+		if asset.AlphaNum4 == nil {
+			return "", false
+		}
+		code, ok := jsonAssetCode(asset.AlphaNum4.AssetCode[:])
+		if !ok || !ValidAssetCode.MatchString(code) {
+			return "", false
+		}
+		issuer, err := asset.AlphaNum4.Issuer.GetAddress()
+		return code + ":" + issuer, err == nil
+	case AssetTypeAssetTypeCreditAlphanum12:
+		if asset.AlphaNum12 == nil {
+			return "", false
+		}
+		code, ok := jsonAssetCode(asset.AlphaNum12.AssetCode[:])
+		// Codes of up to 4 characters would be decoded as AlphaNum4.
+		if !ok || len(code) <= 4 || !ValidAssetCode.MatchString(code) {
+			return "", false
+		}
+		issuer, err := asset.AlphaNum12.Issuer.GetAddress()
+		return code + ":" + issuer, err == nil
+	}
+	return "", false
+}
+
+// encodeStrkey writes the strkey form of the account ID, node ID, public key
+// or signer key v, returning false if it has none.
+func encodeStrkey(buf *bytes.Buffer, v reflect.Value) (bool, error) {
+	var address string
+	var err error
+	if v.Type() == signerKeyType {
+		key := v.Interface().(SignerKey)
+		address, err = key.GetAddress()
+	} else {
+		aid := v.Convert(accountIDType).Interface().(AccountId)
+		address, err = aid.GetAddress()
+	}
+	if err != nil {
+		return false, nil
+	}
+	return true, writeJSONString(buf, address)
+}
+
+func encodeJSON(buf *bytes.Buffer, v reflect.Value, path string) error {
+	if !v.IsValid() {
+		return errors.Errorf("xdr: cannot encode %s", path)
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+
+	switch t {
+	case accountIDType, nodeIDType, publicKeyType, signerKeyType:
+		if ok, err := encodeStrkey(buf, v); ok || err != nil {
+			return err
+		}
+	case assetType:
+		if s, ok := jsonAssetString(v.Interface().(Asset)); ok {
+			return writeJSONString(buf, s)
+		}
+	case assetCode4Type, assetCode12Type:
+		code := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(code), v)
+		if s, ok := jsonAssetCode(code); ok {
+			return writeJSONString(buf, s)
+		}
+		buf.WriteString(`{"hex":`)
+		if err := writeJSONString(buf, hex.EncodeToString(code)); err != nil {
+			return err
+		}
+		buf.WriteString("}")
+		return nil
+	}
+
+	if t.Implements(enumType) {
+		names, ok := jsonEnumNames[t]
+		if !ok {
+			return errors.Errorf("xdr: %s: no names for enum %s", path, t)
+		}
+		if name, ok := names[int32(v.Int())]; ok {
+			return writeJSONString(buf, name)
+		}
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		return nil
+	}
+	if t.Implements(unionType) {
+		return encodeUnionJSON(buf, v, path)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Int64:
+		buf.WriteString(`"` + strconv.FormatInt(v.Int(), 10) + `"`)
+	case reflect.Uint64:
+		buf.WriteString(`"` + strconv.FormatUint(v.Uint(), 10) + `"`)
+	case reflect.String:
+		s := v.String()
+		if utf8.ValidString(s) {
+			return writeJSONString(buf, s)
+		}
+		buf.WriteString(`{"base64":`)
+		if err := writeJSONString(buf, base64.StdEncoding.EncodeToString([]byte(s))); err != nil {
+			return err
+		}
+		buf.WriteString("}")
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(raw), v)
+			return writeJSONString(buf, hex.EncodeToString(raw))
+		}
+		return encodeArrayJSON(buf, v, path)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return writeJSONString(buf, base64.StdEncoding.EncodeToString(v.Bytes()))
+		}
+		return encodeArrayJSON(buf, v, path)
+	case reflect.Struct:
+		buf.WriteString("{")
+		first := true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if !first {
+				buf.WriteString(",")
+			}
+			first = false
+			if err := writeJSONString(buf, jsonFieldName(field.Name)); err != nil {
+				return err
+			}
+			buf.WriteString(":")
+			if err := encodeJSON(buf, v.Field(i), path+"."+field.Name); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	default:
+		return errors.Errorf("xdr: %s: cannot encode %s", path, t)
+	}
+	return nil
+}
+
+func encodeArrayJSON(buf *bytes.Buffer, v reflect.Value, path string) error {
+	buf.WriteString("[")
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if err := encodeJSON(buf, v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("]")
+	return nil
+}
+
+// unionSwitch returns the value of the discriminant of the union v.
+func unionSwitch(v reflect.Value) (int32, reflect.Value) {
+	sw := v.FieldByName(v.Interface().(xdrUnion).SwitchFieldName())
+	switch sw.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int32(sw.Uint()), sw
+	default:
+		return int32(sw.Int()), sw
+	}
+}
+
+func encodeUnionJSON(buf *bytes.Buffer, v reflect.Value, path string) error {
+	union := v.Interface().(xdrUnion)
+	swName := union.SwitchFieldName()
+	sw, swValue := unionSwitch(v)
+	arm, ok := union.ArmForSwitch(sw)
+	if !ok {
+		return errors.Errorf("xdr: %s: invalid %s %d of %s", path, swName, sw, v.Type())
+	}
+
+	buf.WriteString("{")
+	if err := writeJSONString(buf, jsonFieldName(swName)); err != nil {
+		return err
+	}
+	buf.WriteString(":")
+	if err := encodeJSON(buf, swValue, path+"."+swName); err != nil {
+		return err
+	}
+	if arm != "" {
+		armValue := v.FieldByName(arm)
+		if armValue.Kind() == reflect.Ptr && armValue.IsNil() {
+			return errors.Errorf("xdr: %s: arm %s of %s is not set", path, arm, v.Type())
+		}
+		buf.WriteString(",")
+		if err := writeJSONString(buf, jsonFieldName(arm)); err != nil {
+			return err
+		}
+		buf.WriteString(":")
+		if err := encodeJSON(buf, armValue, path+"."+arm); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// decodeEscaped decodes the {"key": "..."} escapes of MarshalJSON.
+func decodeEscaped(data interface{}, key string, decode func(string) ([]byte, error), path string) ([]byte, error) {
+	obj, ok := data.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return nil, errors.Errorf("xdr: %s: expected a string", path)
+	}
+	s, ok := obj[key].(string)
+	if !ok {
+		return nil, errors.Errorf("xdr: %s: expected {\"%s\": string}", path, key)
+	}
+	raw, err := decode(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "xdr: %s", path)
+	}
+	return raw, nil
+}
+
+func decodeInt(data interface{}, bits int, signed bool, path string) (int64, uint64, error) {
+	var s string
+	switch d := data.(type) {
+	case json.Number:
+		s = d.String()
+	case string:
+		s = d
+	default:
+		return 0, 0, errors.Errorf("xdr: %s: expected an integer", path)
+	}
+	if signed {
+		i, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "xdr: %s", path)
+		}
+		return i, 0, nil
+	}
+	u, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "xdr: %s", path)
+	}
+	return 0, u, nil
+}
+
+func decodeJSON(data interface{}, v reflect.Value, path string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		if data == nil {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		elem := reflect.New(t.Elem())
+		if err := decodeJSON(data, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if s, ok := data.(string); ok {
+		switch t {
+		case accountIDType, nodeIDType, publicKeyType:
+			aid, err := AddressToAccountId(s)
+			if err != nil {
+				return errors.Wrapf(err, "xdr: %s", path)
+			}
+			v.Set(reflect.ValueOf(aid).Convert(t))
+			return nil
+		case signerKeyType:
+			var key SignerKey
+			if err := key.SetAddress(s); err != nil {
+				return errors.Wrapf(err, "xdr: %s", path)
+			}
+			v.Set(reflect.ValueOf(key))
+			return nil
+		case assetType:
+			assets, err := BuildAssets(s)
+			if err != nil {
+				return errors.Wrapf(err, "xdr: %s", path)
+			}
+			if len(assets) != 1 {
+				return errors.Errorf("xdr: %s: invalid asset %s", path, s)
+			}
+			v.Set(reflect.ValueOf(assets[0]))
+			return nil
+		}
+	}
+
+	switch t {
+	case assetCode4Type, assetCode12Type:
+		var raw []byte
+		if s, ok := data.(string); ok {
+			raw = []byte(s)
+		} else {
+			var err error
+			raw, err = decodeEscaped(data, "hex", hex.DecodeString, path)
+			if err != nil {
+				return err
+			}
+		}
+		if len(raw) > v.Len() {
+			return errors.Errorf("xdr: %s: asset code longer than %d bytes", path, v.Len())
+		}
+		for i := 0; i < v.Len(); i++ {
+			var c byte
+			if i < len(raw) {
+				c = raw[i]
+			}
+			v.Index(i).SetUint(uint64(c))
+		}
+		return nil
+	}
+
+	if t.Implements(enumType) {
+		if name, ok := data.(string); ok {
+			names, ok := jsonEnumNames[t]
+			if !ok {
+				return errors.Errorf("xdr: %s: no names for enum %s", path, t)
+			}
+			for value, n := range names {
+				if n == name {
+					v.SetInt(int64(value))
+					return nil
+				}
+			}
+			return errors.Errorf("xdr: %s: invalid %s %s", path, t, name)
+		}
+		i, _, err := decodeInt(data, 32, true, path)
+		if err != nil {
+			return err
+		}
+		if !v.Interface().(xdrEnum).ValidEnum(int32(i)) {
+			return errors.Errorf("xdr: %s: invalid %s %d", path, t, i)
+		}
+		v.SetInt(i)
+		return nil
+	}
+	if t.Implements(unionType) {
+		return decodeUnionJSON(data, v, path)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := data.(bool)
+		if !ok {
+			return errors.Errorf("xdr: %s: expected a boolean", path)
+		}
+		v.SetBool(b)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, ok := data.(string); ok && t.Kind() != reflect.Int64 {
+			return errors.Errorf("xdr: %s: expected a number", path)
+		}
+		i, _, err := decodeInt(data, t.Bits(), true, path)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if _, ok := data.(string); ok && t.Kind() != reflect.Uint64 {
+			return errors.Errorf("xdr: %s: expected a number", path)
+		}
+		_, u, err := decodeInt(data, t.Bits(), false, path)
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.String:
+		if s, ok := data.(string); ok {
+			v.SetString(s)
+			return nil
+		}
+		raw, err := decodeEscaped(data, "base64", base64.StdEncoding.DecodeString, path)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(raw))
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s, ok := data.(string)
+			if !ok {
+				return errors.Errorf("xdr: %s: expected a hex string", path)
+			}
+			raw, err := hex.DecodeString(s)
+			if err != nil {
+				return errors.Wrapf(err, "xdr: %s", path)
+			}
+			if len(raw) != v.Len() {
+				return errors.Errorf("xdr: %s: expected %d bytes, got %d", path, v.Len(), len(raw))
+			}
+			reflect.Copy(v, reflect.ValueOf(raw))
+			return nil
+		}
+		items, ok := data.([]interface{})
+		if !ok {
+			return errors.Errorf("xdr: %s: expected an array", path)
+		}
+		if len(items) != v.Len() {
+			return errors.Errorf("xdr: %s: expected %d elements, got %d", path, v.Len(), len(items))
+		}
+		for i, item := range items {
+			if err := decodeJSON(item, v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s, ok := data.(string)
+			if !ok {
+				return errors.Errorf("xdr: %s: expected a base64 string", path)
+			}
+			raw, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return errors.Wrapf(err, "xdr: %s", path)
+			}
+			v.SetBytes(raw)
+			return nil
+		}
+		items, ok := data.([]interface{})
+		if !ok {
+			return errors.Errorf("xdr: %s: expected an array", path)
+		}
+		// Empty arrays are nil, as when decoding from binary.
+		if len(items) == 0 {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := decodeJSON(item, slice.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Struct:
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("xdr: %s: expected an object", path)
+		}
+		seen := 0
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			item, ok := obj[jsonFieldName(field.Name)]
+			if !ok {
+				continue
+			}
+			seen++
+			if err := decodeJSON(item, v.Field(i), path+"."+field.Name); err != nil {
+				return err
+			}
+		}
+		if seen != len(obj) {
+			return errors.Errorf("xdr: %s: unknown fields for %s", path, t)
+		}
+	default:
+		return errors.Errorf("xdr: %s: cannot decode %s", path, t)
+	}
+	return nil
+}
+
+func decodeUnionJSON(data interface{}, v reflect.Value, path string) error {
+	obj, ok := data.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("xdr: %s: expected an object", path)
+	}
+	union := v.Interface().(xdrUnion)
+	swName := union.SwitchFieldName()
+	swData, ok := obj[jsonFieldName(swName)]
+	if !ok {
+		return errors.Errorf("xdr: %s: missing %s", path, jsonFieldName(swName))
+	}
+
+	// Reset the arms of the union before setting the discriminant.
+	v.Set(reflect.Zero(v.Type()))
+	if err := decodeJSON(swData, v.FieldByName(swName), path+"."+swName); err != nil {
+		return err
+	}
+	sw, _ := unionSwitch(v)
+	arm, ok := union.ArmForSwitch(sw)
+	if !ok {
+		return errors.Errorf("xdr: %s: invalid %s %d of %s", path, swName, sw, v.Type())
+	}
+	if arm == "" {
+		if len(obj) != 1 {
+			return errors.Errorf("xdr: %s: unknown fields for %s", path, v.Type())
+		}
+		return nil
+	}
+	armData, ok := obj[jsonFieldName(arm)]
+	if !ok {
+		return errors.Errorf("xdr: %s: missing %s", path, jsonFieldName(arm))
+	}
+	if len(obj) != 2 {
+		return errors.Errorf("xdr: %s: unknown fields for %s", path, v.Type())
+	}
+	armValue := v.FieldByName(arm)
+	if armValue.Kind() == reflect.Ptr && armData == nil {
+		return errors.Errorf("xdr: %s: arm %s of %s is not set", path, arm, v.Type())
+	}
+	return decodeJSON(armData, armValue, path+"."+arm)
+}
@@ -0,0 +1,167 @@
+package xdr
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertJSONRoundTrip checks that v survives encoding to JSON and back with
+// the same binary encoding, and returns its JSON.
+func assertJSONRoundTrip(t *testing.T, v interface{}) string {
+	expected, err := MarshalBase64(v)
+	require.NoError(t, err)
+
+	encoded, err := MarshalJSON(v)
+	require.NoError(t, err)
+
+	decoded := reflect.New(reflect.TypeOf(v).Elem())
+	require.NoError(t, UnmarshalJSON(encoded, decoded.Interface()), string(encoded))
+	actual, err := MarshalBase64(decoded.Interface())
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual, string(encoded))
+	return string(encoded)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var envelope TransactionEnvelope
+	require.NoError(t, SafeUnmarshalBase64("AAAAAG5oJtVdnYOVdZqtXpTHBtbcY0mCmfcBIKEgWnlvFIhaAAAAZAAAAAIAAAACAAAAAAAAAAAAAAABAAAAAAAAAAEAAAAAO2C/AO45YBD3tHVFO1R3A0MekP8JR6nN1A9eWidyItUAAAABVVNEAAAAAACuo3ot45qCPExpQ/3oHN+z17Ryis1lfMFYmQWgruS+TAAAAAB3NZQAAAAAAAAAAAFvFIhaAAAAQKcGS9OsVnVHCVIH04C9ZKzzKYBRdCmy+Jwmzld7QcALOxZUcAgkuGfoSdvXpH38mNvrqQiaMsSNmTJWYRzHvgo=", &envelope))
+	assertJSONRoundTrip(t, &envelope)
+
+	var result TransactionResult
+	require.NoError(t, SafeUnmarshalBase64("AAAAAAAAAGQAAAAAAAAAAQAAAAAAAAABAAAAAAAAAAA=", &result))
+	assertJSONRoundTrip(t, &result)
+
+	var meta TransactionMeta
+	require.NoError(t, SafeUnmarshalBase64("AAAAAAAAAAEAAAADAAAAAQAZphoAAAAAAAAAAMIK9djC7k75ziKOLJcvMAIBG7tnBuoeI34x+Pi6zqcZAAAAF0h255wAGaYWAAAAAQAAAAMAAAAAAAAAAAAAAAADBQUFAAAAAwAAAAAtkqVYLPLYhqNMmQLPc+T9eTWp8LIE8eFlR5K4wNJKTQAAAAMAAAAAynnCTTyw53VVRLOWX6XKTva63IM1LslPNW01YB0hz/8AAAADAAAAAuOwxEKY/BwUmvv0yJlvuSQnrkHkZJuTTKSVmRt4UrhVAAAAAwAAAAAAAAAAAAAAAwAZphYAAAAAAAAAAMp5wk08sOd1VUSzll+lyk72utyDNS7JTzVtNWAdIc//AAAAF0h26AAAGaYWAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAAAAAAAQAZphoAAAAAAAAAAMp5wk08sOd1VUSzll+lyk72utyDNS7JTzVtNWAdIc//AAAAGZyCzAAAGaYWAAAAAAAAAAAAAAAAAAAAAAAAAAABAAAAAAAAAAAAAAAAAAAA", &meta))
+	assertJSONRoundTrip(t, &meta)
+
+	// Values that can't use the short forms.
+	issuer := MustAddress("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H")
+	short12 := Asset{
+		Type:       AssetTypeAssetTypeCreditAlphanum12,
+		AlphaNum12: &AssetAlphaNum12{AssetCode: AssetCode12{'A', 'B'}, Issuer: issuer},
+	}
+	zeros := Asset{
+		Type:      AssetTypeAssetTypeCreditAlphanum4,
+		AlphaNum4: &AssetAlphaNum4{AssetCode: AssetCode4{'A', 0, 'B'}, Issuer: issuer},
+	}
+	entry := LedgerEntry{
+		LastModifiedLedgerSeq: 7,
+		Data: LedgerEntryData{
+			Type: LedgerEntryTypeOffer,
+			Offer: &OfferEntry{
+				SellerId: issuer,
+				OfferId:  -1,
+				Selling:  short12,
+				Buying:   zeros,
+				Amount:   9223372036854775807,
+				Price:    Price{N: 1, D: 3},
+			},
+		},
+	}
+	encoded := assertJSONRoundTrip(t, &entry)
+	assert.Contains(t, encoded, `"buying":{"type":"AssetTypeAssetTypeCreditAlphanum4","alphaNum4":{"assetCode":{"hex":"41004200"}`)
+	assert.Contains(t, encoded, `"selling":{"type":"AssetTypeAssetTypeCreditAlphanum12","alphaNum12":{"assetCode":"AB",`)
+	assert.Contains(t, encoded, `"amount":"9223372036854775807"`)
+
+	account := AccountEntry{
+		AccountId:  issuer,
+		HomeDomain: "\xff\xfe",
+		Signers:    []Signer{{Key: MustSigner("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"), Weight: 1}},
+	}
+	encoded = assertJSONRoundTrip(t, &account)
+	assert.Contains(t, encoded, `"homeDomain":{"base64":"//4="}`)
+}
+
+func TestJSONEncoding(t *testing.T) {
+	memo := Memo{Type: MemoTypeMemoId, Id: &[]Uint64{12}[0]}
+	encoded, err := MarshalJSON(memo)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"MemoTypeMemoId","id":"12"}`, string(encoded))
+
+	encoded, err = MarshalJSON(&TransactionResultPair{
+		TransactionHash: Hash{0xab},
+		Result: TransactionResult{
+			FeeCharged: 100,
+			Result:     TransactionResultResult{Code: TransactionResultCodeTxBadSeq},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"transactionHash":"ab00000000000000000000000000000000000000000000000000000000000000",`+
+		`"result":{"feeCharged":"100","result":{"code":"TransactionResultCodeTxBadSeq"},"ext":{"v":0}}}`,
+		string(encoded))
+
+	encoded, err = MarshalJSON(MustNewCreditAsset("USD", "GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"))
+	require.NoError(t, err)
+	assert.Equal(t, `"USD:GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"`, string(encoded))
+
+	// Unset union arms can't be encoded, as in binary.
+	_, err = MarshalJSON(Memo{Type: MemoTypeMemoText})
+	assert.EqualError(t, err, "xdr: value: arm Text of xdr.Memo is not set")
+}
+
+func TestJSONDecoding(t *testing.T) {
+	// Alternative forms are accepted.
+	var asset Asset
+	require.NoError(t, UnmarshalJSON([]byte(`{"type":1,"alphaNum4":{
+		"assetCode":"USD",
+		"issuer":{"type":"PublicKeyTypePublicKeyTypeEd25519","ed25519":"62fc1d0bd091b2b61c0dd656346b2a68d7d347c6f2c2c8ee6d04470256fc05f7"}
+	}}`), &asset))
+	assert.Equal(t, MustNewCreditAsset("USD", "GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"), asset)
+
+	var amount Int64
+	require.NoError(t, UnmarshalJSON([]byte(`12`), &amount))
+	assert.Equal(t, Int64(12), amount)
+
+	for _, c := range []struct {
+		json string
+		v    interface{}
+		err  string
+	}{
+		{`{"type":"MemoTypeMemoText"}`, &Memo{}, "xdr: value: missing text"},
+		{`{"type":"MemoTypeMemoNone","text":"a"}`, &Memo{}, "xdr: value: unknown fields for xdr.Memo"},
+		{`{"type":"MemoTypeMemo"}`, &Memo{}, "xdr: value.Type: invalid xdr.MemoType MemoTypeMemo"},
+		{`{"n":1,"d":2,"x":3}`, &Price{}, "xdr: value: unknown fields for xdr.Price"},
+		{`{"n":"1","d":2}`, &Price{}, "xdr: value.N: expected a number"},
+		{`"00"`, &Hash{}, "xdr: value: expected 32 bytes, got 1"},
+		{`"4294967296"`, new(Uint64), ""},
+		{`4294967296`, new(Uint32), `xdr: value: strconv.ParseUint: parsing "4294967296": value out of range`},
+		{`"USD"`, &Asset{}, "xdr: value: USD is not a valid asset"},
+		{`{} {}`, &Price{}, "xdr: unexpected data after JSON value"},
+	} {
+		err := UnmarshalJSON([]byte(c.json), c.v)
+		if c.err == "" {
+			assert.NoError(t, err, c.json)
+		} else {
+			assert.EqualError(t, err, c.err, c.json)
+		}
+	}
+}
+
+// TestJSONEnumNames checks that jsonEnumNames lists every generated enum.
+func TestJSONEnumNames(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "xdr_generated.go", nil, 0)
+	require.NoError(t, err)
+
+	var enums []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "ValidEnum" || fn.Recv == nil {
+			continue
+		}
+		enums = append(enums, fn.Recv.List[0].Type.(*ast.Ident).Name)
+	}
+	require.NotEmpty(t, enums)
+
+	var names []string
+	for typ := range jsonEnumNames {
+		names = append(names, typ.Name())
+	}
+	assert.ElementsMatch(t, enums, names)
+}
@@ -0,0 +1,288 @@
+package xdr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldChange describes a change of a single field of a ledger entry.
+type FieldChange struct {
+	// Field is a name of the changed field, ex. "balance", "flag AUTH_REQUIRED"
+	// or "signer GABC...".
+	Field string
+	// Before and After are display friendly values of the field. Before is
+	// empty when the value has been added, After when it has been removed.
+	Before string
+	After  string
+	// Summary describes the change, ex. "+50.0000000", "1→0" or "set".
+	Summary string
+}
+
+// String returns a display friendly form of the change, ex.
+// "balance +50.0000000".
+func (c FieldChange) String() string {
+	return c.Field + " " + c.Summary
+}
+
+// LedgerEntryDiff is a list of field changes between two versions of a ledger
+// entry.
+type LedgerEntryDiff []FieldChange
+
+// String returns all changes separated by commas.
+func (d LedgerEntryDiff) String() string {
+	changes := make([]string, len(d))
+	for i, change := range d {
+		changes[i] = change.String()
+	}
+	return strings.Join(changes, ", ")
+}
+
+// DiffLedgerEntries returns changes of the fields of `before` needed to get
+// `after`. Both entries must have the same ledger key.
+func DiffLedgerEntries(before, after LedgerEntry) (LedgerEntryDiff, error) {
+	beforeKey, afterKey := before.LedgerKey(), after.LedgerKey()
+	if !beforeKey.Equals(afterKey) {
+		return nil, fmt.Errorf(
+			"cannot diff entries with different keys: %s and %s",
+			LedgerKeyString(beforeKey),
+			LedgerKeyString(afterKey),
+		)
+	}
+
+	var d entryDiffer
+	switch before.Data.Type {
+	case LedgerEntryTypeAccount:
+		d.account(before.Data.MustAccount(), after.Data.MustAccount())
+	case LedgerEntryTypeTrustline:
+		d.trustLine(before.Data.MustTrustLine(), after.Data.MustTrustLine())
+	case LedgerEntryTypeOffer:
+		d.offer(before.Data.MustOffer(), after.Data.MustOffer())
+	case LedgerEntryTypeData:
+		d.data(before.Data.MustData(), after.Data.MustData())
+	default:
+		return nil, fmt.Errorf("unknown ledger entry type: %v", before.Data.Type)
+	}
+	d.uint("last_modified_ledger", uint64(before.LastModifiedLedgerSeq), uint64(after.LastModifiedLedgerSeq))
+
+	return d.diff, nil
+}
+
+// LedgerKeyString returns a display friendly form of the ledger key, ex.
+// "trustline GABC... credit_alphanum4/USD/GDEF...".
+func LedgerKeyString(key LedgerKey) string {
+	switch key.Type {
+	case LedgerEntryTypeAccount:
+		return "account " + key.Account.AccountId.Address()
+	case LedgerEntryTypeTrustline:
+		return "trustline " + key.TrustLine.AccountId.Address() + " " + key.TrustLine.Asset.String()
+	case LedgerEntryTypeOffer:
+		return fmt.Sprintf("offer %s %d", key.Offer.SellerId.Address(), key.Offer.OfferId)
+	case LedgerEntryTypeData:
+		return fmt.Sprintf("data %s %q", key.Data.AccountId.Address(), key.Data.DataName)
+	default:
+		return fmt.Sprintf("unknown ledger entry type: %v", key.Type)
+	}
+}
+
+var accountFlagNames = []struct {
+	flag AccountFlags
+	name string
+}{
+	{AccountFlagsAuthRequiredFlag, "AUTH_REQUIRED"},
+	{AccountFlagsAuthRevocableFlag, "AUTH_REVOCABLE"},
+	{AccountFlagsAuthImmutableFlag, "AUTH_IMMUTABLE"},
+}
+
+// entryDiffer collects field changes of a ledger entry.
+type entryDiffer struct {
+	diff LedgerEntryDiff
+}
+
+func (d *entryDiffer) add(field, before, after, summary string) {
+	d.diff = append(d.diff, FieldChange{
+		Field:   field,
+		Before:  before,
+		After:   after,
+		Summary: summary,
+	})
+}
+
+func (d *entryDiffer) value(field, before, after string) {
+	if before != after {
+		d.add(field, before, after, before+"→"+after)
+	}
+}
+
+func (d *entryDiffer) uint(field string, before, after uint64) {
+	d.value(field, strconv.FormatUint(before, 10), strconv.FormatUint(after, 10))
+}
+
+func (d *entryDiffer) int(field string, before, after int64) {
+	d.value(field, strconv.FormatInt(before, 10), strconv.FormatInt(after, 10))
+}
+
+// amount adds a change of an amount in stroops, summarized as a signed
+// delta, ex. "-1.5000000".
+func (d *entryDiffer) amount(field string, before, after Int64) {
+	if before == after {
+		return
+	}
+
+	// Compute the delta in uint64 so that it doesn't overflow for any pair
+	// of amounts.
+	sign := "+"
+	delta := uint64(after) - uint64(before)
+	if after < before {
+		sign = "-"
+		delta = uint64(before) - uint64(after)
+	}
+
+	d.add(field, formatAmount(int64(before)), formatAmount(int64(after)), sign+formatStroops(delta))
+}
+
+func (d *entryDiffer) flag(name string, before, after bool) {
+	switch {
+	case !before && after:
+		d.add("flag "+name, "", name, "set")
+	case before && !after:
+		d.add("flag "+name, name, "", "cleared")
+	}
+}
+
+func (d *entryDiffer) liabilities(before, after Liabilities) {
+	d.amount("buying_liabilities", before.Buying, after.Buying)
+	d.amount("selling_liabilities", before.Selling, after.Selling)
+}
+
+func (d *entryDiffer) account(before, after AccountEntry) {
+	d.amount("balance", before.Balance, after.Balance)
+	d.int("seq_num", int64(before.SeqNum), int64(after.SeqNum))
+	d.uint("num_subentries", uint64(before.NumSubEntries), uint64(after.NumSubEntries))
+	d.value("inflation_dest", optionalAddress(before.InflationDest), optionalAddress(after.InflationDest))
+	d.value("home_domain", strconv.Quote(string(before.HomeDomain)), strconv.Quote(string(after.HomeDomain)))
+	d.uint("master_weight", uint64(before.MasterKeyWeight()), uint64(after.MasterKeyWeight()))
+	d.uint("low_threshold", uint64(before.ThresholdLow()), uint64(after.ThresholdLow()))
+	d.uint("med_threshold", uint64(before.ThresholdMedium()), uint64(after.ThresholdMedium()))
+	d.uint("high_threshold", uint64(before.ThresholdHigh()), uint64(after.ThresholdHigh()))
+
+	for _, f := range accountFlagNames {
+		d.flag(
+			f.name,
+			AccountFlags(before.Flags)&f.flag != 0,
+			AccountFlags(after.Flags)&f.flag != 0,
+		)
+	}
+
+	d.signers(before.Signers, after.Signers)
+	d.liabilities(accountLiabilities(before), accountLiabilities(after))
+}
+
+func (d *entryDiffer) signers(before, after []Signer) {
+	beforeWeights := map[string]Uint32{}
+	for _, signer := range before {
+		beforeWeights[signer.Key.Address()] = signer.Weight
+	}
+	afterWeights := map[string]Uint32{}
+	for _, signer := range after {
+		afterWeights[signer.Key.Address()] = signer.Weight
+	}
+
+	// Signers are kept sorted by key in accounts but sort them anyway to
+	// make the diff deterministic for any input.
+	for _, signer := range SortSignersByKey(before) {
+		key := signer.Key.Address()
+		weight := strconv.FormatUint(uint64(signer.Weight), 10)
+		afterWeight, ok := afterWeights[key]
+		if !ok {
+			d.add("signer "+key, weight, "", "removed")
+			continue
+		}
+
+		if afterWeight != signer.Weight {
+			newWeight := strconv.FormatUint(uint64(afterWeight), 10)
+			d.add("signer "+key, weight, newWeight, "weight "+weight+"→"+newWeight)
+		}
+	}
+
+	for _, signer := range SortSignersByKey(after) {
+		key := signer.Key.Address()
+		if _, ok := beforeWeights[key]; !ok {
+			weight := strconv.FormatUint(uint64(signer.Weight), 10)
+			d.add("signer "+key, "", weight, "added with weight "+weight)
+		}
+	}
+}
+
+func (d *entryDiffer) trustLine(before, after TrustLineEntry) {
+	d.amount("balance", before.Balance, after.Balance)
+	d.amount("limit", before.Limit, after.Limit)
+	d.flag(
+		"AUTHORIZED",
+		TrustLineFlags(before.Flags).IsAuthorized(),
+		TrustLineFlags(after.Flags).IsAuthorized(),
+	)
+	d.liabilities(trustLineLiabilities(before), trustLineLiabilities(after))
+}
+
+func (d *entryDiffer) offer(before, after OfferEntry) {
+	d.value("selling", before.Selling.String(), after.Selling.String())
+	d.value("buying", before.Buying.String(), after.Buying.String())
+	d.amount("amount", before.Amount, after.Amount)
+	d.value("price", priceString(before.Price), priceString(after.Price))
+	d.flag(
+		"PASSIVE",
+		OfferEntryFlags(before.Flags)&OfferEntryFlagsPassiveFlag != 0,
+		OfferEntryFlags(after.Flags)&OfferEntryFlagsPassiveFlag != 0,
+	)
+}
+
+func (d *entryDiffer) data(before, after DataEntry) {
+	d.value(
+		"value",
+		base64.StdEncoding.EncodeToString(before.DataValue),
+		base64.StdEncoding.EncodeToString(after.DataValue),
+	)
+}
+
+func accountLiabilities(account AccountEntry) Liabilities {
+	if v1, ok := account.Ext.GetV1(); ok {
+		return v1.Liabilities
+	}
+	return Liabilities{}
+}
+
+func trustLineLiabilities(trustLine TrustLineEntry) Liabilities {
+	if v1, ok := trustLine.Ext.GetV1(); ok {
+		return v1.Liabilities
+	}
+	return Liabilities{}
+}
+
+func optionalAddress(account *AccountId) string {
+	if account == nil {
+		return "none"
+	}
+	return account.Address()
+}
+
+func priceString(p Price) string {
+	if p.D == 0 {
+		return fmt.Sprintf("%d/%d", p.N, p.D)
+	}
+	return p.String()
+}
+
+// formatAmount formats an amount in stroops the way the amount package does.
+// It can't be used here because it imports xdr.
+func formatAmount(v int64) string {
+	if v < 0 {
+		return "-" + formatStroops(uint64(-v))
+	}
+	return formatStroops(uint64(v))
+}
+
+func formatStroops(v uint64) string {
+	return fmt.Sprintf("%d.%07d", v/10000000, v%10000000)
+}
@@ -0,0 +1,183 @@
+package xdr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLedgerEntries(t *testing.T) {
+	account := MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB")
+	signer1 := MustSigner("GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H")
+	signer2 := MustSigner("GCFXHS4GXL6BVUCXBWXGTITROWLVYXQKQLF4YH5O5JT3YZXCYPAFBJZB")
+	signer3 := MustSigner("GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML")
+
+	before := LedgerEntry{
+		LastModifiedLedgerSeq: 10,
+		Data: LedgerEntryData{
+			Type: LedgerEntryTypeAccount,
+			Account: &AccountEntry{
+				AccountId:  account,
+				Balance:    1000000000,
+				SeqNum:     7,
+				Flags:      Uint32(AccountFlagsAuthRevocableFlag),
+				HomeDomain: "example.com",
+				Thresholds: Thresholds{1, 0, 0, 0},
+				Signers: []Signer{
+					{Key: signer1, Weight: 1},
+					{Key: signer2, Weight: 1},
+				},
+			},
+		},
+	}
+	after := LedgerEntry{
+		LastModifiedLedgerSeq: 12,
+		Data: LedgerEntryData{
+			Type: LedgerEntryTypeAccount,
+			Account: &AccountEntry{
+				AccountId:  account,
+				Balance:    1500000000,
+				SeqNum:     8,
+				Flags:      Uint32(AccountFlagsAuthRequiredFlag),
+				HomeDomain: "example.com",
+				Thresholds: Thresholds{1, 0, 2, 0},
+				Signers: []Signer{
+					{Key: signer1, Weight: 0},
+					{Key: signer3, Weight: 5},
+				},
+				Ext: AccountEntryExt{
+					V:  1,
+					V1: &AccountEntryV1{Liabilities: Liabilities{Selling: 100}},
+				},
+			},
+		},
+	}
+
+	diff, err := DiffLedgerEntries(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, FieldChange{
+		Field:   "balance",
+		Before:  "100.0000000",
+		After:   "150.0000000",
+		Summary: "+50.0000000",
+	}, diff[0])
+	assert.Equal(t, []string{
+		"balance +50.0000000",
+		"seq_num 7→8",
+		"med_threshold 0→2",
+		"flag AUTH_REQUIRED set",
+		"flag AUTH_REVOCABLE cleared",
+		"signer " + signer1.Address() + " weight 1→0",
+		"signer " + signer2.Address() + " removed",
+		"signer " + signer3.Address() + " added with weight 5",
+		"selling_liabilities +0.0000100",
+		"last_modified_ledger 10→12",
+	}, diffStrings(diff))
+
+	diff, err = DiffLedgerEntries(after, before)
+	require.NoError(t, err)
+	assert.Equal(t, "balance -50.0000000", diff[0].String())
+
+	diff, err = DiffLedgerEntries(before, before)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	usd := MustNewCreditAsset("USD", signer1.Address())
+	trustLine := func(balance Int64, flags TrustLineFlags) LedgerEntry {
+		return LedgerEntry{
+			Data: LedgerEntryData{
+				Type: LedgerEntryTypeTrustline,
+				TrustLine: &TrustLineEntry{
+					AccountId: account,
+					Asset:     usd,
+					Balance:   balance,
+					Limit:     1000000000,
+					Flags:     Uint32(flags),
+				},
+			},
+		}
+	}
+	diff, err = DiffLedgerEntries(trustLine(5, TrustLineFlagsAuthorizedFlag), trustLine(-5, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "balance -0.0000010, flag AUTHORIZED cleared", diff.String())
+
+	offer := func(amount Int64, price Price) LedgerEntry {
+		return LedgerEntry{
+			Data: LedgerEntryData{
+				Type: LedgerEntryTypeOffer,
+				Offer: &OfferEntry{
+					SellerId: account,
+					OfferId:  1,
+					Selling:  MustNewNativeAsset(),
+					Buying:   usd,
+					Amount:   amount,
+					Price:    price,
+				},
+			},
+		}
+	}
+	diff, err = DiffLedgerEntries(offer(10, Price{N: 1, D: 2}), offer(9223372036854775807, Price{N: 3, D: 1}))
+	require.NoError(t, err)
+	assert.Equal(t, "amount +922337203685.4775797, price 0.5000000→3.0000000", diff.String())
+
+	_, err = DiffLedgerEntries(before, trustLine(5, 0))
+	assert.EqualError(t, err, "cannot diff entries with different keys: account "+account.Address()+
+		" and trustline "+account.Address()+" credit_alphanum4/USD/"+signer1.Address())
+}
+
+func TestLedgerEntryChangesSummary(t *testing.T) {
+	account := MustAddress("GAOQJGUAB7NI7K7I62ORBXMN3J4SSWQUQ7FOEPSDJ322W2HMCNWPHXFB")
+	state := LedgerEntry{
+		Data: LedgerEntryData{
+			Type:    LedgerEntryTypeAccount,
+			Account: &AccountEntry{AccountId: account, Balance: 100000000},
+		},
+	}
+	updated := state
+	updated.Data.Account = &AccountEntry{AccountId: account, Balance: 50000000}
+	data := LedgerEntry{
+		Data: LedgerEntryData{
+			Type: LedgerEntryTypeData,
+			Data: &DataEntry{AccountId: account, DataName: "name", DataValue: DataValue("value")},
+		},
+	}
+
+	meta := TransactionMeta{
+		V: 1,
+		V1: &TransactionMetaV1{
+			Operations: []OperationMeta{
+				{Changes: LedgerEntryChanges{
+					{Type: LedgerEntryChangeTypeLedgerEntryState, State: &state},
+					{Type: LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &updated},
+					{Type: LedgerEntryChangeTypeLedgerEntryCreated, Created: &data},
+				}},
+				{Changes: LedgerEntryChanges{
+					{Type: LedgerEntryChangeTypeLedgerEntryState, State: &data},
+					{Type: LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &LedgerKey{
+						Type: LedgerEntryTypeData,
+						Data: &LedgerKeyData{AccountId: account, DataName: "name"},
+					}},
+				}},
+			},
+		},
+	}
+
+	summaries, err := meta.OperationsSummary()
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	require.Len(t, summaries[0], 2)
+	assert.Equal(t, LedgerEntryChangeTypeLedgerEntryUpdated, summaries[0][0].Type)
+	assert.Equal(t, "account "+account.Address()+" updated: balance -5.0000000", summaries[0][0].String())
+	assert.Equal(t, "data "+account.Address()+` "name" created`, summaries[0][1].String())
+	require.Len(t, summaries[1], 1)
+	assert.Equal(t, "data "+account.Address()+` "name" removed`, summaries[1][0].String())
+}
+
+func diffStrings(diff LedgerEntryDiff) []string {
+	var strs []string
+	for _, change := range diff {
+		strs = append(strs, change.String())
+	}
+	return strs
+}
@@ -1,5 +1,7 @@
 package xdr
 
+import "fmt"
+
 // Operations is a helper on TransactionMeta that returns operations
 // meta from `TransactionMeta.Operations` or `TransactionMeta.V1.Operations`.
 func (transactionMeta *TransactionMeta) OperationsMeta() []OperationMeta {
@@ -10,3 +12,87 @@ func (transactionMeta *TransactionMeta) OperationsMeta() []OperationMeta {
 
 	return transactionMeta.MustV1().Operations
 }
+
+// LedgerEntryChangeSummary describes how a single ledger entry was changed.
+type LedgerEntryChangeSummary struct {
+	Key LedgerKey
+	// Type is LedgerEntryChangeTypeLedgerEntryCreated,
+	// LedgerEntryChangeTypeLedgerEntryUpdated or
+	// LedgerEntryChangeTypeLedgerEntryRemoved.
+	Type LedgerEntryChangeType
+	// Diff contains changed fields of updated entries. It's empty for
+	// created and removed entries and for updates without state before.
+	Diff LedgerEntryDiff
+}
+
+// String returns a display friendly form of the summary, ex.
+// "account GABC... updated: balance +50.0000000".
+func (s LedgerEntryChangeSummary) String() string {
+	var action string
+	switch s.Type {
+	case LedgerEntryChangeTypeLedgerEntryCreated:
+		action = "created"
+	case LedgerEntryChangeTypeLedgerEntryUpdated:
+		action = "updated"
+	case LedgerEntryChangeTypeLedgerEntryRemoved:
+		action = "removed"
+	default:
+		action = s.Type.String()
+	}
+
+	str := LedgerKeyString(s.Key) + " " + action
+	if len(s.Diff) > 0 {
+		str += ": " + s.Diff.String()
+	}
+	return str
+}
+
+// Summary pairs every updated or removed entry with the state preceding it
+// and returns how each entry was changed, in the order of changes.
+func (changes LedgerEntryChanges) Summary() ([]LedgerEntryChangeSummary, error) {
+	states := map[string]LedgerEntry{}
+	var summaries []LedgerEntryChangeSummary
+
+	for _, change := range changes {
+		key := change.LedgerKey()
+		keyString, err := MarshalBase64(key)
+		if err != nil {
+			return nil, err
+		}
+
+		switch change.Type {
+		case LedgerEntryChangeTypeLedgerEntryState:
+			states[keyString] = change.MustState()
+			continue
+		case LedgerEntryChangeTypeLedgerEntryUpdated:
+			summary := LedgerEntryChangeSummary{Key: key, Type: change.Type}
+			if state, ok := states[keyString]; ok {
+				summary.Diff, err = DiffLedgerEntries(state, change.MustUpdated())
+				if err != nil {
+					return nil, err
+				}
+			}
+			summaries = append(summaries, summary)
+		default:
+			summaries = append(summaries, LedgerEntryChangeSummary{Key: key, Type: change.Type})
+		}
+		delete(states, keyString)
+	}
+
+	return summaries, nil
+}
+
+// OperationsSummary returns summaries of ledger entry changes of every
+// operation in the transaction.
+func (transactionMeta *TransactionMeta) OperationsSummary() ([][]LedgerEntryChangeSummary, error) {
+	operations := transactionMeta.OperationsMeta()
+	summaries := make([][]LedgerEntryChangeSummary, len(operations))
+	for i, operation := range operations {
+		var err error
+		summaries[i], err = operation.Changes.Summary()
+		if err != nil {
+			return nil, fmt.Errorf("error summarizing operation %d: %v", i, err)
+		}
+	}
+	return summaries, nil
+}